@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/credstore"
+)
+
+// Manages the credential store (CREDENTIAL_DIR, encrypted with CREDENTIAL_KEY
+// or CREDENTIAL_KEY_FILE). Values are read from stdin so they stay out of the
+// shell history, and are never printed:
+//
+//	go run ./cmd/credentials put KIS_APP_KEY < app_key.txt
+//	go run ./cmd/credentials list
+//	go run ./cmd/credentials delete kis_token_real
+//
+// KIS_APP_KEY, KIS_APP_SECRET, KIS_ACCOUNT_NUM, ALPACA_API_KEY and
+// ALPACA_SECRET_KEY entries are used when the variable is not set.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: credentials put NAME < value | list | delete NAME")
+	}
+	flag.Parse()
+	if err := godotenv.Load("../.env"); err != nil {
+		godotenv.Load()
+	}
+
+	cfg := config.Load()
+	store, err := credstore.FromConfig(cfg)
+	if err != nil {
+		log.Fatal("Credential store init failed:", err)
+	}
+
+	switch args := flag.Args(); {
+	case len(args) == 2 && args[0] == "put":
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		value = strings.TrimSpace(value)
+		if value == "" {
+			log.Fatalf("No value on stdin (%v)", err)
+		}
+		if err := store.Put(args[1], []byte(value)); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Stored %s in %s (encrypted: %v)", args[1], cfg.CredentialDir, store.Encrypted())
+	case len(args) == 1 && args[0] == "list":
+		names, err := store.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	case len(args) == 2 && args[0] == "delete":
+		if err := store.Delete(args[1]); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Deleted %s", args[1])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+)
+
+// Runs the fake KIS API so the server can be exercised offline:
+//
+//	go run ./cmd/fake_kis -addr :9443 -scenario scenario.json
+//	KIS_BASE_URL=http://localhost:9443 KIS_MODE=real go run ./cmd/server
+//
+// The realtime WebSocket is served at ws://localhost:9443/ws (KIS_WS_URL).
+func main() {
+	addr := flag.String("addr", ":9443", "listen address")
+	scenarioPath := flag.String("scenario", "", "scenario JSON file (default: built-in demo account)")
+	flag.Parse()
+
+	sc := demoScenario()
+	if *scenarioPath != "" {
+		loaded, err := kisfake.LoadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatalf("Failed to load scenario: %v", err)
+		}
+		sc = loaded
+		log.Printf("[KISFAKE] Loaded scenario from %s", *scenarioPath)
+	} else {
+		log.Println("[KISFAKE] Using built-in demo scenario")
+	}
+
+	fake := kisfake.New(sc)
+	log.Printf("[KISFAKE] Fake KIS API listening on %s (Cash: $%.2f, Holdings: %d, Faults: %d)",
+		*addr, sc.Cash, len(sc.Holdings), len(sc.Faults))
+	if err := http.ListenAndServe(*addr, fake); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// demoScenario holds the V2 rebalance assets with 200 days of synthetic history
+func demoScenario() kisfake.Scenario {
+	start := map[string]float64{"TQQQ": 45, "PFIX": 40, "SCHD": 26, "TMF": 6}
+	drift := map[string]float64{"TQQQ": 0.002, "PFIX": -0.0005, "SCHD": 0.0003, "TMF": -0.001}
+
+	sc := kisfake.Scenario{
+		Cash:     10000,
+		Prices:   make(map[string]float64),
+		Daily:    make(map[string][]kisfake.Bar),
+		AutoFill: true,
+		Holdings: []kisfake.Holding{
+			{Symbol: "TQQQ", Exchange: "NASD", Qty: 20, AvgPrice: 50},
+			{Symbol: "SCHD", Exchange: "AMEX", Qty: 40, AvgPrice: 27},
+		},
+	}
+	for sym, p0 := range start {
+		bars := syntheticDaily(p0, drift[sym], 200)
+		sc.Daily[sym] = bars
+		sc.Prices[sym] = bars[0].Close
+	}
+	return sc
+}
+
+// syntheticDaily builds n weekday bars ending yesterday, newest first
+func syntheticDaily(p0, drift float64, n int) []kisfake.Bar {
+	var bars []kisfake.Bar
+	day := time.Now().AddDate(0, 0, -1)
+	for len(bars) < n {
+		if wd := day.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			bars = append(bars, kisfake.Bar{Date: day.Format("20060102")})
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+
+	// Walk forward in time from the oldest bar
+	price := p0
+	for i := len(bars) - 1; i >= 0; i-- {
+		wave := 0.01 * math.Sin(float64(i)/7)
+		open := price
+		price = math.Max(0.01, price*(1+drift+wave))
+		bars[i].Open = round2(open)
+		bars[i].Close = round2(price)
+		bars[i].High = round2(math.Max(open, price) * 1.01)
+		bars[i].Low = round2(math.Min(open, price) * 0.99)
+		bars[i].Volume = 1_000_000
+	}
+	return bars
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
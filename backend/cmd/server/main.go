@@ -6,16 +6,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/api"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/cassette"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/credstore"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/paper"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/service"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/worker"
 )
 
@@ -23,61 +29,138 @@ func main() {
 	// 1. Config
 	cfg := config.Load()
 
+	// 1.1 Credentials: keys missing from the env come from the store, and no
+	// secret (keys, account, tokens) reaches a log line
+	log.SetOutput(credstore.NewRedactor(os.Stderr))
+	gin.DefaultWriter = credstore.NewRedactor(os.Stdout)
+	gin.DefaultErrorWriter = credstore.NewRedactor(os.Stderr)
+	credStore, err := credstore.FromConfig(cfg)
+	if err != nil {
+		log.Fatal("Credential store init failed:", err)
+	}
+	if err := credstore.LoadCredentials(credStore, cfg); err != nil {
+		log.Fatal("Credential load failed:", err)
+	}
+	credstore.Register(cfg.Secrets()...)
+
 	// 2. DB
 	db, err := repository.NewDB("data/db.sqlite")
 	if err != nil {
 		log.Fatal("DB init failed:", err)
 	}
 
-	// 3. KIS Client
-	client := kis.NewClient(cfg)
-
-	// 4. Strategy
-	strat := service.NewStrategy(db, client)
-
-	// 3.1 Market Data (Alpaca + DuckDB)
-	alpacaClient := market.NewAlpacaClient(cfg)
+	// 3. Market Data (Alpaca + DuckDB)
+	alpacaTransport, err := cassette.FromConfig(cfg, "alpaca")
+	if err != nil {
+		log.Fatal("Alpaca cassette init failed:", err)
+	}
+	alpacaClient := market.NewAlpacaClient(cfg, alpacaTransport)
 	marketSvc := market.NewMarketDataService(cfg, alpacaClient)
 	marketRepo, err := market.NewMarketRepository()
 	if err != nil {
 		log.Printf("⚠ MarketRepository (DuckDB) init failed: %v", err)
 	}
 
+	// 3.1 Broker (KIS or Paper)
+	var brk broker.Broker
+	switch cfg.Broker {
+	case "paper":
+		paperBroker, err := paper.NewBroker(marketRepo, cfg.PaperLedgerPath, cfg.PaperInitialCash)
+		if err != nil {
+			log.Fatal("Paper broker init failed:", err)
+		}
+		brk = paperBroker
+	default:
+		client, err := kis.NewClient(cfg)
+		if err != nil {
+			log.Fatal("KIS client init failed:", err)
+		}
+		kisTransport, err := cassette.FromConfig(cfg, "kis")
+		if err != nil {
+			log.Fatal("KIS cassette init failed:", err)
+		}
+		if kisTransport != nil {
+			client.Client.Transport = kisTransport
+		}
+		client.Store = credStore
+		brk = kis.NewBroker(client)
+	}
+	log.Printf("[STARTUP] Broker: %s (Mode: %s)", brk.Name(), strings.ToUpper(brk.Mode()))
+
+	// 4. Strategy
+	symbolMaster, err := symbols.Load(cfg.SymbolMaster)
+	if err != nil {
+		log.Fatal("Symbol master init failed:", err)
+	}
+	log.Printf("[STARTUP] Symbol master: %d symbols (%s)", symbolMaster.Len(), strings.Join(symbolMaster.Sources(), ", "))
+	strat := service.NewStrategy(db, brk, symbolMaster)
+	placement, err := service.ParsePlacement(cfg.OrderPlacement)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, ok := brk.(broker.Reserver); !ok && placement == service.PlacementReserve {
+		log.Printf("[STARTUP] ⚠ %s broker does not take reservation orders, placing live orders", brk.Name())
+		placement = service.PlacementLive
+	}
+	strat.Placement = placement
+	log.Printf("[STARTUP] Order placement: %s", strat.Placement)
+	if err := service.CheckScheduleTime(cfg.ScheduleTime); err != nil {
+		log.Fatal(err)
+	}
+	strat.FX.Fallback = cfg.FXRate
+	if cfg.FXRate > 0 {
+		log.Printf("[STARTUP] USD/KRW fallback rate: %.2f", cfg.FXRate)
+	}
+
 	// 5. Handler
 	handler := api.NewHandler(db, strat, marketSvc, marketRepo)
 
 	// 6. Scheduler
-	scheduler := worker.NewScheduler(strat, marketSvc)
+	scheduler := worker.NewScheduler(cfg, strat, marketSvc)
 	scheduler.Start()
+	handler.Scheduler = scheduler
+
+	// 6.1 Realtime quotes (KIS WebSocket)
+	quoteCtx, stopQuotes := context.WithCancel(context.Background())
+	defer stopQuotes()
+	if cfg.RealtimeQuotes {
+		if _, ok := brk.(broker.QuoteStreamer); ok {
+			go strat.RunQuoteFeed(quoteCtx)
+		} else {
+			log.Printf("[STARTUP] ⚠ %s broker has no realtime quote feed, using REST quotes", brk.Name())
+		}
+	}
 
 	// 7. Startup Balance Check (for debugging via docker logs)
 	log.Println("========================================")
-	log.Println("[STARTUP] Checking KIS API connection...")
-	balance, err := client.GetBalance()
+	log.Printf("[STARTUP] Checking broker connection (%s)...", brk.Name())
+	startupCtx, startupCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer startupCancel()
+	balance, err := brk.GetBalance(startupCtx)
 	if err != nil {
 		log.Printf("[STARTUP] ✗ Balance check failed: %v", err)
 	} else {
 		log.Printf("[STARTUP] ✓ API connection successful!")
-		log.Printf("[STARTUP] Account: %s", cfg.KisAccountNum)
+		log.Printf("[STARTUP] Account set: %v", cfg.KisAccountNum != "")
 		log.Println("[STARTUP] ----------------------------------------")
 
 		// Cash balance inquiry
-		cashBalance, cashErr := client.GetBuyingPower()
+		cash, cashErr := brk.GetCash(startupCtx)
 		if cashErr != nil {
 			log.Printf("[STARTUP] Cash Balance: (조회 실패: %v)", cashErr)
 		} else {
-			log.Printf("[STARTUP] 💵 Available Cash: $%s", cashBalance.Output.OvrsOrdPsblAmt)
+			log.Printf("[STARTUP] 💵 Available Cash: $%.2f", cash)
 		}
 
-		log.Printf("[STARTUP] Total Invested: $%s", balance.Output2.TotalPurchase)
-		log.Printf("[STARTUP] Total Evaluation: $%s", balance.Output2.TotalAmt)
-		log.Printf("[STARTUP] Total P/L: $%s (%s%%)", balance.Output2.TotalPL, balance.Output2.TotalPLRate)
-		log.Printf("[STARTUP] Realized P/L: $%s", balance.Output2.RealizedPL)
+		log.Printf("[STARTUP] Total Invested: $%.2f", balance.TotalPurchase)
+		log.Printf("[STARTUP] Total Evaluation: $%.2f", balance.TotalEvaluation)
+		log.Printf("[STARTUP] Total P/L: $%.2f (%.2f%%)", balance.TotalPL, balance.TotalPLRate)
+		log.Printf("[STARTUP] Realized P/L: $%.2f", balance.RealizedPL)
 		log.Println("[STARTUP] ----------------------------------------")
-		log.Printf("[STARTUP] Holdings: %d", len(balance.Output1))
-		for i, h := range balance.Output1 {
-			log.Printf("[STARTUP]   [%d] %s:%s - Qty: %s, AvgPrice: $%s, Now: $%s",
-				i+1, h.ExchCode, h.Symbol, h.Qty, h.AvgPrice, h.NowPrice)
+		log.Printf("[STARTUP] Holdings: %d", len(balance.Positions))
+		for i, h := range balance.Positions {
+			log.Printf("[STARTUP]   [%d] %s:%s - Qty: %d, AvgPrice: $%.2f, Now: $%.2f",
+				i+1, h.Exchange, h.Symbol, h.Qty, h.AvgPrice, h.CurrentPrice)
 		}
 	}
 	log.Println("========================================")
@@ -93,10 +176,49 @@ func main() {
 
 		v1.POST("/sync", handler.TriggerSync)
 
+		// Infinite-buy cycles
+		v1.GET("/cycles/history", handler.GetCycleHistory)
+		v1.GET("/cycles/events", handler.GetCycleEvents)
+		v1.GET("/cycles/config", handler.GetCycleConfigs)
+		v1.POST("/cycles/config/:symbol", handler.UpdateCycleConfig)
+		v1.DELETE("/cycles/config/:symbol", handler.DeleteCycleConfig)
+
+		// Symbol master
+		v1.GET("/symbols", handler.GetSymbols)
+		v1.GET("/symbols/:symbol", handler.GetSymbol)
+
+		// Orders API
+		v1.GET("/orders", handler.GetOrders)
+		v1.GET("/orders/open", handler.GetOpenOrders)
+		v1.POST("/orders/cancel", handler.CancelOrdersForSymbol)
+		v1.POST("/orders/:id/cancel", handler.CancelOrder)
+		v1.POST("/orders/:id/amend", handler.AmendOrder)
+		v1.GET("/orders/reservations", handler.GetReservations)
+
 		// Rebalance API
 		v1.GET("/rebalance/preview", handler.GetRebalancePreview)
 		v1.POST("/rebalance/execute", handler.ExecuteRebalance)
 		v1.POST("/rebalance/execute-custom", handler.ExecuteCustomRebalance)
+		v1.POST("/rebalance/queue", handler.QueueRebalance)
+
+		// Strategy plug-ins API
+		v1.GET("/strategies", handler.GetStrategies)
+		v1.GET("/strategies/:name", handler.GetStrategy)
+		v1.POST("/strategies/:name", handler.UpdateStrategy)
+		v1.GET("/strategies/:name/evaluate", handler.EvaluateStrategy)
+		v1.POST("/strategies/:name/execute", handler.ExecuteStrategy)
+
+		// Realized P&L API
+		v1.GET("/pnl/realized", handler.GetRealizedPnL)
+		v1.POST("/pnl/import", handler.ImportPnL)
+
+		// Realtime quotes API
+		v1.GET("/quotes", handler.GetQuotes)
+		v1.GET("/quotes/stream", handler.StreamQuotes)
+
+		// Exchange rate API
+		v1.GET("/fx", handler.GetFXRates)
+		v1.POST("/fx/refresh", handler.RefreshFXRate)
 
 		// Market Data API
 		v1.POST("/market/backfill", handler.Backfill)
@@ -112,6 +234,8 @@ func main() {
 		Addr:    ":" + port,
 		Handler: r,
 	}
+	// Ending the feed closes open quote streams, which Shutdown would wait for
+	srv.RegisterOnShutdown(stopQuotes)
 
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
@@ -132,6 +256,9 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
+	// Abort in-flight scheduled jobs (e.g. a running rebalance) first
+	scheduler.Stop()
+
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -22,7 +22,7 @@ func main() {
 	}
 
 	log.Println("Initializing Alpaca Client...")
-	client := market.NewAlpacaClient(cfg)
+	client := market.NewAlpacaClient(cfg, nil)
 	if client == nil {
 		log.Fatal("Failed to create Alpaca client")
 	}
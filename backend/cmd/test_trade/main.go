@@ -2,15 +2,20 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/cassette"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/credstore"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/service"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
 )
 
 func loadEnv() {
@@ -63,11 +68,17 @@ func main() {
 	// 1. Config
 	cfg := config.Load()
 
-	maskKey := "EMPTY"
-	if len(cfg.KisAppKey) > 5 {
-		maskKey = cfg.KisAppKey[:5] + "..."
+	// Same credential store and token lock as the server, so the two never race on the token
+	log.SetOutput(credstore.NewRedactor(os.Stderr))
+	credStore, err := credstore.FromConfig(cfg)
+	if err != nil {
+		log.Fatal("Credential store init failed:", err)
+	}
+	if err := credstore.LoadCredentials(credStore, cfg); err != nil {
+		log.Fatal("Credential load failed:", err)
 	}
-	log.Printf("Config loaded. Account: %s, AppKey: %s", cfg.KisAccountNum, maskKey)
+	credstore.Register(cfg.Secrets()...)
+	log.Printf("Config loaded. Account set: %v, AppKey set: %v", cfg.KisAccountNum != "", cfg.KisAppKey != "")
 
 	// 2. DB
 	db, err := repository.NewDB("data/db.sqlite")
@@ -85,21 +96,37 @@ func main() {
 	}
 
 	// 3. KIS Client
-	client := kis.NewClient(cfg)
+	client, err := kis.NewClient(cfg)
+	if err != nil {
+		log.Fatal("KIS client init failed:", err)
+	}
+	// CASSETTE_MODE=record captures this run as a fixture for later replay
+	kisTransport, err := cassette.FromConfig(cfg, "kis")
+	if err != nil {
+		log.Fatal("KIS cassette init failed:", err)
+	}
+	if kisTransport != nil {
+		client.Client.Transport = kisTransport
+	}
+	client.Store = credStore
+	log.Printf("KIS mode: %s", client.Mode())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
 
 	// 4. Test Buying Power API
 	log.Println("Testing GetBuyingPower API...")
-	bp, bpErr := client.GetBuyingPower()
+	bp, bpErr := client.GetBuyingPower(ctx, kis.BuyingPowerQuery{ExchCode: "NASD", Symbol: "TQQQ"})
 	if bpErr != nil {
 		log.Printf("✗ GetBuyingPower failed: %v", bpErr)
 	} else {
-		log.Printf("✓ Available Cash: $%s", bp.Output.OvrsOrdPsblAmt)
+		log.Printf("✓ Available Cash: $%s (Max TQQQ Qty: %s)", bp.Output.OvrsOrdPsblAmt, bp.Output.MaxOrdPsblQty)
 	}
 
 	// 5. Test Order Error Handling
 	log.Println("Testing PlaceOrder Error Handling (0 qty)...")
-	errReq := kis.OrderReq{ExchCode: "NASD", Symbol: "TQQQ", OrdType: "00", Side: "BUY", Qty: 0, Price: 50.0}
-	if err := client.PlaceOrder(errReq); err != nil {
+	errReq := kis.OrderReq{ExchCode: "NASD", Symbol: "TQQQ", OrdType: kis.OrdLimit, Side: "BUY", Qty: 0, Price: 50.0}
+	if _, err := client.PlaceOrder(ctx, errReq); err != nil {
 		log.Printf("✓ Correctly caught error: %v", err)
 	} else {
 		log.Printf("✗ Failed to catch error (returned nil)")
@@ -120,11 +147,14 @@ func main() {
 	}
 
 	// 7. Strategy
-	strat := service.NewStrategy(db, client)
+	strat := service.NewStrategy(db, kis.NewBroker(client), symbols.Default())
+	if strat.Placement, err = service.ParsePlacement(cfg.OrderPlacement); err != nil {
+		log.Fatal(err)
+	}
 
 	// 8. Execute
-	log.Println("Triggering ExecuteDaily()...")
-	strat.ExecuteDaily()
+	log.Printf("Triggering ExecuteDaily() (placement: %s)...", strat.Placement)
+	strat.ExecuteDaily(ctx)
 
 	log.Println("=== TEST FINISHED ===")
 
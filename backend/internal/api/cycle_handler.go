@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// GetCycleEvents API: GET /api/cycles/events?symbol=TQQQ&limit=100
+// Exhaustion, quarter stop and reverse mode history of the infinite-buy cycles, newest first
+func (h *Handler) GetCycleEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	events, err := h.Strategy.CycleEvents(c.Query("symbol"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// GetCycleConfigs API: GET /api/cycles/config
+// The effective setup of every symbol of the settings: allocation, principal,
+// splits, rates and the stored override
+func (h *Handler) GetCycleConfigs(c *gin.Context) {
+	var settings model.UserSettings
+	h.Repo.First(&settings)
+	cycles, err := h.Strategy.SymbolCycles(settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cycles": cycles})
+}
+
+// UpdateCycleConfig API: POST /api/cycles/config/:symbol
+// Body: {"Exchange": "AMEX", "Allocation": 0.5, "SplitCount": 30, "TargetRate": 0.12, "LadderRate": 0.05}
+// Zero values fall back to the settings.
+func (h *Handler) UpdateCycleConfig(c *gin.Context) {
+	var input model.CycleConfig
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Symbol = c.Param("symbol")
+	cfg, err := h.Strategy.SaveCycleConfig(input)
+	if err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// DeleteCycleConfig API: DELETE /api/cycles/config/:symbol
+func (h *Handler) DeleteCycleConfig(c *gin.Context) {
+	if err := h.Strategy.DeleteCycleConfig(c.Param("symbol")); err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// GetCycleHistory API: GET /api/cycles/history?symbol=TQQQ
+// Finished infinite-buy cycles (newest first) with the statistics of each symbol
+func (h *Handler) GetCycleHistory(c *gin.Context) {
+	cycles, stats, err := h.Strategy.CycleHistory(c.Query("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"cycles": cycles, "stats": stats})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+)
+
+// categoryStatus maps broker error categories to HTTP status codes
+var categoryStatus = map[broker.ErrorCategory]int{
+	broker.CategoryAuth:         http.StatusBadGateway, // Our credentials, not the caller's
+	broker.CategoryRateLimit:    http.StatusTooManyRequests,
+	broker.CategoryMarketClosed: http.StatusConflict,
+	broker.CategoryFunds:        http.StatusUnprocessableEntity,
+	broker.CategoryValidation:   http.StatusBadRequest,
+	broker.CategoryUnavailable:  http.StatusServiceUnavailable,
+	broker.CategoryConflict:     http.StatusConflict,
+}
+
+// respondError writes err as a structured JSON error:
+// {"error": "...", "category": "RATE_LIMIT", "code": "EGW00201", "tr_id": "TTTS3012R"}
+// extra fields (e.g. partial results) are merged into the body.
+func respondError(c *gin.Context, err error, extra gin.H) {
+	category := broker.CategoryOf(err)
+	status, ok := categoryStatus[category]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	body := gin.H{
+		"error":    err.Error(),
+		"category": category,
+	}
+	var apiErr *kis.APIError
+	if errors.As(err, &apiErr) {
+		body["code"] = apiErr.MsgCd
+		body["tr_id"] = apiErr.TrID
+		body["message"] = apiErr.Msg1
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFXRates API: GET /api/fx?from=2025-01-01&to=2025-01-31
+// Current USD/KRW rate and the stored daily rates (default: last 30 days)
+func (h *Handler) GetFXRates(c *gin.Context) {
+	from, to, ok := dateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	history, err := h.Strategy.FX.History(from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"current": nil, "history": history}
+	if current, err := h.Strategy.FX.Current(c.Request.Context()); err == nil {
+		resp["current"] = current
+	} else {
+		resp["error"] = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RefreshFXRate API: POST /api/fx/refresh
+// Quotes the broker and stores today's rate
+func (h *Handler) RefreshFXRate(c *gin.Context) {
+	rate, err := h.Strategy.FX.Refresh(c.Request.Context())
+	if err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, rate)
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
@@ -13,14 +16,18 @@ import (
 type Handler struct {
 	Repo       *repository.DB
 	Strategy   *service.Strategy
+	Broker     broker.Broker
 	MarketSvc  *market.MarketDataService
 	MarketRepo *market.MarketRepository
+	// Scheduler is re-read on strategy config changes, nil = not scheduled
+	Scheduler StrategyScheduler
 }
 
 func NewHandler(repo *repository.DB, strat *service.Strategy, mSvc *market.MarketDataService, mRepo *market.MarketRepository) *Handler {
 	return &Handler{
 		Repo:       repo,
 		Strategy:   strat,
+		Broker:     strat.Broker,
 		MarketSvc:  mSvc,
 		MarketRepo: mRepo,
 	}
@@ -33,10 +40,19 @@ func (h *Handler) GetDashboard(c *gin.Context) {
 	var cycles []model.CycleStatus
 	h.Repo.Find(&cycles)
 
-	// Just return cycles for now
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
+		"broker": h.Broker.Name(),
+		"mode":   h.Broker.Mode(),
 		"cycles": cycles,
-	})
+	}
+	// Account value in USD and KRW; the cycles are still served when the broker is down
+	if account, err := h.Strategy.AccountValue(c.Request.Context()); err != nil {
+		log.Printf("[API] Dashboard account value failed: %v", err)
+		resp["account_error"] = err.Error()
+	} else {
+		resp["account"] = account
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetSettings
@@ -45,11 +61,14 @@ func (h *Handler) GetSettings(c *gin.Context) {
 	if err := h.Repo.First(&settings).Error; err != nil {
 		// Return default
 		c.JSON(http.StatusOK, model.UserSettings{
-			Principal:  10000,
-			SplitCount: 40,
-			TargetRate: 0.10,
-			IsActive:   false,
-			Symbols:    "TQQQ",
+			Principal:         10000,
+			PrincipalCurrency: service.CurrencyUSD,
+			SplitCount:        40,
+			TargetRate:        0.10,
+			LadderRate:        0.05,
+			ExhaustPolicy:     service.ExhaustQuarter,
+			IsActive:          false,
+			Symbols:           "SOXL",
 		})
 		return
 	}
@@ -63,6 +82,34 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	currency, err := service.ParseCurrency(input.PrincipalCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.PrincipalCurrency = currency
+	policy, err := service.ParseExhaustPolicy(input.ExhaustPolicy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.ExhaustPolicy = policy
+	syms := service.CycleSymbols(input)
+	for _, sym := range syms {
+		if _, err := h.Strategy.Symbols.Lookup(sym); err != nil {
+			respondError(c, err, nil)
+			return
+		}
+	}
+	if err := h.Strategy.CheckCycleSymbols(syms); err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	input.Symbols = strings.Join(syms, ",")
+	if input.LadderRate < 0 || (input.TargetRate > 0 && input.LadderRate >= input.TargetRate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ladder rate must be between 0 and the target rate"})
+		return
+	}
 
 	// Upsert
 	var settings model.UserSettings
@@ -70,8 +117,11 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 		h.Repo.Create(&input)
 	} else {
 		settings.Principal = input.Principal
+		settings.PrincipalCurrency = input.PrincipalCurrency
 		settings.SplitCount = input.SplitCount
 		settings.TargetRate = input.TargetRate
+		settings.LadderRate = input.LadderRate
+		settings.ExhaustPolicy = input.ExhaustPolicy
 		settings.IsActive = input.IsActive
 		settings.Symbols = input.Symbols
 		h.Repo.Save(&settings)
@@ -81,8 +131,8 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 }
 
 func (h *Handler) TriggerSync(c *gin.Context) {
-	if err := h.Strategy.SyncState(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Strategy.SyncState(c.Request.Context()); err != nil {
+		respondError(c, err, nil)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "synced"})
@@ -90,9 +140,9 @@ func (h *Handler) TriggerSync(c *gin.Context) {
 
 // GetRebalancePreview
 func (h *Handler) GetRebalancePreview(c *gin.Context) {
-	plan, err := h.Strategy.CalculateRebalancePlan()
+	plan, err := h.Strategy.CalculateRebalancePlan(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, nil)
 		return
 	}
 	c.JSON(http.StatusOK, plan)
@@ -103,13 +153,25 @@ func (h *Handler) ExecuteRebalance(c *gin.Context) {
 	// Optional dry_run param
 	dryRun := c.Query("dry_run") == "true"
 
-	if err := h.Strategy.ExecuteRebalance(dryRun); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Strategy.ExecuteRebalance(c.Request.Context(), dryRun); err != nil {
+		respondError(c, err, gin.H{"dry_run": dryRun})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "executed", "dry_run": dryRun})
 }
 
+// QueueRebalance places the rebalance as reservation orders for the next
+// session open: POST /api/rebalance/queue?dry_run=true
+func (h *Handler) QueueRebalance(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	if err := h.Strategy.QueueRebalance(c.Request.Context(), dryRun); err != nil {
+		respondError(c, err, gin.H{"dry_run": dryRun})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "queued", "dry_run": dryRun})
+}
+
 // ExecuteCustomRebalance accepts a custom plan from the frontend
 func (h *Handler) ExecuteCustomRebalance(c *gin.Context) {
 	dryRun := c.Query("dry_run") == "true"
@@ -126,8 +188,8 @@ func (h *Handler) ExecuteCustomRebalance(c *gin.Context) {
 		return
 	}
 
-	if err := h.Strategy.ExecuteCustomRebalance(&customPlan, dryRun); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Strategy.ExecuteCustomRebalance(c.Request.Context(), &customPlan, dryRun); err != nil {
+		respondError(c, err, gin.H{"dry_run": dryRun})
 		return
 	}
 
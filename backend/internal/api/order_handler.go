@@ -0,0 +1,146 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// GetOrders API: GET /api/orders?status=FILLED&symbol=TQQQ
+// Lists the 100 most recent tracked orders
+func (h *Handler) GetOrders(c *gin.Context) {
+	query := h.Repo.Order("submitted_at DESC").Limit(100)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if symbol := c.Query("symbol"); symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+
+	var orders []model.Order
+	if err := query.Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// GetOpenOrders API: GET /api/orders/open?symbol=TQQQ
+func (h *Handler) GetOpenOrders(c *gin.Context) {
+	var (
+		orders []model.Order
+		err    error
+	)
+	if symbol := c.Query("symbol"); symbol != "" {
+		orders, err = h.Strategy.Orders.OpenOrdersForSymbol(symbol)
+	} else {
+		orders, err = h.Strategy.Orders.OpenOrders()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(orders), "orders": orders})
+}
+
+// findOrder loads the tracked order named by the :id path parameter
+func (h *Handler) findOrder(c *gin.Context) (*model.Order, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		return nil, false
+	}
+
+	var order model.Order
+	if err := h.Repo.First(&order, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return nil, false
+	}
+	return &order, true
+}
+
+// CancelOrder API: POST /api/orders/:id/cancel
+func (h *Handler) CancelOrder(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	log.Printf("[API] Cancel request for order #%d (%s)", order.ID, order.BrokerOrderID)
+	if err := h.Strategy.Orders.Cancel(c.Request.Context(), order); err != nil {
+		respondError(c, err, gin.H{"order": order})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancel requested", "order": order})
+}
+
+// CancelOrdersForSymbol API: POST /api/orders/cancel?symbol=TQQQ&source=DAILY
+// source limits the cancel to the orders of one source, empty = all
+func (h *Handler) CancelOrdersForSymbol(c *gin.Context) {
+	symbol := c.Query("symbol")
+	source := strings.ToUpper(c.Query("source"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol required"})
+		return
+	}
+
+	log.Printf("[API] Cancel-all request for %s (source: %q)", symbol, source)
+	n, err := h.Strategy.Orders.CancelAllForSymbol(c.Request.Context(), symbol, source)
+	if err != nil {
+		respondError(c, err, gin.H{"cancelled": n})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancel requested", "symbol": symbol, "source": source, "cancelled": n})
+}
+
+// AmendOrder API: POST /api/orders/:id/amend {"qty": 10, "price": 55.5}
+func (h *Handler) AmendOrder(c *gin.Context) {
+	order, ok := h.findOrder(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Qty   int     `json:"qty" binding:"required,gt=0"`
+		Price float64 `json:"price" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("[API] Amend request for order #%d: %d @ $%.2f", order.ID, input.Qty, input.Price)
+	amended, err := h.Strategy.Orders.Amend(c.Request.Context(), order, input.Qty, input.Price)
+	if err != nil {
+		respondError(c, err, gin.H{"order": order})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "amended", "order": amended})
+}
+
+// GetReservations API: GET /api/orders/reservations?days=7
+// Lists the reservation orders known to the broker, tracked or not
+func (h *Handler) GetReservations(c *gin.Context) {
+	days := 7
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	reservations, err := h.Strategy.Orders.Reservations(c.Request.Context(), since)
+	if err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(reservations), "reservations": reservations})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// dateRange reads ?from=YYYY-MM-DD&to=YYYY-MM-DD, defaulting to the
+// defaultDays days up to today (ET). It writes the 400 itself.
+func dateRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	now := time.Now().In(broker.Eastern())
+	to, _ := time.Parse("2006-01-02", now.Format("2006-01-02"))
+	from := to.AddDate(0, 0, -defaultDays)
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date (YYYY-MM-DD)"})
+			return from, to, false
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date (YYYY-MM-DD)"})
+			return from, to, false
+		}
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// ImportPnL API: POST /api/pnl/import?from=2025-01-01&to=2025-01-31
+// Imports the broker's realized profit and trade history (default: last 30 days)
+func (h *Handler) ImportPnL(c *gin.Context) {
+	from, to, ok := dateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	log.Printf("[API] P&L import request: %s ~ %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	res, err := h.Strategy.Profits.Import(c.Request.Context(), from, to)
+	if err != nil {
+		respondError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// GetRealizedPnL API: GET /api/pnl/realized?from=2025-01-01&to=2025-12-31
+// Realized P&L by month and by symbol from the last import, next to the
+// TradeLog figures (default: last 365 days)
+func (h *Handler) GetRealizedPnL(c *gin.Context) {
+	from, to, ok := dateRange(c, 365)
+	if !ok {
+		return
+	}
+
+	summary, err := h.Strategy.Profits.Summary(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// quoteKeepAlive is how often an idle quote stream sends a ping event
+const quoteKeepAlive = 15 * time.Second
+
+// querySymbols reads ?symbols=TQQQ,SOXL (upper-cased, empty = all)
+func querySymbols(c *gin.Context) map[string]bool {
+	out := map[string]bool{}
+	for _, sym := range strings.Split(c.Query("symbols"), ",") {
+		if sym = strings.ToUpper(strings.TrimSpace(sym)); sym != "" {
+			out[sym] = true
+		}
+	}
+	return out
+}
+
+// GetQuotes API: GET /api/quotes?symbols=TQQQ,SOXL
+// Latest realtime quotes from the cache
+func (h *Handler) GetQuotes(c *gin.Context) {
+	want := querySymbols(c)
+	quotes := []broker.Quote{}
+	for _, q := range h.Strategy.Quotes.Snapshot() {
+		if len(want) == 0 || want[q.Symbol] {
+			quotes = append(quotes, q)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"streaming": h.Strategy.Quotes.Streaming(),
+		"quotes":    quotes,
+	})
+}
+
+// StreamQuotes API: GET /api/quotes/stream?symbols=TQQQ,SOXL
+// Server-sent events: a "quote" event per update, starting with the cached
+// quotes. Requested symbols not yet on the feed are subscribed while the
+// stream is open.
+func (h *Handler) StreamQuotes(c *gin.Context) {
+	if !h.Strategy.Quotes.Streaming() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":    "realtime quotes are off (set REALTIME_QUOTES=true with the kis broker)",
+			"category": broker.CategoryUnavailable,
+		})
+		return
+	}
+
+	want := querySymbols(c)
+	var releases []func()
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+	for sym := range want {
+		release, err := h.Strategy.Watch(sym)
+		if err != nil && !errors.Is(err, broker.ErrNotSupported) {
+			respondError(c, err, gin.H{"symbol": sym})
+			return
+		}
+		if release != nil {
+			releases = append(releases, release)
+		}
+	}
+
+	updates, stop := h.Strategy.Quotes.Listen()
+	defer stop()
+	log.Printf("[API] Quote stream opened (symbols: %s)", c.Query("symbols"))
+
+	for _, q := range h.Strategy.Quotes.Snapshot() {
+		if len(want) == 0 || want[q.Symbol] {
+			c.SSEvent("quote", q)
+		}
+	}
+	c.Writer.Flush()
+
+	ping := time.NewTicker(quoteKeepAlive)
+	defer ping.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case q, ok := <-updates:
+			if !ok {
+				return false // Feed stopped
+			}
+			if len(want) == 0 || want[q.Symbol] {
+				c.SSEvent("quote", q)
+			}
+		case t := <-ping.C:
+			c.SSEvent("ping", t.Unix())
+		}
+		return true
+	})
+	log.Printf("[API] Quote stream closed")
+}
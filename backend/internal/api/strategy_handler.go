@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/service"
+)
+
+// StrategyScheduler is the part of the scheduler the strategy API drives
+type StrategyScheduler interface {
+	// ScheduleStrategies re-registers the jobs after a config change
+	ScheduleStrategies()
+	// Spec is the cron spec a strategy runs on
+	Spec(cfg model.StrategyConfig) (string, error)
+	NextRun(name string) (time.Time, bool)
+}
+
+// strategyView is a strategy plug-in with its config
+type strategyView struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	// Schedule and Placement are as configured (empty = default), the
+	// Effective ones are what the scheduler uses
+	Schedule           string            `json:"schedule"`
+	EffectiveSchedule  string            `json:"effective_schedule"`
+	Placement          string            `json:"placement"`
+	EffectivePlacement service.Placement `json:"effective_placement"`
+	Params             json.RawMessage   `json:"params,omitempty"`
+	NextRun            *time.Time        `json:"next_run"`
+}
+
+func (h *Handler) strategyView(p service.StrategyPlugin, cfg model.StrategyConfig) strategyView {
+	v := strategyView{
+		Name:               p.Name(),
+		Description:        p.Describe(),
+		Enabled:            cfg.Enabled,
+		Schedule:           cfg.Schedule,
+		Placement:          cfg.Placement,
+		EffectivePlacement: h.Strategy.PlacementFor(cfg),
+	}
+	if cfg.Params != "" {
+		v.Params = json.RawMessage(cfg.Params)
+	}
+	if h.Scheduler != nil {
+		v.EffectiveSchedule, _ = h.Scheduler.Spec(cfg)
+		if next, ok := h.Scheduler.NextRun(p.Name()); ok {
+			v.NextRun = &next
+		}
+	}
+	return v
+}
+
+// strategyError maps an unknown strategy to 404, anything else like a broker error
+func strategyError(c *gin.Context, err error, extra gin.H) {
+	if errors.Is(err, service.ErrUnknownStrategy) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	respondError(c, err, extra)
+}
+
+// GetStrategies API: GET /api/strategies
+// Every registered strategy with its config and next scheduled run
+func (h *Handler) GetStrategies(c *gin.Context) {
+	out := []strategyView{}
+	for _, p := range h.Strategy.Plugins.List() {
+		cfg, err := h.Strategy.StrategyConfig(p.Name())
+		if err != nil {
+			strategyError(c, err, nil)
+			return
+		}
+		out = append(out, h.strategyView(p, cfg))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetStrategy API: GET /api/strategies/:name
+// Config and current state of one strategy
+func (h *Handler) GetStrategy(c *gin.Context) {
+	name := c.Param("name")
+	p, ok := h.Strategy.Plugins.Get(name)
+	if !ok {
+		strategyError(c, service.ErrUnknownStrategy, nil)
+		return
+	}
+	cfg, err := h.Strategy.StrategyConfig(name)
+	if err != nil {
+		strategyError(c, err, nil)
+		return
+	}
+	state, err := h.Strategy.StrategyState(c.Request.Context(), name)
+	if err != nil {
+		strategyError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strategy": h.strategyView(p, cfg), "state": state})
+}
+
+// UpdateStrategy API: POST /api/strategies/:name
+// Body: {"enabled": true, "schedule": "50 15 * * 1-5", "placement": "reserve", "params": {...}}
+// Omitted fields keep their value; an empty schedule or placement restores the default.
+func (h *Handler) UpdateStrategy(c *gin.Context) {
+	name := c.Param("name")
+	p, ok := h.Strategy.Plugins.Get(name)
+	if !ok {
+		strategyError(c, service.ErrUnknownStrategy, nil)
+		return
+	}
+	var input struct {
+		Enabled   *bool           `json:"enabled"`
+		Schedule  *string         `json:"schedule"`
+		Placement *string         `json:"placement"`
+		Params    json.RawMessage `json:"params"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg, err := h.Strategy.StrategyConfig(name)
+	if err != nil {
+		strategyError(c, err, nil)
+		return
+	}
+	if input.Enabled != nil {
+		cfg.Enabled = *input.Enabled
+	}
+	if input.Schedule != nil {
+		cfg.Schedule = *input.Schedule
+	}
+	if input.Placement != nil {
+		cfg.Placement = *input.Placement
+	}
+	if input.Params != nil {
+		cfg.Params = string(input.Params)
+		if cfg.Params == "null" {
+			cfg.Params = ""
+		}
+	}
+	cfg, err = h.Strategy.SaveStrategyConfig(cfg)
+	if err != nil {
+		strategyError(c, err, nil)
+		return
+	}
+
+	if h.Scheduler != nil {
+		h.Scheduler.ScheduleStrategies()
+	}
+	log.Printf("[API] Strategy %s updated", name)
+	c.JSON(http.StatusOK, h.strategyView(p, cfg))
+}
+
+// EvaluateStrategy API: GET /api/strategies/:name/evaluate
+// The orders the strategy would place now; nothing is sent
+func (h *Handler) EvaluateStrategy(c *gin.Context) {
+	prop, err := h.Strategy.EvaluateStrategy(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		strategyError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusOK, prop)
+}
+
+// ExecuteStrategy API: POST /api/strategies/:name/execute?dry_run=true
+// Runs the strategy now with its configured placement
+func (h *Handler) ExecuteStrategy(c *gin.Context) {
+	name := c.Param("name")
+	dryRun := c.Query("dry_run") == "true"
+
+	if err := h.Strategy.RunStrategy(c.Request.Context(), name, dryRun); err != nil {
+		strategyError(c, err, gin.H{"dry_run": dryRun})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "executed", "strategy": name, "dry_run": dryRun})
+}
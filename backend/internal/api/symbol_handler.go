@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSymbols API: GET /api/symbols
+// Lists the symbol master and where it was loaded from
+func (h *Handler) GetSymbols(c *gin.Context) {
+	master := h.Strategy.Symbols
+	c.JSON(http.StatusOK, gin.H{
+		"count":   master.Len(),
+		"sources": master.Sources(),
+		"symbols": master.All(),
+	})
+}
+
+// GetSymbol API: GET /api/symbols/:symbol
+func (h *Handler) GetSymbol(c *gin.Context) {
+	sym, err := h.Strategy.Symbols.Lookup(c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "category": "VALIDATION"})
+		return
+	}
+	c.JSON(http.StatusOK, sym)
+}
@@ -0,0 +1,318 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Exchange is a broker-neutral US listing venue.
+// Adapters translate it into their own codes (e.g. KIS uses NAS/NASD for quotes/orders).
+type Exchange string
+
+const (
+	ExchangeNASDAQ Exchange = "NASDAQ"
+	ExchangeNYSE   Exchange = "NYSE"
+	ExchangeAMEX   Exchange = "AMEX"
+)
+
+type Side string
+
+const (
+	SideBuy  Side = "BUY"
+	SideSell Side = "SELL"
+)
+
+type OrderType string
+
+const (
+	OrderTypeLimit  OrderType = "LIMIT"
+	OrderTypeMarket OrderType = "MARKET"
+	// Closing auction: on-close orders trade only at the official close,
+	// LOC when the close is at or better than the limit
+	OrderTypeLOC OrderType = "LOC"
+	OrderTypeMOC OrderType = "MOC"
+	// Opening auction
+	OrderTypeLOO OrderType = "LOO"
+	OrderTypeMOO OrderType = "MOO"
+)
+
+// HasLimit reports whether the order carries a limit price
+func (t OrderType) HasLimit() bool {
+	switch t {
+	case OrderTypeLimit, OrderTypeLOC, OrderTypeLOO:
+		return true
+	}
+	return false
+}
+
+// OnClose reports whether the order trades in the closing auction
+func (t OrderType) OnClose() bool {
+	return t == OrderTypeLOC || t == OrderTypeMOC
+}
+
+// OnOpen reports whether the order trades in the opening auction
+func (t OrderType) OnOpen() bool {
+	return t == OrderTypeLOO || t == OrderTypeMOO
+}
+
+// Valid reports whether t is a known order type
+func (t OrderType) Valid() bool {
+	switch t {
+	case OrderTypeLimit, OrderTypeMarket, OrderTypeLOC, OrderTypeMOC, OrderTypeLOO, OrderTypeMOO:
+		return true
+	}
+	return false
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusSubmitted       OrderStatus = "SUBMITTED"
+	OrderStatusPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderStatusFilled          OrderStatus = "FILLED"
+	OrderStatusCancelled       OrderStatus = "CANCELLED"
+	OrderStatusRejected        OrderStatus = "REJECTED"
+	// OrderStatusUnknown marks an order whose submission may or may not have
+	// reached the broker. It has no order ID until the poller finds it in the
+	// broker's order list (OrderResolver).
+	OrderStatusUnknown OrderStatus = "UNKNOWN"
+	// OrderStatusReserved marks a reservation order queued at the broker for
+	// the next session. It becomes SUBMITTED once the broker sends it.
+	OrderStatusReserved OrderStatus = "RESERVED"
+)
+
+// ErrNotSupported is returned when an adapter cannot serve a call.
+var ErrNotSupported = errors.New("operation not supported by broker")
+
+// ErrOutcomeUnknown is returned by PlaceOrder when the request may have been
+// accepted even though no response arrived. Callers must not resubmit blindly.
+var ErrOutcomeUnknown = NewError(CategoryUnavailable, "order outcome unknown")
+
+// Position is a single holding in the account
+type Position struct {
+	Symbol       string   `json:"symbol"`
+	Exchange     Exchange `json:"exchange"`
+	Qty          int      `json:"qty"`
+	AvgPrice     float64  `json:"avg_price"`
+	CurrentPrice float64  `json:"current_price"`
+}
+
+// Balance is the account snapshot: holdings plus summary figures (USD)
+type Balance struct {
+	Positions       []Position `json:"positions"`
+	TotalPurchase   float64    `json:"total_purchase"`   // Invested amount
+	TotalEvaluation float64    `json:"total_evaluation"` // Evaluation amount
+	TotalPL         float64    `json:"total_pl"`
+	TotalPLRate     float64    `json:"total_pl_rate"` // Percent
+	RealizedPL      float64    `json:"realized_pl"`
+}
+
+// Position returns the holding for symbol, if any
+func (b *Balance) Position(symbol string) (Position, bool) {
+	for _, p := range b.Positions {
+		if p.Symbol == symbol {
+			return p, true
+		}
+	}
+	return Position{}, false
+}
+
+// BuyingPower is what the account can spend on one symbol at a limit price
+type BuyingPower struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`   // Price the quantity was computed at
+	Cash   float64 `json:"cash"`    // USD available for new orders
+	MaxQty int     `json:"max_qty"` // Shares orderable at Price
+}
+
+// Period is the granularity of history bars
+type Period string
+
+const (
+	PeriodDay   Period = "DAY"
+	PeriodWeek  Period = "WEEK"
+	PeriodMonth Period = "MONTH"
+)
+
+// HistoryRequest selects bars newest first, back to Start and/or up to Limit rows.
+// At least one of Start and Limit must be set.
+type HistoryRequest struct {
+	Exchange Exchange `json:"exchange"`
+	Symbol   string   `json:"symbol"`
+	Period   Period   `json:"period"`   // Default PeriodDay
+	Adjusted bool     `json:"adjusted"` // Adjust for splits and dividends
+	Start    string   `json:"start"`    // Oldest date to include (YYYYMMDD)
+	End      string   `json:"end"`      // Newest date to include (YYYYMMDD), empty = latest
+	Limit    int      `json:"limit"`    // Max rows, 0 = no limit
+}
+
+// Bar is one history row. Weekly and monthly bars are dated by the adapter's convention.
+type Bar struct {
+	Date     string  `json:"date"` // YYYYMMDD
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   int64   `json:"volume"`
+	Adjusted bool    `json:"adjusted"`
+}
+
+type OrderRequest struct {
+	Exchange Exchange  `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Side     Side      `json:"side"`
+	Type     OrderType `json:"type"`
+	Qty      int       `json:"qty"`
+	Price    float64   `json:"price"` // Limit price, ignored for MARKET
+}
+
+// Order is an order as known by the broker
+type Order struct {
+	ID string `json:"id"` // Broker order number
+	OrderRequest
+	Status       OrderStatus `json:"status"`
+	FilledQty    int         `json:"filled_qty"`
+	AvgFillPrice float64     `json:"avg_fill_price"`
+	SubmittedAt  time.Time   `json:"submitted_at"`
+}
+
+// Broker is the account and market surface the strategy trades against.
+// Calls that reach the broker take a ctx; cancelling it aborts the request.
+type Broker interface {
+	// Name identifies the adapter (e.g. "kis")
+	Name() string
+	// Mode tells where orders go: "real" (live account), "virtual" (broker sandbox) or "paper" (local simulation)
+	Mode() string
+
+	GetBalance(ctx context.Context) (*Balance, error)
+	// GetCash returns the USD amount available for new orders
+	GetCash(ctx context.Context) (float64, error)
+	// GetBuyingPower returns the max quantity of symbol orderable at price
+	// (0 = current price), as the broker will check it
+	GetBuyingPower(ctx context.Context, exch Exchange, symbol string, price float64) (*BuyingPower, error)
+	GetQuote(ctx context.Context, exch Exchange, symbol string) (float64, error)
+	// GetHistory returns OHLCV bars newest first
+	GetHistory(ctx context.Context, req HistoryRequest) ([]Bar, error)
+
+	PlaceOrder(ctx context.Context, req OrderRequest) (*Order, error)
+	GetOrderStatus(ctx context.Context, orderID string) (*Order, error)
+	// CancelOrder cancels the unfilled remainder of o
+	CancelOrder(ctx context.Context, o Order) error
+	// AmendOrder changes quantity and limit price of o. The returned order may
+	// carry a new ID when the broker books the revision as a new order.
+	AmendOrder(ctx context.Context, o Order, qty int, price float64) (*Order, error)
+}
+
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "PENDING"   // Waiting for the session open
+	ReservationSent      ReservationStatus = "SENT"      // Forwarded as a regular order (OrderID)
+	ReservationCancelled ReservationStatus = "CANCELLED" // Cancelled before the open
+	ReservationRejected  ReservationStatus = "REJECTED"  // Not forwarded (Message has the reason)
+)
+
+// Reservation is an order queued outside market hours
+type Reservation struct {
+	ID string `json:"id"` // Broker reservation number
+	OrderRequest
+	Status     ReservationStatus `json:"status"`
+	OrderID    string            `json:"order_id"` // Live order once sent
+	Message    string            `json:"message"`
+	ReservedAt time.Time         `json:"reserved_at"`
+}
+
+// Reserver is implemented by brokers that take reservation orders: orders
+// entered outside the session and sent as regular orders at the next open.
+type Reserver interface {
+	PlaceReservation(ctx context.Context, req OrderRequest) (*Reservation, error)
+	// GetReservations lists the reservations made since since
+	GetReservations(ctx context.Context, since time.Time) ([]Reservation, error)
+	CancelReservation(ctx context.Context, r Reservation) error
+}
+
+// RealizedPL is the broker's realized profit of one symbol on one trade date (USD)
+type RealizedPL struct {
+	Date         string   `json:"date"` // YYYYMMDD (US trade date)
+	Exchange     Exchange `json:"exchange"`
+	Symbol       string   `json:"symbol"`
+	Qty          int      `json:"qty"` // Shares sold
+	AvgBuyPrice  float64  `json:"avg_buy_price"`
+	AvgSellPrice float64  `json:"avg_sell_price"`
+	BuyAmount    float64  `json:"buy_amount"`
+	SellAmount   float64  `json:"sell_amount"`
+	Fee          float64  `json:"fee"`
+	Profit       float64  `json:"profit"` // After fees
+	ExchangeRate float64  `json:"exchange_rate"`
+}
+
+// Execution is one fill from the broker's trade history
+type Execution struct {
+	Date       string  `json:"date"`        // YYYYMMDD (US trade date)
+	SettleDate string  `json:"settle_date"` // YYYYMMDD
+	Symbol     string  `json:"symbol"`
+	Side       Side    `json:"side"`
+	Qty        int     `json:"qty"`
+	Price      float64 `json:"price"`
+	Amount     float64 `json:"amount"`
+	Fee        float64 `json:"fee"`
+}
+
+// ProfitReporter is implemented by brokers that report realized profit and
+// trade history for a date range (YYYYMMDD, both ends included).
+type ProfitReporter interface {
+	GetRealizedPL(ctx context.Context, start, end string) ([]RealizedPL, error)
+	GetExecutions(ctx context.Context, start, end string) ([]Execution, error)
+}
+
+// FXRate is the KRW price of one USD as applied by the broker
+type FXRate struct {
+	Rate float64 `json:"rate"` // KRW per USD
+	Date string  `json:"date"` // YYYYMMDD the rate was posted (KST)
+}
+
+// FXQuoter is implemented by brokers that quote the USD/KRW rate they
+// settle the account at.
+type FXQuoter interface {
+	GetFXRate(ctx context.Context) (*FXRate, error)
+}
+
+// Quote is a realtime price update. Fields the update does not carry are 0.
+type Quote struct {
+	Exchange Exchange  `json:"exchange"`
+	Symbol   string    `json:"symbol"`
+	Last     float64   `json:"last"` // Last trade price
+	Bid      float64   `json:"bid"`
+	Ask      float64   `json:"ask"`
+	BidSize  int64     `json:"bid_size"`
+	AskSize  int64     `json:"ask_size"`
+	Volume   int64     `json:"volume"`   // Session volume
+	Time     time.Time `json:"time"`     // Exchange time of the update
+	Received time.Time `json:"received"` // When the update arrived
+}
+
+// QuoteStreamer is implemented by brokers with a realtime quote feed.
+type QuoteStreamer interface {
+	// StreamQuotes runs the feed until ctx ends, calling sink for every
+	// update. It reconnects and resubscribes on its own.
+	StreamQuotes(ctx context.Context, sink func(Quote)) error
+	// SubscribeQuotes adds symbol to the feed, also while streaming
+	SubscribeQuotes(exch Exchange, symbol string) error
+	// UnsubscribeQuotes drops symbol from the feed
+	UnsubscribeQuotes(exch Exchange, symbol string) error
+}
+
+// OrderResolver is implemented by brokers that can settle orders placed with
+// an unknown outcome (ErrOutcomeUnknown)
+type OrderResolver interface {
+	// ListOrders returns the orders submitted since since, open and done
+	ListOrders(ctx context.Context, since time.Time) ([]Order, error)
+	// ClearOutcomeUnknown lifts the duplicate guard of req once its outcome is known
+	ClearOutcomeUnknown(req OrderRequest)
+}
+
+// TokenRefresher is implemented by brokers holding an expiring session token.
+type TokenRefresher interface {
+	ForceRefresh(ctx context.Context) error
+}
@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrorCategory groups broker failures by what the caller can do about them
+type ErrorCategory string
+
+const (
+	CategoryAuth         ErrorCategory = "AUTH"          // Credentials or token rejected
+	CategoryRateLimit    ErrorCategory = "RATE_LIMIT"    // Too many requests, retry later
+	CategoryMarketClosed ErrorCategory = "MARKET_CLOSED" // Outside trading hours / holiday
+	CategoryFunds        ErrorCategory = "FUNDS"         // Not enough cash or sellable quantity
+	CategoryValidation   ErrorCategory = "VALIDATION"    // Bad symbol, price, quantity or parameters
+	CategoryUnavailable  ErrorCategory = "UNAVAILABLE"   // Network failure, server error or unknown outcome
+	CategoryConflict     ErrorCategory = "CONFLICT"      // Duplicate of an order whose outcome is still unknown
+	CategoryUnknown      ErrorCategory = "UNKNOWN"
+)
+
+// Categorized is implemented by errors that know their category
+type Categorized interface {
+	error
+	ErrorCategory() ErrorCategory
+}
+
+// CategoryOf returns the category of the first categorized error in err's chain
+func CategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	var c Categorized
+	if errors.As(err, &c) {
+		return c.ErrorCategory()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return CategoryUnavailable
+	}
+	return CategoryUnknown
+}
+
+// Error is a plain categorized error for adapters without richer error data
+type Error struct {
+	Category ErrorCategory
+	Message  string
+}
+
+func NewError(category ErrorCategory, format string, args ...interface{}) *Error {
+	return &Error{Category: category, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) ErrorCategory() ErrorCategory {
+	return e.Category
+}
@@ -0,0 +1,105 @@
+package broker
+
+import (
+	"sync"
+	"time"
+)
+
+// US equity sessions in minutes after midnight ET
+const (
+	PreMarketOpenMin = 4 * 60
+	RegularOpenMin   = 9*60 + 30
+	RegularCloseMin  = 16 * 60
+)
+
+// closingCutoffMin is the last minute each exchange accepts on-close orders
+// (NYSE 15:50, Nasdaq 15:55, NYSE Arca 15:59). Unknown venues use the earliest.
+var closingCutoffMin = map[Exchange]int{
+	ExchangeNYSE:   15*60 + 50,
+	ExchangeNASDAQ: 15*60 + 55,
+	ExchangeAMEX:   15*60 + 59,
+}
+
+var (
+	easternOnce sync.Once
+	eastern     *time.Location
+)
+
+// Eastern returns America/New_York, falling back to a fixed EST offset when
+// the zone database is missing
+func Eastern() *time.Location {
+	easternOnce.Do(func() {
+		loc, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			loc = time.FixedZone("EST", -5*60*60)
+		}
+		eastern = loc
+	})
+	return eastern
+}
+
+func cutoffMin(exch Exchange) int {
+	if m, ok := closingCutoffMin[exch]; ok {
+		return m
+	}
+	return closingCutoffMin[ExchangeNYSE]
+}
+
+// EarliestClosingCutoff is the first on-close cutoff of the known exchanges in
+// minutes after midnight ET; a job placing on-close orders for any venue has
+// to be done by then
+func EarliestClosingCutoff() int {
+	earliest := RegularCloseMin
+	for _, m := range closingCutoffMin {
+		earliest = min(earliest, m)
+	}
+	return earliest
+}
+
+// ClosingCutoff returns the on-close order cutoff of exch on the day of now
+func ClosingCutoff(exch Exchange, now time.Time) time.Time {
+	min := cutoffMin(exch)
+	et := now.In(Eastern())
+	return time.Date(et.Year(), et.Month(), et.Day(), min/60, min%60, 0, 0, et.Location())
+}
+
+// CheckOrderWindow rejects auction orders entered outside the time they can
+// still reach their session. Plain limit and market orders
+// are left to the broker. Exchange holidays are not known here.
+func CheckOrderWindow(t OrderType, exch Exchange, now time.Time) error {
+	if !t.OnClose() && !t.OnOpen() {
+		return nil
+	}
+
+	et := now.In(Eastern())
+	if et.Weekday() == time.Saturday || et.Weekday() == time.Sunday {
+		return NewError(CategoryMarketClosed, "%s orders cannot be entered on weekends", t)
+	}
+	m := et.Hour()*60 + et.Minute()
+
+	switch {
+	case t.OnClose():
+		cutoff := cutoffMin(exch)
+		if m < PreMarketOpenMin || m >= cutoff {
+			return NewError(CategoryMarketClosed, "%s orders for %s are accepted 04:00-%02d:%02d ET (now %s)",
+				t, exch, cutoff/60, cutoff%60, et.Format("15:04"))
+		}
+	case t.OnOpen():
+		if m < PreMarketOpenMin || m >= RegularOpenMin {
+			return NewError(CategoryMarketClosed, "%s orders are accepted 04:00-09:30 ET before the open (now %s)",
+				t, et.Format("15:04"))
+		}
+	}
+	return nil
+}
+
+// InRegularSession reports whether now falls in the regular session of a
+// weekday (exchange holidays are not known here)
+func InRegularSession(now time.Time) bool {
+	et := now.In(Eastern())
+	if et.Weekday() == time.Saturday || et.Weekday() == time.Sunday {
+		return false
+	}
+	m := et.Hour()*60 + et.Minute()
+	return m >= RegularOpenMin && m < RegularCloseMin
+}
@@ -0,0 +1,312 @@
+// Package cassette records broker HTTP traffic into fixture files and replays
+// it deterministically. A Recorder is an http.RoundTripper, so it plugs into
+// kis.Client.Client and the Alpaca marketdata client without changing them.
+//
+// Credentials, tokens and account numbers are scrubbed before anything is
+// written, so cassettes can be committed as test fixtures.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects what a Recorder does with requests
+type Mode string
+
+const (
+	ModeOff    Mode = ""       // Pass through untouched
+	ModeRecord Mode = "record" // Forward to the network and append each exchange to the cassette
+	ModeReplay Mode = "replay" // Serve recorded responses, never touch the network
+)
+
+// ParseMode validates a CASSETTE_MODE value ("off" is the same as empty)
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "off":
+		return ModeOff, nil
+	case "record":
+		return ModeRecord, nil
+	case "replay":
+		return ModeReplay, nil
+	}
+	return ModeOff, fmt.Errorf("invalid cassette mode %q (must be \"record\", \"replay\" or empty)", s)
+}
+
+// Interaction is one recorded request/response pair
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+// Cassette is the on-disk fixture format
+type Cassette struct {
+	Name         string        `json:"name"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette through a temp file so a crash never leaves half a fixture
+func (c *Cassette) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Recorder is an http.RoundTripper that records to or replays from one cassette
+type Recorder struct {
+	mode     Mode
+	path     string
+	next     http.RoundTripper
+	scrubber *Scrubber
+
+	mu       sync.Mutex
+	cassette *Cassette
+	served   map[string]int // Replay: how many interactions per key were already served
+}
+
+// New creates a recorder for the cassette at path. In replay mode the file
+// must exist; in record mode it is started from scratch and rewritten after
+// every exchange. secrets are literal values (keys, account numbers) that are
+// scrubbed wherever they appear.
+func New(mode Mode, path string, secrets ...string) (*Recorder, error) {
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		next:     http.DefaultTransport,
+		scrubber: NewScrubber(secrets...),
+		served:   make(map[string]int),
+	}
+
+	switch mode {
+	case ModeReplay:
+		c, err := Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("replay cassette: %w", err)
+		}
+		r.cassette = c
+		log.Printf("[CASSETTE] Replaying %d interactions from %s", len(c.Interactions), path)
+	case ModeRecord:
+		r.cassette = &Cassette{
+			Name:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			RecordedAt: time.Now().UTC(),
+		}
+		log.Printf("[CASSETTE] Recording to %s", path)
+	}
+	return r, nil
+}
+
+// Wrap sets the transport used in record and pass-through mode (default http.DefaultTransport)
+func (r *Recorder) Wrap(next http.RoundTripper) *Recorder {
+	if next != nil {
+		r.next = next
+	}
+	return r
+}
+
+// Cassette returns a copy of the interactions recorded or loaded so far
+func (r *Recorder) Cassette() Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cassette == nil {
+		return Cassette{}
+	}
+	c := *r.cassette
+	c.Interactions = append([]Interaction(nil), r.cassette.Interactions...)
+	return c
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch r.mode {
+	case ModeReplay:
+		return r.replay(req)
+	case ModeRecord:
+		return r.record(req)
+	default:
+		return r.next.RoundTrip(req)
+	}
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		// Transport failures are not recorded; replay only serves real answers
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     r.scrubber.URL(req.URL.String()),
+			Headers: r.scrubber.Headers(req.Header),
+			Body:    r.scrubber.Body(reqBody),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: r.scrubber.Headers(resp.Header),
+			Body:    r.scrubber.Body(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	if err := r.cassette.Save(r.path); err != nil {
+		log.Printf("[CASSETTE] ✗ Failed to save %s: %v", r.path, err)
+	}
+	return resp, nil
+}
+
+// replay serves the next unserved interaction with the same key. Once a key
+// is exhausted its last response is repeated, so polling loops stay stable.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := r.key(req.Method, req.URL.String(), req.Header, reqBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []*Interaction
+	for i := range r.cassette.Interactions {
+		in := &r.cassette.Interactions[i]
+		if r.key(in.Request.Method, in.Request.URL, in.Request.Headers, []byte(in.Request.Body)) == key {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", r.path, req.Method, r.scrubber.URL(req.URL.String()))
+	}
+
+	n := r.served[key]
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	r.served[key]++
+	return matches[n].Response.toHTTP(req), nil
+}
+
+// matchHeaders are the request headers that select a different KIS response
+// for the same URL (TR ID and continuation flag)
+var matchHeaders = []string{"tr_id", "tr_cont"}
+
+// key identifies a request by method, scrubbed path and query, the
+// selecting headers and the scrubbed body
+func (r *Recorder) key(method, rawURL string, header map[string][]string, body []byte) string {
+	var b strings.Builder
+	b.WriteString(method)
+	b.WriteString(" ")
+	b.WriteString(canonicalURL(r.scrubber.URL(rawURL)))
+	h := http.Header(header)
+	for _, name := range matchHeaders {
+		if v := h.Get(name); v != "" {
+			fmt.Fprintf(&b, " %s=%s", name, v)
+		}
+	}
+	b.WriteString(" ")
+	b.WriteString(r.scrubber.Body(body))
+	return b.String()
+}
+
+func (rr RecordedResponse) toHTTP(req *http.Request) *http.Response {
+	header := make(http.Header, len(rr.Headers))
+	for k, v := range rr.Headers {
+		header[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rr.Status, http.StatusText(rr.Status)),
+		StatusCode:    rr.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
+
+// readBody drains *body and replaces it with a re-readable copy
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// canonicalURL drops scheme and host and sorts query parameters, so a
+// cassette replays against any base URL and parameter order never breaks a match
+func canonicalURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		raw = u.RequestURI()
+	}
+	path, query, ok := strings.Cut(raw, "?")
+	if !ok {
+		return raw
+	}
+	params := strings.Split(query, "&")
+	sort.Strings(params)
+	return path + "?" + strings.Join(params, "&")
+}
@@ -0,0 +1,134 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testAppKey    = "appkey123456"
+	testAppSecret = "secret123456"
+	testAccount   = "12345678"
+	testToken     = "token-abcdef-987654"
+)
+
+// kisLike answers a token request and a TR-dependent inquiry the way KIS does
+func kisLike(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth2/tokenP":
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":86400}`, testToken)
+		case "/inquire":
+			fmt.Fprintf(w, `{"rt_cd":"0","tr":%q,"cano":%q}`, r.Header.Get("tr_id"), r.URL.Query().Get("CANO"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func do(t *testing.T, client *http.Client, method, url, trID, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("authorization", "Bearer "+testToken)
+	req.Header.Set("appkey", testAppKey)
+	req.Header.Set("appsecret", testAppSecret)
+	if trID != "" {
+		req.Header.Set("tr_id", trID)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	srv := kisLike(t)
+	path := filepath.Join(t.TempDir(), "kis.json")
+
+	rec, err := New(ModeRecord, path, testAppKey, testAppSecret, testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: rec}
+	tokenBody := fmt.Sprintf(`{"grant_type":"client_credentials","appkey":%q,"appsecret":%q}`, testAppKey, testAppSecret)
+	do(t, client, "POST", srv.URL+"/oauth2/tokenP", "", tokenBody)
+	_, balance := do(t, client, "GET", srv.URL+"/inquire?CANO="+testAccount+"&ACNT_PRDT_CD=01", "TTTS3012R", "")
+	_, history := do(t, client, "GET", srv.URL+"/inquire?CANO="+testAccount+"&ACNT_PRDT_CD=01", "TTTS3035R", "")
+	srv.Close()
+
+	// Nothing secret reaches the file
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{testAppKey, testAppSecret, testAccount, testToken} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+	if !strings.Contains(string(raw), Redacted) {
+		t.Error("cassette has no redacted values")
+	}
+
+	// Replay against a dead base URL with the query in another order
+	replay, err := New(ModeReplay, path, testAppKey, testAppSecret, testAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replay}
+	base := "http://127.0.0.1:1"
+
+	status, body := do(t, client, "POST", base+"/oauth2/tokenP", "", tokenBody)
+	var tok map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &tok); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || tok["access_token"] != Redacted || tok["token_type"] != "Bearer" {
+		t.Errorf("replayed token: %d %s", status, body)
+	}
+
+	// The TR ID selects between responses recorded for the same URL
+	url := base + "/inquire?ACNT_PRDT_CD=01&CANO=" + testAccount
+	if _, got := do(t, client, "GET", url, "TTTS3035R", ""); !strings.Contains(got, `"tr":"TTTS3035R"`) {
+		t.Errorf("replayed history = %s, recorded %s", got, history)
+	}
+	if _, got := do(t, client, "GET", url, "TTTS3012R", ""); !strings.Contains(got, `"tr":"TTTS3012R"`) {
+		t.Errorf("replayed balance = %s, recorded %s", got, balance)
+	}
+	// An exhausted key repeats its last response
+	if _, got := do(t, client, "GET", url, "TTTS3012R", ""); !strings.Contains(got, `"tr":"TTTS3012R"`) {
+		t.Errorf("repeated balance = %s", got)
+	}
+
+	// A request never recorded fails instead of reaching the network
+	req, _ := http.NewRequest("GET", base+"/inquire?CANO=other", nil)
+	if _, err := client.Do(req); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("unrecorded request: err = %v", err)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	a := canonicalURL("https://openapi.koreainvestment.com:9443/x?b=2&a=1")
+	b := canonicalURL("http://127.0.0.1:8080/x?a=1&b=2")
+	if a != b || a != "/x?a=1&b=2" {
+		t.Errorf("canonicalURL = %q and %q, want /x?a=1&b=2", a, b)
+	}
+}
@@ -0,0 +1,31 @@
+package cassette
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+)
+
+// FromConfig returns the transport for the named cassette (e.g. "kis",
+// "alpaca") under CASSETTE_DIR, or nil when CASSETTE_MODE is off.
+// All configured credentials are scrubbed from the recording.
+func FromConfig(cfg *config.Config, name string) (http.RoundTripper, error) {
+	mode, err := ParseMode(cfg.CassetteMode)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeOff {
+		return nil, nil
+	}
+
+	rec, err := New(mode, filepath.Join(cfg.CassetteDir, name+".json"), cfg.Secrets()...)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeRecord {
+		log.Printf("[CASSETTE] ⚠ %s traffic is being recorded; review %s before committing it", name, cfg.CassetteDir)
+	}
+	return rec, nil
+}
@@ -0,0 +1,162 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces every scrubbed value
+const Redacted = "REDACTED"
+
+// sensitiveHeaders are credential headers sent to KIS and Alpaca
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Appkey":              true,
+	"Appsecret":           true,
+	"Apca-Api-Key-Id":     true,
+	"Apca-Api-Secret-Key": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// droppedHeaders change on every call and would only add noise to fixtures
+var droppedHeaders = map[string]bool{
+	"Date":            true,
+	"Content-Length":  true,
+	"X-Request-Id":    true,
+	"Accept-Encoding": true,
+}
+
+// sensitiveFields are JSON body keys and query parameters holding secrets
+// (KIS token request/response, websocket approval key, account number)
+var sensitiveFields = map[string]bool{
+	"appkey":       true,
+	"appsecret":    true,
+	"secretkey":    true,
+	"access_token": true,
+	"approval_key": true,
+	"cano":         true,
+}
+
+// minSecretLen keeps short values like the "01" account product code from
+// being replaced everywhere they happen to appear
+const minSecretLen = 6
+
+// Scrubber removes credentials from recorded traffic
+type Scrubber struct {
+	secrets []string
+}
+
+// NewScrubber also replaces the given literal values (app keys, account
+// numbers) anywhere in URLs and bodies
+func NewScrubber(secrets ...string) *Scrubber {
+	s := &Scrubber{}
+	for _, v := range secrets {
+		if len(v) >= minSecretLen {
+			s.secrets = append(s.secrets, v)
+		}
+	}
+	return s
+}
+
+func (s *Scrubber) literals(v string) string {
+	for _, secret := range s.secrets {
+		v = strings.ReplaceAll(v, secret, Redacted)
+	}
+	return v
+}
+
+// Headers returns a scrubbed copy of h
+func (s *Scrubber) Headers(h map[string][]string) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(h))
+	for k, vals := range h {
+		name := http.CanonicalHeaderKey(k)
+		if droppedHeaders[name] {
+			continue
+		}
+		cp := make([]string, len(vals))
+		for i, v := range vals {
+			if sensitiveHeaders[name] {
+				cp[i] = Redacted
+			} else {
+				cp[i] = s.literals(v)
+			}
+		}
+		out[name] = cp
+	}
+	return out
+}
+
+// URL scrubs sensitive query parameters
+func (s *Scrubber) URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return s.literals(raw)
+	}
+	q := u.Query()
+	changed := false
+	for k := range q {
+		if sensitiveFields[strings.ToLower(k)] {
+			q.Set(k, Redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+	return s.literals(u.String())
+}
+
+// Body scrubs sensitive JSON fields at any depth, then literal secrets.
+// Bodies without sensitive fields keep their original bytes.
+func (s *Scrubber) Body(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	out := body
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err == nil && scrubValue(v) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err == nil {
+			out = bytes.TrimRight(buf.Bytes(), "\n")
+		}
+	}
+	return s.literals(string(out))
+}
+
+// scrubValue redacts sensitive keys in place and reports whether anything changed
+func scrubValue(v interface{}) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if sensitiveFields[strings.ToLower(k)] {
+				if t[k] != Redacted {
+					t[k] = Redacted
+					changed = true
+				}
+				continue
+			}
+			if scrubValue(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if scrubValue(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
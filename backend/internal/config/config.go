@@ -3,16 +3,41 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
 	KisAppKey     string
 	KisAppSecret  string
 	KisAccountNum string
-	KisBaseURL    string // Real: https://openapi.koreainvestment.com:9443, Virtual: https://openapivts.koreainvestment.com:29443
-	ScheduleTime  string // HH:MM (Time in ET to execute daily strategy)
+	KisBaseURL    string  // Real: https://openapi.koreainvestment.com:9443, Virtual: https://openapivts.koreainvestment.com:29443
+	KisMode       string  // "real" or "virtual", must match KisBaseURL
+	KisRateLimit  float64 // Requests per second, 0 = KIS quota for the mode
+	KisWSURL      string  // Realtime WebSocket endpoint, empty = default of KisMode
+	ScheduleTime  string  // HH:MM (Time in ET to execute daily strategy), before the NYSE 15:50 on-close cutoff
 	AlpacaApiKey  string
 	AlpacaSecret  string
+
+	Broker           string  // "kis" (default) or "paper"
+	PaperInitialCash float64 // Starting USD cash for a new paper ledger
+	PaperLedgerPath  string
+
+	CassetteMode string // "" (off), "record" or "replay" broker HTTP traffic
+	CassetteDir  string
+
+	SymbolMaster []string // Extra symbol master files (JSON, CSV or KIS .cod/.zip) over the built-ins
+
+	OrderPlacement string // "live" (default) or "reserve": queue strategy orders as reservation orders
+	ReserveTime    string // HH:MM ET the evening before the rebalance day to queue reservations
+
+	FXRate float64 // USD/KRW used when the broker has not quoted a rate, 0 = none
+
+	RealtimeQuotes bool // Subscribe the broker's realtime quote feed (KIS WebSocket)
+
+	CredentialDir     string // Credential store (broker tokens, optional API keys)
+	CredentialKey     string // Master key encrypting the store, empty = plaintext files
+	CredentialKeyFile string // File holding the master key, used when CredentialKey is empty
 }
 
 func Load() *Config {
@@ -21,12 +46,44 @@ func Load() *Config {
 		KisAppSecret:  getEnv("KIS_APP_SECRET", ""),
 		KisAccountNum: getEnv("KIS_ACCOUNT_NUM", ""),
 		KisBaseURL:    getEnv("KIS_BASE_URL", "https://openapi.koreainvestment.com:9443"),
-		ScheduleTime:  getEnv("SCHEDULE_TIME", "15:50"),
+		KisMode:       getEnv("KIS_MODE", "real"),
+		KisRateLimit:  getEnvFloat("KIS_RATE_LIMIT", 0),
+		KisWSURL:      os.Getenv("KIS_WS_URL"),
+		ScheduleTime:  getEnv("SCHEDULE_TIME", "15:40"),
 		AlpacaApiKey:  getEnv("ALPACA_API_KEY", ""),
 		AlpacaSecret:  getEnv("ALPACA_SECRET_KEY", ""),
+
+		Broker:           getEnv("BROKER", "kis"),
+		PaperInitialCash: getEnvFloat("PAPER_INITIAL_CASH", 10000),
+		PaperLedgerPath:  getEnv("PAPER_LEDGER_PATH", "data/paper_ledger.json"),
+
+		CassetteMode: os.Getenv("CASSETTE_MODE"),
+		CassetteDir:  getEnv("CASSETTE_DIR", "data/cassettes"),
+
+		SymbolMaster: getEnvList("SYMBOL_MASTER"),
+
+		OrderPlacement: getEnv("ORDER_PLACEMENT", "live"),
+		ReserveTime:    getEnv("RESERVE_TIME", "21:30"),
+
+		FXRate: getEnvFloat("FX_RATE", 0),
+
+		RealtimeQuotes: getEnvBool("REALTIME_QUOTES", false),
+
+		CredentialDir:     getEnv("CREDENTIAL_DIR", "data/credentials"),
+		CredentialKey:     os.Getenv("CREDENTIAL_KEY"),
+		CredentialKeyFile: os.Getenv("CREDENTIAL_KEY_FILE"),
 	}
 }
 
+// Secrets are the configured values that must never reach a log or a fixture
+func (c *Config) Secrets() []string {
+	out := []string{c.KisAppKey, c.KisAppSecret, c.KisAccountNum, c.AlpacaApiKey, c.AlpacaSecret, c.CredentialKey}
+	if len(c.KisAccountNum) > 8 {
+		out = append(out, c.KisAccountNum[:8]) // CANO
+	}
+	return out
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -36,3 +93,40 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Warning: Invalid value for %s (%s), using %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: Invalid value for %s (%s), using %v", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package credstore
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+)
+
+// FromConfig opens the credential store of cfg (CREDENTIAL_DIR), encrypted
+// with CREDENTIAL_KEY or the contents of CREDENTIAL_KEY_FILE
+func FromConfig(cfg *config.Config) (Store, error) {
+	key := []byte(cfg.CredentialKey)
+	if len(key) == 0 && cfg.CredentialKeyFile != "" {
+		data, err := os.ReadFile(cfg.CredentialKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("CREDENTIAL_KEY_FILE: %w", err)
+		}
+		key = []byte(strings.TrimSpace(string(data)))
+		if len(key) == 0 {
+			return nil, fmt.Errorf("CREDENTIAL_KEY_FILE %s is empty", cfg.CredentialKeyFile)
+		}
+	}
+	Register(string(key))
+
+	store, err := NewFileStore(cfg.CredentialDir, key)
+	if err != nil {
+		return nil, err
+	}
+	if !store.Encrypted() {
+		log.Printf("[CREDSTORE] ⚠ No CREDENTIAL_KEY set, %s is stored unencrypted (0600)", cfg.CredentialDir)
+	}
+	return store, nil
+}
+
+// LoadCredentials fills the API keys missing from the environment with the
+// store entries of the same name (KIS_APP_KEY, KIS_APP_SECRET, ...)
+func LoadCredentials(store Store, cfg *config.Config) error {
+	fields := []struct {
+		name string
+		dst  *string
+	}{
+		{"KIS_APP_KEY", &cfg.KisAppKey},
+		{"KIS_APP_SECRET", &cfg.KisAppSecret},
+		{"KIS_ACCOUNT_NUM", &cfg.KisAccountNum},
+		{"ALPACA_API_KEY", &cfg.AlpacaApiKey},
+		{"ALPACA_SECRET_KEY", &cfg.AlpacaSecret},
+	}
+	for _, f := range fields {
+		if *f.dst != "" {
+			continue
+		}
+		v, err := store.Get(f.name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.dst = strings.TrimSpace(string(v))
+		log.Printf("[CREDSTORE] %s loaded from the credential store", f.name)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package credstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File layout of an encrypted value: magic, GCM nonce, ciphertext+tag.
+// The entry name is authenticated so files cannot be swapped between names.
+var encMagic = []byte("TQCS1\n")
+
+const (
+	encExt  = ".enc"
+	jsonExt = ".json"
+	lockExt = ".lock"
+)
+
+// validName keeps entry names usable as file names
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// FileStore keeps one file per entry in Dir, mode 0600 in a 0700 directory.
+// With a master key the files are AES-256-GCM encrypted (<name>.enc),
+// otherwise they are plaintext (<name>.json).
+type FileStore struct {
+	Dir  string
+	aead cipher.AEAD
+
+	mu    sync.Mutex // Serializes locks taken in this process
+	locks map[string]*sync.Mutex
+}
+
+// NewFileStore opens dir; masterKey is hashed to the AES-256 key, nil stores plaintext
+func NewFileStore(dir string, masterKey []byte) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("credential dir %s: %w", dir, err)
+	}
+	s := &FileStore{Dir: dir, locks: make(map[string]*sync.Mutex)}
+	if len(masterKey) > 0 {
+		key := sha256.Sum256(masterKey)
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		if s.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Encrypted() bool {
+	return s.aead != nil
+}
+
+func (s *FileStore) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid credential name %q", name)
+	}
+	ext := jsonExt
+	if s.Encrypted() {
+		ext = encExt
+	}
+	return filepath.Join(s.Dir, name+ext), nil
+}
+
+func (s *FileStore) Get(name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkPerm(path)
+	if !s.Encrypted() {
+		return data, nil
+	}
+	return s.open(name, data)
+}
+
+func (s *FileStore) Put(name string, data []byte) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if s.Encrypted() {
+		if data, err = s.seal(name, data); err != nil {
+			return err
+		}
+	}
+	return writeAtomic(path, data)
+}
+
+func (s *FileStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the names readable with this store's key setting, sorted
+func (s *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ext := jsonExt
+	if s.Encrypted() {
+		ext = encExt
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ext); ok && !e.IsDir() && validName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Lock takes <name>.lock: an in-process mutex plus an OS file lock for other processes
+func (s *FileStore) Lock(name string) (func(), error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid credential name %q", name)
+	}
+	s.mu.Lock()
+	m, ok := s.locks[name]
+	if !ok {
+		m = &sync.Mutex{}
+		s.locks[name] = m
+	}
+	s.mu.Unlock()
+
+	m.Lock()
+	f, err := os.OpenFile(filepath.Join(s.Dir, name+lockExt), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		m.Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		m.Unlock()
+		return nil, fmt.Errorf("lock %s: %w", name, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		m.Unlock()
+	}, nil
+}
+
+func (s *FileStore) seal(name string, plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, encMagic...), nonce...)
+	return s.aead.Seal(out, nonce, plain, []byte(name)), nil
+}
+
+func (s *FileStore) open(name string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encMagic) || len(data) < len(encMagic)+s.aead.NonceSize() {
+		return nil, fmt.Errorf("credential %s: not an encrypted credential file", name)
+	}
+	data = data[len(encMagic):]
+	nonce, sealed := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("credential %s: wrong master key or corrupted file", name)
+	}
+	return plain, nil
+}
+
+// writeAtomic writes data to a 0600 temp file next to path, syncs it and
+// renames it over path
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op after the rename
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// checkPerm tightens a credential file others can read (e.g. copied in by hand)
+func checkPerm(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0o077 == 0 {
+		return
+	}
+	log.Printf("[CREDSTORE] ⚠ %s was readable by others (%v), setting 0600", path, info.Mode().Perm())
+	os.Chmod(path, 0o600)
+}
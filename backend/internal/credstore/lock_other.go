@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package credstore
+
+import "os"
+
+// No flock here: Lock only serializes goroutines of this process
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) {}
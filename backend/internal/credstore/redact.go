@@ -0,0 +1,69 @@
+package credstore
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces every registered secret in redacted output
+const Redacted = "REDACTED"
+
+// minSecretLen keeps short values like the "01" account product code from
+// redacting unrelated text
+const minSecretLen = 6
+
+var registry struct {
+	sync.RWMutex
+	values []string // Longest first, so a secret containing another is replaced whole
+}
+
+// Register adds values (keys, tokens, account numbers) to redact from logs
+func Register(values ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, v := range values {
+		if len(v) < minSecretLen || contains(registry.values, v) {
+			continue
+		}
+		registry.values = append(registry.values, v)
+	}
+	sort.Slice(registry.values, func(i, j int) bool { return len(registry.values[i]) > len(registry.values[j]) })
+}
+
+func contains(values []string, v string) bool {
+	for _, have := range values {
+		if have == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces every registered secret in s
+func Redact(s string) string {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, v := range registry.values {
+		s = strings.ReplaceAll(s, v, Redacted)
+	}
+	return s
+}
+
+type redactWriter struct {
+	w io.Writer
+}
+
+// NewRedactor wraps w (a log output) so every write is redacted first.
+// The log package and gin write one line per call, so secrets are never split.
+func NewRedactor(w io.Writer) io.Writer {
+	return redactWriter{w: w}
+}
+
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
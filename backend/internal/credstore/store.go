@@ -0,0 +1,28 @@
+// Package credstore keeps broker credentials and tokens out of the working
+// directory and out of the logs: a Store of named secrets on disk (AES-GCM
+// encrypted when a master key is configured), cross-process locking and a
+// log writer that redacts every registered secret.
+package credstore
+
+import (
+	"errors"
+)
+
+// ErrNotFound is returned by Get for a name that was never stored
+var ErrNotFound = errors.New("credential not found")
+
+// Store holds named secrets (tokens, app keys)
+type Store interface {
+	Get(name string) ([]byte, error)
+	// Put replaces name atomically: readers see the old or the new value, never a partial one
+	Put(name string, data []byte) error
+	Delete(name string) error
+	List() ([]string, error)
+
+	// Lock takes an exclusive lock on name shared with other processes using
+	// the same store (server and test_trade); call the func to release it
+	Lock(name string) (func(), error)
+
+	// Encrypted reports whether values are encrypted at rest
+	Encrypted() bool
+}
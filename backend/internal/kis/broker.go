@@ -0,0 +1,517 @@
+package kis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// Broker adapts Client to the broker.Broker interface
+type Broker struct {
+	Client *Client
+
+	streamOnce sync.Once
+	stream     *Stream
+}
+
+func NewBroker(client *Client) *Broker {
+	return &Broker{Client: client}
+}
+
+// quoteStream returns the realtime stream, created on first use
+func (b *Broker) quoteStream() *Stream {
+	b.streamOnce.Do(func() { b.stream = b.Client.NewStream() })
+	return b.stream
+}
+
+func (b *Broker) StreamQuotes(ctx context.Context, sink func(broker.Quote)) error {
+	return b.quoteStream().Run(ctx, sink)
+}
+
+func (b *Broker) SubscribeQuotes(exch broker.Exchange, symbol string) error {
+	code, ok := symbols.QuoteCode(exch)
+	if !ok {
+		return broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", exch)
+	}
+	return b.quoteStream().Subscribe(code, symbol)
+}
+
+func (b *Broker) UnsubscribeQuotes(exch broker.Exchange, symbol string) error {
+	code, ok := symbols.QuoteCode(exch)
+	if !ok {
+		return broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", exch)
+	}
+	return b.quoteStream().Unsubscribe(code, symbol)
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+func parseInt(s string) int {
+	// Quantities sometimes come back as "12.000"
+	return int(parseFloat(s))
+}
+
+func (b *Broker) Name() string {
+	return "kis"
+}
+
+func (b *Broker) Mode() string {
+	return string(b.Client.Mode())
+}
+
+func (b *Broker) ForceRefresh(ctx context.Context) error {
+	return b.Client.ForceRefresh(ctx)
+}
+
+func (b *Broker) GetBalance(ctx context.Context) (*broker.Balance, error) {
+	resp, err := b.Client.GetBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bal := &broker.Balance{
+		TotalPurchase:   parseFloat(resp.Output2.TotalPurchase),
+		TotalEvaluation: parseFloat(resp.Output2.TotalAmt),
+		TotalPL:         parseFloat(resp.Output2.TotalPL),
+		TotalPLRate:     parseFloat(resp.Output2.TotalPLRate),
+		RealizedPL:      parseFloat(resp.Output2.RealizedPL),
+	}
+	for _, h := range resp.Output1 {
+		bal.Positions = append(bal.Positions, broker.Position{
+			Symbol:       h.Symbol,
+			Exchange:     symbols.ExchangeFromCode(h.ExchCode),
+			Qty:          parseInt(h.Qty),
+			AvgPrice:     parseFloat(h.AvgPrice),
+			CurrentPrice: parseFloat(h.NowPrice),
+		})
+	}
+	return bal, nil
+}
+
+func (b *Broker) GetCash(ctx context.Context) (float64, error) {
+	resp, err := b.Client.GetBuyingPower(ctx, cashQuery)
+	if err != nil {
+		return 0, err
+	}
+	return parseFloat(resp.Output.OvrsOrdPsblAmt), nil
+}
+
+func (b *Broker) GetBuyingPower(ctx context.Context, exch broker.Exchange, symbol string, price float64) (*broker.BuyingPower, error) {
+	code, ok := symbols.OrderCode(exch)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", exch)
+	}
+	resp, err := b.Client.GetBuyingPower(ctx, BuyingPowerQuery{ExchCode: code, Symbol: symbol, Price: price})
+	if err != nil {
+		return nil, err
+	}
+	return &broker.BuyingPower{
+		Symbol: symbol,
+		Price:  price,
+		Cash:   parseFloat(resp.Output.OvrsOrdPsblAmt),
+		MaxQty: parseInt(resp.Output.MaxOrdPsblQty),
+	}, nil
+}
+
+func (b *Broker) GetQuote(ctx context.Context, exch broker.Exchange, symbol string) (float64, error) {
+	code, ok := symbols.QuoteCode(exch)
+	if !ok {
+		return 0, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", exch)
+	}
+	return b.Client.GetCurrentPrice(ctx, code, symbol)
+}
+
+// gubnCodes maps history periods to the daily price API's GUBN
+var gubnCodes = map[broker.Period]string{
+	"":                 GubnDay,
+	broker.PeriodDay:   GubnDay,
+	broker.PeriodWeek:  GubnWeek,
+	broker.PeriodMonth: GubnMonth,
+}
+
+func (b *Broker) GetHistory(ctx context.Context, req broker.HistoryRequest) ([]broker.Bar, error) {
+	code, ok := symbols.QuoteCode(req.Exchange)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", req.Exchange)
+	}
+	gubn, ok := gubnCodes[req.Period]
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported history period: %s", req.Period)
+	}
+	items, err := b.Client.GetDailyPrice(ctx, DailyPriceQuery{
+		ExchCode: code,
+		Symbol:   req.Symbol,
+		Gubn:     gubn,
+		Adjusted: req.Adjusted,
+		Start:    req.Start,
+		End:      req.End,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]broker.Bar, 0, len(items))
+	for _, it := range items {
+		bars = append(bars, broker.Bar{
+			Date:     it.Date,
+			Open:     it.Open,
+			High:     it.High,
+			Low:      it.Low,
+			Close:    it.Close,
+			Volume:   it.Volume,
+			Adjusted: it.Adjusted,
+		})
+	}
+	return bars, nil
+}
+
+func (b *Broker) PlaceOrder(ctx context.Context, req broker.OrderRequest) (*broker.Order, error) {
+	exch, ok := symbols.OrderCode(req.Exchange)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", req.Exchange)
+	}
+	ordType, ok := ordDvsnFor[req.Type]
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported order type for KIS US orders: %s", req.Type)
+	}
+	if err := broker.CheckOrderWindow(req.Type, req.Exchange, time.Now()); err != nil {
+		return nil, err
+	}
+
+	odno, err := b.Client.PlaceOrder(ctx, OrderReq{
+		ExchCode: exch,
+		Symbol:   req.Symbol,
+		Qty:      req.Qty,
+		Price:    req.Price,
+		OrdType:  ordType,
+		Side:     string(req.Side),
+	})
+	if errors.Is(err, ErrAmbiguous) {
+		return nil, fmt.Errorf("%w: %v", broker.ErrOutcomeUnknown, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &broker.Order{
+		ID:           odno,
+		OrderRequest: req,
+		Status:       broker.OrderStatusSubmitted,
+		SubmittedAt:  time.Now(),
+	}, nil
+}
+
+func (b *Broker) CancelOrder(ctx context.Context, o broker.Order) error {
+	exch, ok := symbols.OrderCode(o.Exchange)
+	if !ok {
+		return broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", o.Exchange)
+	}
+	_, err := b.Client.CancelOrder(ctx, exch, o.Symbol, o.ID, o.Qty-o.FilledQty)
+	return err
+}
+
+func (b *Broker) AmendOrder(ctx context.Context, o broker.Order, qty int, price float64) (*broker.Order, error) {
+	exch, ok := symbols.OrderCode(o.Exchange)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", o.Exchange)
+	}
+	odno, err := b.Client.ReviseOrder(ctx, exch, o.Symbol, o.ID, qty, price)
+	if err != nil {
+		return nil, err
+	}
+
+	amended := o
+	amended.ID = odno
+	amended.Qty = qty
+	amended.Price = price
+	amended.FilledQty = 0
+	amended.AvgFillPrice = 0
+	amended.Status = broker.OrderStatusSubmitted
+	amended.SubmittedAt = time.Now()
+	return &amended, nil
+}
+
+// orderLookback bounds the order history inquiry used for status checks
+const orderLookback = 7 * 24 * time.Hour
+
+// GetOrderStatus looks the order up in the order/fill history of the last week (US dates)
+func (b *Broker) GetOrderStatus(ctx context.Context, orderID string) (*broker.Order, error) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(loc)
+	start := now.Add(-orderLookback).Format("20060102")
+	end := now.Format("20060102")
+
+	items, err := b.Client.GetOrderHistory(ctx, start, end, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if it.OrderNo == orderID {
+			return orderFromHistory(it, loc), nil
+		}
+	}
+	return nil, fmt.Errorf("order not found: %s", orderID)
+}
+
+// ListOrders returns the orders of the US dates from since through today
+func (b *Broker) ListOrders(ctx context.Context, since time.Time) ([]broker.Order, error) {
+	loc := broker.Eastern()
+	items, err := b.Client.GetOrderHistory(ctx, since.In(loc).Format("20060102"), time.Now().In(loc).Format("20060102"), "")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]broker.Order, 0, len(items))
+	for _, it := range items {
+		out = append(out, *orderFromHistory(it, loc))
+	}
+	return out, nil
+}
+
+// ClearOutcomeUnknown lifts the client's guard against resending req, placed
+// as an order or a reservation
+func (b *Broker) ClearOutcomeUnknown(req broker.OrderRequest) {
+	exch, _ := symbols.OrderCode(req.Exchange)
+	b.Client.ClearAmbiguous(OrderReq{
+		ExchCode: exch,
+		Symbol:   req.Symbol,
+		Qty:      req.Qty,
+		Price:    req.Price,
+		OrdType:  ordDvsnFor[req.Type],
+		Side:     string(req.Side),
+	})
+}
+
+// orderFromHistory maps an inquire-ccnl row to a broker.Order
+func orderFromHistory(it OrderHistoryItem, loc *time.Location) *broker.Order {
+	side := broker.SideBuy
+	if it.SideCode == "01" {
+		side = broker.SideSell
+	}
+
+	o := &broker.Order{
+		ID: it.OrderNo,
+		OrderRequest: broker.OrderRequest{
+			Exchange: symbols.ExchangeFromCode(it.ExchCode),
+			Symbol:   it.Symbol,
+			Side:     side,
+			Type:     broker.OrderTypeLimit,
+			Qty:      parseInt(it.OrderQty),
+			Price:    parseFloat(it.OrderPrice),
+		},
+		FilledQty:    parseInt(it.FilledQty),
+		AvgFillPrice: parseFloat(it.FilledPrice),
+	}
+	if t, err := time.ParseInLocation("20060102150405", it.OrderDate+it.OrderTime, loc); err == nil {
+		o.SubmittedAt = t
+	}
+
+	unfilled := parseInt(it.UnfilledQty)
+	switch {
+	case it.RejectReason != "" || strings.Contains(it.StatusName, "거부"):
+		o.Status = broker.OrderStatusRejected
+	case o.Qty > 0 && o.FilledQty >= o.Qty:
+		o.Status = broker.OrderStatusFilled
+	case unfilled == 0 && strings.Contains(it.StatusName, "완료"):
+		// Done but not fully filled: cancelled (or expired) with the remainder
+		o.Status = broker.OrderStatusCancelled
+	case o.FilledQty > 0:
+		o.Status = broker.OrderStatusPartiallyFilled
+	default:
+		o.Status = broker.OrderStatusSubmitted
+	}
+	return o
+}
+
+// kst is the zone of reservation receipt dates
+var kst = time.FixedZone("KST", 9*60*60)
+
+// Reservation IDs join the receipt date and reservation number: "20260115-0000012345"
+func reservationID(r ReservationReceipt) string {
+	return r.ReceiptDate + "-" + r.ResvOrderNo
+}
+
+func parseReservationID(id string) (ReservationReceipt, error) {
+	date, no, ok := strings.Cut(id, "-")
+	if !ok || len(date) != 8 || no == "" {
+		return ReservationReceipt{}, broker.NewError(broker.CategoryValidation, "invalid KIS reservation ID: %q", id)
+	}
+	return ReservationReceipt{ReceiptDate: date, ResvOrderNo: no}, nil
+}
+
+// PlaceReservation queues req for the next session. KIS takes reservations
+// only while the regular session is closed.
+func (b *Broker) PlaceReservation(ctx context.Context, req broker.OrderRequest) (*broker.Reservation, error) {
+	exch, ok := symbols.OrderCode(req.Exchange)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported exchange: %s", req.Exchange)
+	}
+	ordType, ok := ordDvsnFor[req.Type]
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported order type for KIS reservation orders: %s", req.Type)
+	}
+	if broker.InRegularSession(time.Now()) {
+		return nil, broker.NewError(broker.CategoryValidation, "reservation orders are not accepted during the regular session; place a regular order")
+	}
+
+	receipt, err := b.Client.PlaceReservation(ctx, OrderReq{
+		ExchCode: exch,
+		Symbol:   req.Symbol,
+		Qty:      req.Qty,
+		Price:    req.Price,
+		OrdType:  ordType,
+		Side:     string(req.Side),
+	})
+	if errors.Is(err, ErrAmbiguous) {
+		return nil, fmt.Errorf("%w: %v", broker.ErrOutcomeUnknown, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &broker.Reservation{
+		ID:           reservationID(receipt),
+		OrderRequest: req,
+		Status:       broker.ReservationPending,
+		ReservedAt:   time.Now(),
+	}, nil
+}
+
+func (b *Broker) CancelReservation(ctx context.Context, r broker.Reservation) error {
+	receipt, err := parseReservationID(r.ID)
+	if err != nil {
+		return err
+	}
+	return b.Client.CancelReservation(ctx, receipt)
+}
+
+func (b *Broker) GetReservations(ctx context.Context, since time.Time) ([]broker.Reservation, error) {
+	start := since.In(kst).Format("20060102")
+	end := time.Now().In(kst).Format("20060102")
+	items, err := b.Client.ListReservations(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]broker.Reservation, 0, len(items))
+	for _, it := range items {
+		out = append(out, reservationFromItem(it))
+	}
+	return out, nil
+}
+
+// reservationFromItem maps an order-resv-list row to a broker.Reservation
+func reservationFromItem(it ReservationItem) broker.Reservation {
+	side := broker.SideBuy
+	if it.SideCode == "01" {
+		side = broker.SideSell
+	}
+
+	r := broker.Reservation{
+		ID: reservationID(ReservationReceipt{ReceiptDate: it.ReceiptDate, ResvOrderNo: it.ResvOrderNo}),
+		OrderRequest: broker.OrderRequest{
+			Exchange: symbols.ExchangeFromCode(it.ExchCode),
+			Symbol:   it.Symbol,
+			Side:     side,
+			Type:     broker.OrderTypeLimit,
+			Qty:      parseInt(it.OrderQty),
+			Price:    parseFloat(it.OrderPrice),
+		},
+		OrderID: strings.TrimSpace(it.OrderNo),
+		Message: strings.TrimSpace(it.RejectReason),
+	}
+	if r.Price == 0 && side == broker.SideSell {
+		r.Type = broker.OrderTypeMOO
+	}
+	if t, err := time.ParseInLocation("20060102150405", it.ReceiptDate+it.ReceiptTime, kst); err == nil {
+		r.ReservedAt = t
+	}
+
+	switch {
+	case it.Cancelled == "Y":
+		r.Status = broker.ReservationCancelled
+	case r.OrderID != "":
+		r.Status = broker.ReservationSent
+	case r.Message != "":
+		r.Status = broker.ReservationRejected
+	default:
+		r.Status = broker.ReservationPending
+	}
+	return r
+}
+
+func (b *Broker) GetRealizedPL(ctx context.Context, start, end string) ([]broker.RealizedPL, error) {
+	items, err := b.Client.GetPeriodProfit(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]broker.RealizedPL, 0, len(items))
+	for _, it := range items {
+		out = append(out, broker.RealizedPL{
+			Date:         it.TradeDate,
+			Exchange:     symbols.ExchangeFromCode(it.ExchCode),
+			Symbol:       it.Symbol,
+			Qty:          parseInt(it.SoldQty),
+			AvgBuyPrice:  parseFloat(it.AvgBuyPrice),
+			AvgSellPrice: parseFloat(it.AvgSellPrice),
+			BuyAmount:    parseFloat(it.BuyAmt),
+			SellAmount:   parseFloat(it.SellAmt),
+			Fee:          parseFloat(it.Fee),
+			Profit:       parseFloat(it.RealizedPL),
+			ExchangeRate: parseFloat(it.ExchangeRate),
+		})
+	}
+	return out, nil
+}
+
+func (b *Broker) GetExecutions(ctx context.Context, start, end string) ([]broker.Execution, error) {
+	items, err := b.Client.GetPeriodTrans(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]broker.Execution, 0, len(items))
+	for _, it := range items {
+		side := broker.SideBuy
+		if it.SideCode == "01" {
+			side = broker.SideSell
+		}
+		out = append(out, broker.Execution{
+			Date:       it.TradeDate,
+			SettleDate: it.SettleDate,
+			Symbol:     it.Symbol,
+			Side:       side,
+			Qty:        parseInt(it.Qty),
+			Price:      parseFloat(it.Price),
+			Amount:     parseFloat(it.Amount),
+			Fee:        parseFloat(it.Fee),
+		})
+	}
+	return out, nil
+}
+
+func (b *Broker) GetFXRate(ctx context.Context) (*broker.FXRate, error) {
+	resp, err := b.Client.GetPresentBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usd, ok := resp.Currency("USD")
+	rate := parseFloat(usd.ExchangeRate)
+	if !ok || rate <= 0 {
+		return nil, broker.NewError(broker.CategoryUnavailable, "no USD/KRW rate in the present balance")
+	}
+	return &broker.FXRate{Rate: rate, Date: time.Now().In(kst).Format("20060102")}, nil
+}
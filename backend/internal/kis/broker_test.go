@@ -0,0 +1,232 @@
+package kis_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+)
+
+// newFakeClient connects a client to a fake KIS server; rate 0 is the real quota
+func newFakeClient(t *testing.T, sc kisfake.Scenario, rate float64) (*kis.Client, *kisfake.Server) {
+	t.Helper()
+	srv := kisfake.NewServer(sc)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		KisAppKey:     "appkey123456",
+		KisAppSecret:  "secret123456",
+		KisAccountNum: "12345678-01",
+		KisBaseURL:    srv.URL,
+		KisMode:       "real",
+		KisWSURL:      srv.WSURL(),
+		KisRateLimit:  rate,
+	}
+	client, err := kis.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, srv
+}
+
+func newFakeBroker(t *testing.T, sc kisfake.Scenario) (*kis.Broker, *kisfake.Server) {
+	t.Helper()
+	client, srv := newFakeClient(t, sc, 0)
+	return kis.NewBroker(client), srv
+}
+
+// An order placed through the adapter, filled in two parts by the fake, shows
+// up in the order status and in the paged balance of every exchange
+func TestOrderFillBalanceRoundTrip(t *testing.T) {
+	b, srv := newFakeBroker(t, kisfake.Scenario{
+		Cash:   10000,
+		Prices: map[string]float64{"TQQQ": 50},
+		Holdings: []kisfake.Holding{
+			{Symbol: "AAPL", Exchange: "NASD", Qty: 1, AvgPrice: 200},
+			{Symbol: "MSFT", Exchange: "NASD", Qty: 2, AvgPrice: 400},
+			{Symbol: "NVDA", Exchange: "NASD", Qty: 3, AvgPrice: 100},
+			{Symbol: "KO", Exchange: "NYSE", Qty: 4, AvgPrice: 60},
+			{Symbol: "SOXL", Exchange: "AMEX", Qty: 5, AvgPrice: 25},
+			{Symbol: "SPXL", Exchange: "AMEX", Qty: 6, AvgPrice: 150},
+		},
+		BalancePageSize: 2,
+	})
+	ctx := context.Background()
+
+	order, err := b.PlaceOrder(ctx, broker.OrderRequest{
+		Symbol: "TQQQ", Exchange: broker.ExchangeNASDAQ, Side: broker.SideBuy,
+		Type: broker.OrderTypeLimit, Qty: 10, Price: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booked := srv.Orders(); len(booked) != 1 || booked[0].OrderNo != order.ID || booked[0].Qty != 10 {
+		t.Fatalf("fake booked %+v, want order %s for 10", booked, order.ID)
+	}
+
+	if err := srv.Fill(order.ID, 4, 49.5); err != nil {
+		t.Fatal(err)
+	}
+	status, err := b.GetOrderStatus(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != broker.OrderStatusPartiallyFilled || status.FilledQty != 4 {
+		t.Fatalf("after the first fill: %s %d/%d", status.Status, status.FilledQty, status.Qty)
+	}
+
+	if err := srv.Fill(order.ID, 6, 50); err != nil {
+		t.Fatal(err)
+	}
+	status, err = b.GetOrderStatus(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != broker.OrderStatusFilled || status.FilledQty != 10 || math.Abs(status.AvgFillPrice-49.8) > 0.005 {
+		t.Fatalf("after the last fill: %s %d/%d @ %.4f", status.Status, status.FilledQty, status.Qty, status.AvgFillPrice)
+	}
+
+	bal, err := b.GetBalance(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		exch broker.Exchange
+		qty  int
+	}{
+		"AAPL": {broker.ExchangeNASDAQ, 1},
+		"MSFT": {broker.ExchangeNASDAQ, 2},
+		"NVDA": {broker.ExchangeNASDAQ, 3},
+		"TQQQ": {broker.ExchangeNASDAQ, 10},
+		"KO":   {broker.ExchangeNYSE, 4},
+		"SOXL": {broker.ExchangeAMEX, 5},
+		"SPXL": {broker.ExchangeAMEX, 6},
+	}
+	if len(bal.Positions) != len(want) {
+		t.Fatalf("balance has %d positions, want %d: %+v", len(bal.Positions), len(want), bal.Positions)
+	}
+	for _, p := range bal.Positions {
+		w, ok := want[p.Symbol]
+		if !ok || p.Exchange != w.exch || p.Qty != w.qty {
+			t.Errorf("position %s %s qty %d, want %+v", p.Symbol, p.Exchange, p.Qty, w)
+		}
+		if p.Symbol == "TQQQ" && math.Abs(p.AvgPrice-49.8) > 0.005 {
+			t.Errorf("TQQQ avg price %.4f, want 49.80", p.AvgPrice)
+		}
+	}
+
+	// NASDAQ holds 4 symbols: at 2 rows per page its second page is a continuation
+	cont := 0
+	for _, r := range srv.Requests() {
+		if r.Query.Get("CTX_AREA_NK200") != "" && r.TrCont == "N" {
+			cont++
+		}
+	}
+	if cont == 0 {
+		t.Error("balance inquiry never requested a continuation page")
+	}
+}
+
+// Order types without a KIS division, like the extended-hours limits KIS does
+// not route, must not go out as plain limits
+func TestUnsupportedOrderTypeRejected(t *testing.T) {
+	b, srv := newFakeBroker(t, kisfake.Scenario{Cash: 10000, Prices: map[string]float64{"TQQQ": 50}})
+
+	_, err := b.PlaceOrder(context.Background(), broker.OrderRequest{
+		Symbol: "TQQQ", Exchange: broker.ExchangeNASDAQ, Side: broker.SideBuy,
+		Type: broker.OrderType("EXT_LIMIT"), Qty: 1, Price: 50,
+	})
+	if broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Fatalf("EXT_LIMIT err = %v, want a validation error", err)
+	}
+	if n := len(srv.Orders()); n != 0 {
+		t.Errorf("fake booked %d orders", n)
+	}
+}
+
+// Accounts whose NASD inquiry already lists every US holding must not see the
+// NYSE and AMEX holdings twice, nor the realized profit of the repeated summary
+func TestBalanceSkipsHoldingsRepeatedAcrossExchanges(t *testing.T) {
+	b, _ := newFakeBroker(t, kisfake.Scenario{
+		Prices:     map[string]float64{"TQQQ": 55, "KO": 60, "SOXL": 25, "SCHD": 27},
+		RealizedPL: 120,
+		Holdings: []kisfake.Holding{
+			{Symbol: "TQQQ", Exchange: "NASD", Qty: 10, AvgPrice: 50},
+			{Symbol: "KO", Exchange: "NYSE", Qty: 4, AvgPrice: 60},
+			{Symbol: "SOXL", Exchange: "AMEX", Qty: 5, AvgPrice: 25},
+			{Symbol: "SCHD", Exchange: "AMEX", Qty: 8, AvgPrice: 25},
+		},
+		BalancePageSize:  3,
+		BalanceAllOnNASD: true,
+	})
+
+	bal, err := b.GetBalance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	qty := map[string]int{}
+	for _, p := range bal.Positions {
+		qty[p.Symbol] += p.Qty
+	}
+	if len(bal.Positions) != 4 || qty["TQQQ"] != 10 || qty["KO"] != 4 || qty["SOXL"] != 5 || qty["SCHD"] != 8 {
+		t.Errorf("positions = %+v, want each holding once", bal.Positions)
+	}
+	// 10×50 + 4×60 + 5×25 + 8×25
+	if math.Abs(bal.TotalPurchase-1065) > 0.005 {
+		t.Errorf("total purchase %.2f, want 1065.00", bal.TotalPurchase)
+	}
+	if math.Abs(bal.RealizedPL-120) > 0.005 {
+		t.Errorf("realized P&L %.2f, want 120.00 counted once", bal.RealizedPL)
+	}
+}
+
+// BYMD is inclusive, so every page after the first starts with the oldest
+// row of the previous one; the pages must join without repeats or gaps
+func TestDailyPricePagesJoinWithoutRepeats(t *testing.T) {
+	var bars []kisfake.Bar
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		c := 50 + float64(i)
+		bars = append(bars, kisfake.Bar{Date: day.AddDate(0, 0, -i).Format("20060102"), Open: c, High: c, Low: c, Close: c, Volume: 1000})
+	}
+	c, srv := newFakeClient(t, kisfake.Scenario{Daily: map[string][]kisfake.Bar{"TQQQ": bars}, DailyPageSize: 4}, 0)
+	ctx := context.Background()
+
+	rows, err := c.GetDailyPrice(ctx, kis.DailyPriceQuery{ExchCode: "NAS", Symbol: "TQQQ", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(bars) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(bars))
+	}
+	for i, r := range rows {
+		if r.Date != bars[i].Date || r.Close != bars[i].Close {
+			t.Errorf("row %d = %s %.2f, want %s %.2f", i, r.Date, r.Close, bars[i].Date, bars[i].Close)
+		}
+	}
+	// 4 + 3 + 3 new rows, then a page with nothing new
+	if n := srv.Count("/quotations/dailyprice"); n != 4 {
+		t.Errorf("%d daily price requests, want 4", n)
+	}
+
+	// A start date stops the walk; a limit cuts the rows
+	rows, err = c.GetDailyPrice(ctx, kis.DailyPriceQuery{ExchCode: "NAS", Symbol: "TQQQ", Start: bars[5].Date})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 || rows[5].Date != bars[5].Date {
+		t.Errorf("from %s: %d rows, want 6 ending there", bars[5].Date, len(rows))
+	}
+	rows, err = c.GetDailyPrice(ctx, kis.DailyPriceQuery{ExchCode: "NAS", Symbol: "TQQQ", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[4].Date != bars[4].Date {
+		t.Errorf("limit 5: %d rows, want the newest 5", len(rows))
+	}
+}
@@ -2,17 +2,22 @@ package kis
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/credstore"
 )
 
 type Client struct {
@@ -21,14 +26,63 @@ type Client struct {
 	TokenExp    time.Time
 	mu          sync.Mutex
 	Client      *http.Client
+	mode        Mode
+	limiter     *rateLimiter
+
+	// Store caches the access token across restarts and processes, nil = memory only
+	Store credstore.Store
+
+	// Orders whose outcome is unknown after a network failure
+	ambMu     sync.Mutex
+	ambiguous map[string]time.Time
 }
 
-func NewClient(cfg *config.Config) *Client {
-	log.Printf("[KIS] Initializing KIS API Client (BaseURL: %s)", cfg.KisBaseURL)
+// NewClient fails if KIS_MODE and KIS_BASE_URL point at different environments
+func NewClient(cfg *config.Config) (*Client, error) {
+	mode, err := ParseMode(cfg.KisMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMode(mode, cfg.KisBaseURL); err != nil {
+		return nil, err
+	}
+
+	rate := cfg.KisRateLimit
+	if rate <= 0 {
+		rate = realRatePerSec
+		if mode == ModeVirtual {
+			rate = virtualRatePerSec
+		}
+	}
+
+	log.Printf("[KIS] Initializing KIS API Client (Mode: %s, BaseURL: %s, Rate: %.0f req/s)", mode, cfg.KisBaseURL, rate)
 	return &Client{
-		Config: cfg,
-		Client: &http.Client{Timeout: 10 * time.Second},
+		Config:    cfg,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		mode:      mode,
+		limiter:   newRateLimiter(rate),
+		ambiguous: make(map[string]time.Time),
+	}, nil
+}
+
+// Mode reports whether the client trades the real or the virtual (VTS) account
+func (c *Client) Mode() Mode {
+	return c.mode
+}
+
+// newRequest builds an API request with the auth headers and the TR ID for api
+func (c *Client) newRequest(ctx context.Context, method, url, api string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
 	}
+
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("authorization", "Bearer "+c.AccessToken)
+	req.Header.Set("appkey", c.Config.KisAppKey)
+	req.Header.Set("appsecret", c.Config.KisAppSecret)
+	req.Header.Set("tr_id", c.trID(api))
+	return req, nil
 }
 
 // logKIS logs a message with KIS prefix and timestamp
@@ -43,18 +97,55 @@ type AuthResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
-const TokenFile = "kis_token.json"
+// legacyTokenFile is where tokens were cached in plaintext before the credential store
+const legacyTokenFile = "kis_token.json"
+
+// tokenName is the credential store entry of the access token; real and
+// virtual tokens are kept apart
+func (c *Client) tokenName() string {
+	return "kis_token_" + string(c.mode)
+}
+
+// storedToken is the cached access token
+type storedToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenExp    time.Time `json:"token_exp"`
+}
+
+// lockToken takes the store lock on the token so another process (server,
+// test_trade) does not issue one at the same time; KIS revokes the previous
+// token on every issue
+func (c *Client) lockToken() func() {
+	if c.Store == nil {
+		return func() {}
+	}
+	unlock, err := c.Store.Lock(c.tokenName())
+	if err != nil {
+		logKIS("Warning: Cannot lock the token, continuing unlocked: %v", err)
+		return func() {}
+	}
+	return unlock
+}
 
 // ForceRefresh unconditionally gets a new token
-func (c *Client) ForceRefresh() error {
+func (c *Client) ForceRefresh(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	defer c.lockToken()()
+
+	// A token issued by another process revokes ours: adopt it rather than
+	// issuing again and revoking theirs in turn
+	stale := c.AccessToken
+	if err := c.loadToken(); err == nil && c.AccessToken != stale && time.Now().Before(c.TokenExp.Add(-10*time.Minute)) {
+		logKIS("ForceRefresh: Using the newer token from the store (expires at %s)", c.TokenExp.Format("15:04:05"))
+		return nil
+	}
 
 	logKIS("ForceRefresh: Requesting new token...")
-	return c.issueToken()
+	return c.issueToken(ctx)
 }
 
-func (c *Client) EnsureToken() error {
+func (c *Client) EnsureToken(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -66,25 +157,26 @@ func (c *Client) EnsureToken() error {
 		return nil
 	}
 
-	// 2. Check file
-	if c.AccessToken == "" {
-		if err := c.loadTokenFromFile(); err == nil {
-			if time.Now().Before(c.TokenExp.Add(-10 * time.Minute)) {
-				logKIS("Token load from file valid (expires at %s, %v remaining)",
-					c.TokenExp.Format("15:04:05"),
-					time.Until(c.TokenExp).Round(time.Second))
-				return nil
-			}
+	// 2. Check the store, under its lock: another process may have just refreshed
+	defer c.lockToken()()
+	if err := c.loadToken(); err == nil {
+		if time.Now().Before(c.TokenExp.Add(-10 * time.Minute)) {
+			logKIS("Token load from store valid (expires at %s, %v remaining)",
+				c.TokenExp.Format("15:04:05"),
+				time.Until(c.TokenExp).Round(time.Second))
+			return nil
 		}
+	} else if !errors.Is(err, credstore.ErrNotFound) {
+		logKIS("Warning: Failed to load token from store: %v", err)
 	}
 
 	// 3. Issue new token
-	return c.issueToken()
+	return c.issueToken(ctx)
 }
 
 // issueToken performs the actual API call to get a new token
 // It assumes the caller holds the lock
-func (c *Client) issueToken() error {
+func (c *Client) issueToken(ctx context.Context) error {
 	logKIS("Requesting new token from API...")
 
 	url := fmt.Sprintf("%s/oauth2/tokenP", c.Config.KisBaseURL)
@@ -97,7 +189,7 @@ func (c *Client) issueToken() error {
 
 	logKIS("POST %s (requesting OAuth token)", url)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		logKIS("✗ Failed to create token request: %v", err)
 		return err
@@ -114,7 +206,7 @@ func (c *Client) issueToken() error {
 	if resp.StatusCode != 200 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		logKIS("✗ Auth failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-		return fmt.Errorf("auth failed with status: %d", resp.StatusCode)
+		return tokenError(resp.StatusCode, bodyBytes)
 	}
 
 	var authResp AuthResponse
@@ -123,12 +215,12 @@ func (c *Client) issueToken() error {
 		return err
 	}
 
+	credstore.Register(authResp.AccessToken)
 	c.AccessToken = authResp.AccessToken
 	// Usually expires in 86400 seconds (24 hours)
 	c.TokenExp = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
 
-	// Save to file
-	c.saveTokenToFile()
+	c.saveToken()
 
 	logKIS("✓ Token refreshed successfully")
 	logKIS("  Token expires at: %s (%v from now)",
@@ -138,42 +230,54 @@ func (c *Client) issueToken() error {
 	return nil
 }
 
-func (c *Client) loadTokenFromFile() error {
-	file, err := os.Open(TokenFile)
+// loadToken reads the cached token, importing a legacy kis_token.json once
+func (c *Client) loadToken() error {
+	if c.Store == nil {
+		return credstore.ErrNotFound
+	}
+	raw, err := c.Store.Get(c.tokenName())
+	if errors.Is(err, credstore.ErrNotFound) {
+		raw, err = c.importLegacyToken()
+	}
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	var data struct {
-		AccessToken string    `json:"access_token"`
-		TokenExp    time.Time `json:"token_exp"`
-	}
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
+	var data storedToken
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return err
 	}
-
+	credstore.Register(data.AccessToken)
 	c.AccessToken = data.AccessToken
 	c.TokenExp = data.TokenExp
 	return nil
 }
 
-func (c *Client) saveTokenToFile() {
-	data := struct {
-		AccessToken string    `json:"access_token"`
-		TokenExp    time.Time `json:"token_exp"`
-	}{
-		AccessToken: c.AccessToken,
-		TokenExp:    c.TokenExp,
+// importLegacyToken moves a plaintext kis_token.json of the working directory into the store
+func (c *Client) importLegacyToken() ([]byte, error) {
+	raw, err := os.ReadFile(legacyTokenFile)
+	if err != nil {
+		return nil, credstore.ErrNotFound
+	}
+	if err := c.Store.Put(c.tokenName(), raw); err != nil {
+		return nil, err
 	}
+	if err := os.Remove(legacyTokenFile); err != nil {
+		logKIS("Warning: Imported %s into the credential store but cannot delete it: %v", legacyTokenFile, err)
+	} else {
+		logKIS("Imported %s into the credential store and deleted it", legacyTokenFile)
+	}
+	return raw, nil
+}
 
-	file, err := os.Create(TokenFile)
-	if err != nil {
-		logKIS("Warning: Failed to save token to file: %v", err)
+func (c *Client) saveToken() {
+	if c.Store == nil {
 		return
 	}
-	defer file.Close()
-	json.NewEncoder(file).Encode(data)
+	raw, _ := json.Marshal(storedToken{AccessToken: c.AccessToken, TokenExp: c.TokenExp})
+	if err := c.Store.Put(c.tokenName(), raw); err != nil {
+		logKIS("Warning: Failed to save token to store: %v", err)
+	}
 }
 
 // Price Response
@@ -199,10 +303,10 @@ func (c *Client) getAccountParts() (string, string) {
 	return acc, "01"
 }
 
-func (c *Client) GetCurrentPrice(exchCode, symbol string) (float64, error) {
+func (c *Client) GetCurrentPrice(ctx context.Context, exchCode, symbol string) (float64, error) {
 	logKIS("GetCurrentPrice: Fetching price for %s:%s", exchCode, symbol)
 
-	if err := c.EnsureToken(); err != nil {
+	if err := c.EnsureToken(ctx); err != nil {
 		logKIS("✗ GetCurrentPrice: Token error: %v", err)
 		return 0, err
 	}
@@ -210,33 +314,18 @@ func (c *Client) GetCurrentPrice(exchCode, symbol string) (float64, error) {
 	url := fmt.Sprintf("%s/uapi/overseas-price/v1/quotations/price?AUTH=&EXCD=%s&SYMB=%s", c.Config.KisBaseURL, exchCode, symbol)
 	logKIS("GET %s", url)
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		logKIS("✗ GetCurrentPrice: Failed to create request: %v", err)
-		return 0, err
-	}
-
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("authorization", "Bearer "+c.AccessToken)
-	req.Header.Set("appkey", c.Config.KisAppKey)
-	req.Header.Set("appsecret", c.Config.KisAppSecret)
-	req.Header.Set("tr_id", "HHDFS76200200") // Overseas Stock Price
-
-	resp, err := c.Client.Do(req)
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiPrice, Idempotent: true}) // Overseas Stock Price
 	if err != nil {
 		logKIS("✗ GetCurrentPrice: Request failed: %v", err)
 		return 0, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
 		logKIS("✗ GetCurrentPrice: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
-		return 0, fmt.Errorf("bad status: %d", resp.StatusCode)
+		return 0, c.apiError(apiPrice, resp.StatusCode, bodyBytes)
 	}
 
-	// Read response for debugging
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	// Log response for debugging
 	logKIS("GetCurrentPrice: Raw response: %s", string(bodyBytes))
 
 	var pResp PriceResponse
@@ -248,7 +337,7 @@ func (c *Client) GetCurrentPrice(exchCode, symbol string) (float64, error) {
 	// Success codes: "0" or "0000"
 	if pResp.RtCd != "0" && pResp.RtCd != "0000" {
 		logKIS("✗ GetCurrentPrice: API error (RtCd=%s): %s", pResp.RtCd, pResp.Msg1)
-		return 0, fmt.Errorf("api error: %s", pResp.Msg1)
+		return 0, c.apiError(apiPrice, resp.StatusCode, bodyBytes)
 	}
 
 	// Parse price (string to float)
@@ -258,63 +347,98 @@ func (c *Client) GetCurrentPrice(exchCode, symbol string) (float64, error) {
 	return price, nil
 }
 
+// Period codes of the daily price API (GUBN)
+const (
+	GubnDay   = "0"
+	GubnWeek  = "1"
+	GubnMonth = "2"
+)
+
+// DailyPriceQuery selects rows of the overseas period price API
+type DailyPriceQuery struct {
+	ExchCode string // Quote exchange code: NAS, NYS, AMS
+	Symbol   string
+	Gubn     string // GubnDay (default), GubnWeek or GubnMonth
+	Adjusted bool   // MODP=1: prices adjusted for splits and dividends
+	Start    string // Oldest date to include (YYYYMMDD), empty = no bound
+	End      string // Newest date to include (YYYYMMDD), empty = latest
+	Limit    int    // Max rows, 0 = everything back to Start
+}
+
 // DailyPriceResponse for history
 type DailyPriceResponse struct {
+	Output1 struct {
+		Symbol  string `json:"rsym"`
+		Decimal string `json:"zdiv"` // Price decimal places
+		Records string `json:"nrec"`
+	} `json:"output1"`
 	Output2 []struct {
-		Date  string `json:"xymd"` // YYYYMMDD
-		Close string `json:"clos"` // Close Price
+		Date   string `json:"xymd"` // YYYYMMDD
+		Close  string `json:"clos"`
+		Sign   string `json:"sign"`
+		Diff   string `json:"diff"`
+		Rate   string `json:"rate"`
+		Open   string `json:"open"`
+		High   string `json:"high"`
+		Low    string `json:"low"`
+		Volume string `json:"tvol"`
+		Amount string `json:"tamt"` // Traded value
 	} `json:"output2"`
 	RtCd string `json:"rt_cd"`
 	Msg1 string `json:"msg1"`
 }
 
-// DailyPriceItem simplified struct for return
+// DailyPriceItem is one parsed period row
 type DailyPriceItem struct {
-	Date  string
-	Close float64
+	Date     string
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   int64
+	Amount   float64
+	Adjusted bool
 }
 
-// GetDailyPrice fetches at least n days of history
-func (c *Client) GetDailyPrice(exchCode, symbol string, days int) ([]DailyPriceItem, error) {
-	logKIS("GetDailyPrice: Fetching last %d days for %s:%s", days, exchCode, symbol)
+// GetDailyPrice fetches period rows newest first, paging back with BYMD
+// until q.Start or q.Limit is reached
+func (c *Client) GetDailyPrice(ctx context.Context, q DailyPriceQuery) ([]DailyPriceItem, error) {
+	if q.Start == "" && q.Limit <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "daily price query needs a start date or a row limit")
+	}
+	if q.Gubn == "" {
+		q.Gubn = GubnDay
+	}
+	modp := "0"
+	if q.Adjusted {
+		modp = "1"
+	}
+	logKIS("GetDailyPrice: %s:%s GUBN=%s MODP=%s range [%s, %s] limit %d",
+		q.ExchCode, q.Symbol, q.Gubn, modp, q.Start, q.End, q.Limit)
 
-	if err := c.EnsureToken(); err != nil {
+	if err := c.EnsureToken(ctx); err != nil {
 		return nil, err
 	}
 
 	var allPrices []DailyPriceItem
-	nextDate := "" // Empty for latest
+	nextDate := q.End // Empty for latest
 
-	for len(allPrices) < days {
+	for q.Limit <= 0 || len(allPrices) < q.Limit {
 		// API limit per call is usually 100
-		url := fmt.Sprintf("%s/uapi/overseas-price/v1/quotations/dailyprice?AUTH=&EXCD=%s&SYMB=%s&GUBN=0&BYMD=%s&MODP=1",
-			c.Config.KisBaseURL, exchCode, symbol, nextDate)
-		logKIS("GET %s (Collected: %d/%d)", url, len(allPrices), days)
-
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("content-type", "application/json")
-		req.Header.Set("authorization", "Bearer "+c.AccessToken)
-		req.Header.Set("appkey", c.Config.KisAppKey)
-		req.Header.Set("appsecret", c.Config.KisAppSecret)
-		req.Header.Set("tr_id", "HHDFS76240000") // Overseas Daily Price
+		url := fmt.Sprintf("%s/uapi/overseas-price/v1/quotations/dailyprice?AUTH=&EXCD=%s&SYMB=%s&GUBN=%s&BYMD=%s&MODP=%s",
+			c.Config.KisBaseURL, q.ExchCode, q.Symbol, q.Gubn, nextDate, modp)
+		logKIS("GET %s (Collected: %d)", url, len(allPrices))
 
-		resp, err := c.Client.Do(req)
+		resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiDailyPrice, Idempotent: true}) // Overseas Daily Price
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
 
 		if resp.StatusCode != 200 {
-			bodyBytes, _ := io.ReadAll(resp.Body)
 			logKIS("✗ GetDailyPrice: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
-			return nil, fmt.Errorf("daily price failed status: %d", resp.StatusCode)
+			return nil, c.apiError(apiDailyPrice, resp.StatusCode, bodyBytes)
 		}
 
-		bodyBytes, _ := io.ReadAll(resp.Body)
 		var dpResp DailyPriceResponse
 		if err := json.Unmarshal(bodyBytes, &dpResp); err != nil {
 			return nil, err
@@ -322,36 +446,45 @@ func (c *Client) GetDailyPrice(exchCode, symbol string, days int) ([]DailyPriceI
 
 		if dpResp.RtCd != "0" && dpResp.RtCd != "0000" {
 			logKIS("✗ GetDailyPrice: API error: %s", dpResp.Msg1)
-			return nil, fmt.Errorf("api error: %s", dpResp.Msg1)
-		}
-
-		if len(dpResp.Output2) == 0 {
-			break // No more data
+			return nil, c.apiError(apiDailyPrice, resp.StatusCode, bodyBytes)
 		}
 
+		// BYMD is inclusive, so each page repeats the previous page's oldest row
+		added := 0
+		reachedStart := false
 		for _, item := range dpResp.Output2 {
-			var closePrice float64
-			fmt.Sscanf(item.Close, "%f", &closePrice)
+			if item.Date == "" {
+				continue
+			}
+			if n := len(allPrices); n > 0 && item.Date >= allPrices[n-1].Date {
+				continue
+			}
+			if q.Start != "" && item.Date < q.Start {
+				reachedStart = true
+				break
+			}
 			allPrices = append(allPrices, DailyPriceItem{
-				Date:  item.Date,
-				Close: closePrice,
+				Date:     item.Date,
+				Open:     parseFloat(item.Open),
+				High:     parseFloat(item.High),
+				Low:      parseFloat(item.Low),
+				Close:    parseFloat(item.Close),
+				Volume:   int64(parseFloat(item.Volume)),
+				Amount:   parseFloat(item.Amount),
+				Adjusted: q.Adjusted,
 			})
+			added++
 		}
 
-		// Prepare next cursor (oldest date)
-		oldest := dpResp.Output2[len(dpResp.Output2)-1].Date
-		if oldest == nextDate {
-			break // Infinite loop protection
+		if added == 0 || reachedStart {
+			break // No more data (or nothing new: infinite loop protection)
 		}
-		nextDate = oldest
-
-		// Small delay to be polite
-		time.Sleep(100 * time.Millisecond)
+		nextDate = allPrices[len(allPrices)-1].Date
 	}
 
 	logKIS("✓ GetDailyPrice: Collected %d records", len(allPrices))
-	if len(allPrices) > days {
-		allPrices = allPrices[:days]
+	if q.Limit > 0 && len(allPrices) > q.Limit {
+		allPrices = allPrices[:q.Limit]
 	}
 	return allPrices, nil
 }
@@ -362,7 +495,7 @@ type OrderReq struct {
 	Symbol   string
 	Qty      int
 	Price    float64
-	OrdType  string // 00: Limit, 01: Market, 34: LOC ? (Need verification)
+	OrdType  OrdDvsn
 	Side     string // BUY or SELL
 }
 
@@ -378,21 +511,44 @@ type OrderResponse struct {
 	} `json:"output"`
 }
 
-func (c *Client) PlaceOrder(o OrderReq) error {
+// formatOrderPrice keeps the four decimals sub-dollar ticks need
+func formatOrderPrice(price float64) string {
+	if price > 0 && price < 1 {
+		return fmt.Sprintf("%.4f", price)
+	}
+	return fmt.Sprintf("%.2f", price)
+}
+
+// PlaceOrder submits an order and returns the KIS order number (ODNO)
+func (c *Client) PlaceOrder(ctx context.Context, o OrderReq) (string, error) {
 	logKIS("PlaceOrder: %s %d shares of %s:%s at $%.2f (type: %s)",
 		o.Side, o.Qty, o.ExchCode, o.Symbol, o.Price, o.OrdType)
 
-	if err := c.EnsureToken(); err != nil {
+	if err := o.OrdType.Validate(o.Side, c.mode, o.Price); err != nil {
+		logKIS("✗ PlaceOrder: %v", err)
+		return "", err
+	}
+	price := o.Price
+	if !o.OrdType.HasPrice() {
+		price = 0
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
 		logKIS("✗ PlaceOrder: Token error: %v", err)
-		return err
+		return "", err
+	}
+
+	// Never resend an order whose previous attempt may have reached KIS
+	if err := c.checkAmbiguous(o); err != nil {
+		logKIS("✗ PlaceOrder: %v", err)
+		return "", err
 	}
 
-	trID := "TTTT1002U" // Buy (Real)
+	api := apiBuy
 	if o.Side == "SELL" {
-		trID = "TTTT1006U" // Sell (Real)
+		api = apiSell
 	}
-	logKIS("PlaceOrder: Using TR_ID=%s for %s order", trID, o.Side)
-	// TODO: Handle Virtual Trading TR IDs if needed (JTTT1002U, JTTT1006U)
+	logKIS("PlaceOrder: Using TR_ID=%s for %s order (%s)", c.trID(api), o.Side, c.mode)
 
 	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/order", c.Config.KisBaseURL)
 	logKIS("POST %s", url)
@@ -405,38 +561,25 @@ func (c *Client) PlaceOrder(o OrderReq) error {
 		"OVRS_EXCG_CD":    o.ExchCode,
 		"PDNO":            o.Symbol,
 		"ORD_QTY":         fmt.Sprintf("%d", o.Qty),
-		"OVRS_ORD_UNPR":   fmt.Sprintf("%.2f", o.Price),
+		"OVRS_ORD_UNPR":   formatOrderPrice(price),
 		"ORD_SVR_DVSN_CD": "0",
-		"ORD_DVSN":        o.OrdType,
+		"ORD_DVSN":        string(o.OrdType),
 	}
 	jsonBody, _ := json.Marshal(body)
 	logKIS("PlaceOrder: Request body: %s", string(jsonBody))
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		logKIS("✗ PlaceOrder: Failed to create request: %v", err)
-		return err
-	}
-
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("authorization", "Bearer "+c.AccessToken)
-	req.Header.Set("appkey", c.Config.KisAppKey)
-	req.Header.Set("appsecret", c.Config.KisAppSecret)
-	req.Header.Set("tr_id", trID)
-
-	resp, err := c.Client.Do(req)
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "POST", URL: url, API: api, Body: jsonBody})
 	if err != nil {
+		if errors.Is(err, ErrAmbiguous) {
+			c.markAmbiguous(o)
+		}
 		logKIS("✗ PlaceOrder: Request failed: %v", err)
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
-
-	// Read response body for logging
-	bodyBytes, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != 200 {
 		logKIS("✗ PlaceOrder: Failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-		return fmt.Errorf("order failed status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return "", c.apiError(api, resp.StatusCode, bodyBytes)
 	}
 
 	// Parse response for detailed logging
@@ -447,24 +590,30 @@ func (c *Client) PlaceOrder(o OrderReq) error {
 				orderResp.Output.ODNO, orderResp.Output.ORD_TMD, orderResp.Msg1)
 		} else {
 			logKIS("⚠ PlaceOrder: Response Code: %s, Msg: %s", orderResp.RtCd, orderResp.Msg1)
-			return fmt.Errorf("api error: %s (Code: %s)", orderResp.Msg1, orderResp.RtCd)
+			return "", c.apiError(api, resp.StatusCode, bodyBytes)
 		}
 	} else {
 		logKIS("✓ PlaceOrder: Completed (raw response: %s)", string(bodyBytes))
 	}
 
-	return nil
+	return orderResp.Output.ODNO, nil
+}
+
+// BalanceHolding is one row of the balance inquiry (output1)
+type BalanceHolding struct {
+	ExchCode    string `json:"ovrs_excg_cd"`
+	Symbol      string `json:"ovrs_pdno"`
+	Qty         string `json:"ovrs_cblc_qty"`      // Holding Qty
+	AvgPrice    string `json:"pchs_avg_pric"`      // Avg Purchase Price
+	NowPrice    string `json:"now_pric2"`          // Current Price
+	PurchaseAmt string `json:"frcr_pchs_amt1"`     // Purchase Amount
+	EvalAmt     string `json:"ovrs_stck_evlu_amt"` // Evaluation Amount
+	EvalPL      string `json:"frcr_evlu_pfls_amt"` // Evaluation P/L
 }
 
 // Balance Response
 type BalanceResponse struct {
-	Output1 []struct {
-		ExchCode string `json:"ovrs_excg_cd"`
-		Symbol   string `json:"ovrs_pdno"`
-		Qty      string `json:"ovrs_cblc_qty"` // Holding Qty
-		AvgPrice string `json:"pchs_avg_pric"` // Avg Purchase Price
-		NowPrice string `json:"now_pric2"`     // Current Price
-	} `json:"output1"`
+	Output1 []BalanceHolding `json:"output1"`
 	Output2 struct {
 		TotalAmt      string `json:"tot_evlu_pfls_amt"`  // Total Evaluation Amount
 		TotalPurchase string `json:"frcr_pchs_amt1"`     // Total Purchase Amount (invested)
@@ -472,78 +621,145 @@ type BalanceResponse struct {
 		TotalPLRate   string `json:"tot_pftrt"`          // Total P/L Rate (%)
 		RealizedPL    string `json:"ovrs_rlzt_pfls_amt"` // Realized P/L
 	} `json:"output2"`
-	RtCd string `json:"rt_cd"`
-	Msg1 string `json:"msg1"`
+	CtxAreaFK200 string `json:"ctx_area_fk200"` // Continuation keys
+	CtxAreaNK200 string `json:"ctx_area_nk200"`
+	RtCd         string `json:"rt_cd"`
+	Msg1         string `json:"msg1"`
 }
 
-func (c *Client) GetBalance() (*BalanceResponse, error) {
-	logKIS("GetBalance: Fetching portfolio balance...")
+// balanceExchanges are the US exchange codes walked by GetBalance.
+// PFIX, SCHD and TMF trade on AMEX and are missing from a NASD-only inquiry.
+var balanceExchanges = []string{"NASD", "NYSE", "AMEX"}
 
-	if err := c.EnsureToken(); err != nil {
+// maxBalancePages guards against a continuation loop
+const maxBalancePages = 20
+
+// GetBalance queries every US exchange, follows CTX_AREA continuation and
+// merges the pages into one holdings list. Totals are recomputed from the merged holdings.
+func (c *Client) GetBalance(ctx context.Context) (*BalanceResponse, error) {
+	logKIS("GetBalance: Fetching portfolio balance (%v)...", balanceExchanges)
+
+	if err := c.EnsureToken(ctx); err != nil {
 		logKIS("✗ GetBalance: Token error: %v", err)
 		return nil, err
 	}
 
-	cano, prdt := c.getAccountParts()
+	merged := &BalanceResponse{RtCd: "0"}
+	seen := make(map[string]bool)
+	var realized float64
 
-	// Note: CTX_AREA keys are for pagination, empty for first page
-	// API requires FK200/NK200, not FK100/NK100
-	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-balance?AUTH=&CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=NASD&TR_CRCY_CD=USD&CTX_AREA_FK200=&CTX_AREA_NK200=", c.Config.KisBaseURL, cano, prdt)
-	logKIS("GET %s", url)
+	for _, exch := range balanceExchanges {
+		fk, nk := "", ""
+		overlaps := false
+		exchRealized := 0.0
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		logKIS("✗ GetBalance: Failed to create request: %v", err)
-		return nil, err
+		for page := 1; ; page++ {
+			bResp, more, err := c.getBalancePage(ctx, exch, fk, nk, page > 1)
+			if err != nil {
+				return nil, err
+			}
+			if page == 1 {
+				exchRealized = parseFloat(bResp.Output2.RealizedPL)
+				merged.Msg1 = bResp.Msg1
+			}
+
+			for _, h := range bResp.Output1 {
+				if seen[h.Symbol] {
+					// Real accounts may return all US holdings for NASD: skip repeats
+					overlaps = true
+					continue
+				}
+				seen[h.Symbol] = true
+				merged.Output1 = append(merged.Output1, h)
+			}
+
+			if !more || page >= maxBalancePages {
+				break
+			}
+			fk, nk = bResp.CtxAreaFK200, bResp.CtxAreaNK200
+		}
+
+		// An exchange repeating holdings we already have also repeats their summary
+		if !overlaps {
+			realized += exchRealized
+		}
 	}
 
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("authorization", "Bearer "+c.AccessToken)
-	req.Header.Set("appkey", c.Config.KisAppKey)
-	req.Header.Set("appsecret", c.Config.KisAppSecret)
-	req.Header.Set("tr_id", "TTTS3012R") // Real: TTTS3012R
+	var purchase, eval, pl float64
+	for _, h := range merged.Output1 {
+		purchase += parseFloat(h.PurchaseAmt)
+		eval += parseFloat(h.EvalAmt)
+		pl += parseFloat(h.EvalPL)
+	}
+	plRate := 0.0
+	if purchase > 0 {
+		plRate = pl / purchase * 100
+	}
+	merged.Output2.TotalAmt = fmt.Sprintf("%.2f", eval)
+	merged.Output2.TotalPurchase = fmt.Sprintf("%.2f", purchase)
+	merged.Output2.TotalPL = fmt.Sprintf("%.2f", pl)
+	merged.Output2.TotalPLRate = fmt.Sprintf("%.2f", plRate)
+	merged.Output2.RealizedPL = fmt.Sprintf("%.2f", realized)
 
-	resp, err := c.Client.Do(req)
+	logKIS("✓ GetBalance: Found %d holdings, Total Evaluation: %s", len(merged.Output1), merged.Output2.TotalAmt)
+	for i, h := range merged.Output1 {
+		logKIS("  [%d] %s:%s - Qty: %s, AvgPrice: %s", i+1, h.ExchCode, h.Symbol, h.Qty, h.AvgPrice)
+	}
+
+	return merged, nil
+}
+
+// getBalancePage fetches one page of the balance inquiry for exch.
+// more reports whether KIS has another page (tr_cont F/M).
+func (c *Client) getBalancePage(ctx context.Context, exch, fk, nk string, cont bool) (*BalanceResponse, bool, error) {
+	cano, prdt := c.getAccountParts()
+
+	// API requires FK200/NK200, not FK100/NK100
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-balance?AUTH=&CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=%s&TR_CRCY_CD=USD&CTX_AREA_FK200=%s&CTX_AREA_NK200=%s",
+		c.Config.KisBaseURL, cano, prdt, exch, neturl.QueryEscape(fk), neturl.QueryEscape(nk))
+	logKIS("GET %s", url)
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiBalance, Cont: cont, Idempotent: true})
 	if err != nil {
 		logKIS("✗ GetBalance: Request failed: %v", err)
-		return nil, err
+		return nil, false, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
 		logKIS("✗ GetBalance: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("balance failed status: %d", resp.StatusCode)
+		return nil, false, c.apiError(apiBalance, resp.StatusCode, bodyBytes)
 	}
 
-	// Read body for debugging
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	logKIS("GetBalance: Raw response: %s", string(bodyBytes))
+	// Log body for debugging
+	logKIS("GetBalance: Raw response (%s): %s", exch, string(bodyBytes))
 
 	var bResp BalanceResponse
 	if err := json.Unmarshal(bodyBytes, &bResp); err != nil {
 		logKIS("✗ GetBalance: Failed to decode response: %v", err)
-		return nil, err
+		return nil, false, err
 	}
 
 	// Success codes: "0" or "0000"
 	if bResp.RtCd != "0" && bResp.RtCd != "0000" {
 		logKIS("✗ GetBalance: API error (RtCd=%s): %s", bResp.RtCd, bResp.Msg1)
-		return nil, fmt.Errorf("api error: %s", bResp.Msg1)
+		return nil, false, c.apiError(apiBalance, resp.StatusCode, bodyBytes)
 	}
 
-	logKIS("✓ GetBalance: Found %d holdings, Total P/L: %s", len(bResp.Output1), bResp.Output2.TotalAmt)
-	for i, h := range bResp.Output1 {
-		logKIS("  [%d] %s:%s - Qty: %s, AvgPrice: %s", i+1, h.ExchCode, h.Symbol, h.Qty, h.AvgPrice)
-	}
-
-	return &bResp, nil
+	trCont := resp.Header.Get("tr_cont")
+	more := (trCont == "F" || trCont == "M") && strings.TrimSpace(bResp.CtxAreaNK200) != ""
+	return &bResp, more, nil
 }
 
-// BuyingPowerResponse for overseas stock buying power inquiry
+// BuyingPowerResponse for overseas stock buying power inquiry (매수가능금액조회)
 type BuyingPowerResponse struct {
 	Output struct {
-		OvrsOrdPsblAmt string `json:"ovrs_ord_psbl_amt"` // Overseas order available amount (USD)
+		OvrsOrdPsblAmt string `json:"ovrs_ord_psbl_amt"`     // Overseas order available amount (USD)
+		OrdPsblFrcrAmt string `json:"ord_psbl_frcr_amt"`     // Orderable foreign currency (USD cash)
+		SellReuseAmt   string `json:"sll_ruse_psbl_amt"`     // Sale proceeds reusable for orders
+		MaxOrdPsblQty  string `json:"max_ord_psbl_qty"`      // Max shares at the queried price
+		OrdPsblQty     string `json:"ord_psbl_qty"`          // Shares payable with USD cash alone
+		OvrsMaxOrdQty  string `json:"ovrs_max_ord_psbl_qty"` // Max shares including KRW auto-exchange
+		ExchangeRate   string `json:"exrt"`
 		MaxBuyAmt      string `json:"max_buy_amt"`       // Maximum buy amount
 		FrcrOrdPsblAmt string `json:"frcr_ord_psbl_amt"` // Foreign currency order available
 	} `json:"output"`
@@ -551,41 +767,38 @@ type BuyingPowerResponse struct {
 	Msg1 string `json:"msg1"`
 }
 
-// GetBuyingPower fetches available buying power for overseas stock trading
-func (c *Client) GetBuyingPower() (*BuyingPowerResponse, error) {
-	logKIS("GetBuyingPower: Fetching available buying power...")
+// BuyingPowerQuery selects the symbol and limit price the orderable quantity
+// is computed for. Price 0 lets KIS use the current price.
+type BuyingPowerQuery struct {
+	ExchCode string // NASD, NYSE, AMEX
+	Symbol   string
+	Price    float64
+}
+
+// cashQuery is used when only the account-wide amount matters: ITEM_CD is
+// mandatory, but the orderable amount does not depend on it
+var cashQuery = BuyingPowerQuery{ExchCode: "NASD", Symbol: "TQQQ"}
 
-	if err := c.EnsureToken(); err != nil {
+// GetBuyingPower fetches the orderable amount and the max quantity of q.Symbol at q.Price
+func (c *Client) GetBuyingPower(ctx context.Context, q BuyingPowerQuery) (*BuyingPowerResponse, error) {
+	logKIS("GetBuyingPower: %s:%s at $%.2f", q.ExchCode, q.Symbol, q.Price)
+
+	if err := c.EnsureToken(ctx); err != nil {
 		logKIS("✗ GetBuyingPower: Token error: %v", err)
 		return nil, err
 	}
 
 	cano, prdt := c.getAccountParts()
 
-	// Use buying power inquiry API (매수가능금액 조회)
-	// Note: ITEM_CD is required, using TQQQ as default for general buying power check
-	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-psamount?CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=NASD&OVRS_ORD_UNPR=0&ITEM_CD=TQQQ",
-		c.Config.KisBaseURL, cano, prdt)
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-psamount?CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=%s&OVRS_ORD_UNPR=%s&ITEM_CD=%s",
+		c.Config.KisBaseURL, cano, prdt, q.ExchCode, formatOrderPrice(q.Price), q.Symbol)
 	logKIS("GET %s", url)
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("authorization", "Bearer "+c.AccessToken)
-	req.Header.Set("appkey", c.Config.KisAppKey)
-	req.Header.Set("appsecret", c.Config.KisAppSecret)
-	req.Header.Set("tr_id", "TTTS3007R") // Overseas stock buying power inquiry (Real)
-
-	resp, err := c.Client.Do(req)
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiBuyingPower, Idempotent: true}) // Overseas stock buying power inquiry
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
 	logKIS("GetBuyingPower: Raw response: %s", string(bodyBytes))
 
 	var bpResp BuyingPowerResponse
@@ -595,9 +808,9 @@ func (c *Client) GetBuyingPower() (*BuyingPowerResponse, error) {
 
 	if bpResp.RtCd != "0" && bpResp.RtCd != "0000" {
 		logKIS("✗ GetBuyingPower: API error (RtCd=%s): %s", bpResp.RtCd, bpResp.Msg1)
-		return nil, fmt.Errorf("api error: %s", bpResp.Msg1)
+		return nil, c.apiError(apiBuyingPower, resp.StatusCode, bodyBytes)
 	}
 
-	logKIS("✓ GetBuyingPower: Available: $%s", bpResp.Output.OvrsOrdPsblAmt)
+	logKIS("✓ GetBuyingPower: Available: $%s, Max Qty: %s", bpResp.Output.OvrsOrdPsblAmt, bpResp.Output.MaxOrdPsblQty)
 	return &bpResp, nil
 }
@@ -0,0 +1,143 @@
+package kis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// APIError is a failed KIS call: a non-200 response or rt_cd other than 0
+type APIError struct {
+	StatusCode int                  `json:"status_code"`
+	RtCd       string               `json:"rt_cd"`
+	MsgCd      string               `json:"msg_cd"`
+	Msg1       string               `json:"msg1"`
+	TrID       string               `json:"tr_id"`
+	Category   broker.ErrorCategory `json:"category"`
+}
+
+func (e *APIError) Error() string {
+	msg := strings.TrimSpace(e.Msg1)
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("kis api error: %s (msg_cd=%s, rt_cd=%s, tr_id=%s, status=%d)",
+		msg, e.MsgCd, e.RtCd, e.TrID, e.StatusCode)
+}
+
+func (e *APIError) ErrorCategory() broker.ErrorCategory {
+	return e.Category
+}
+
+// msgCodeCategories catalogues the KIS gateway message codes we know about
+var msgCodeCategories = map[string]broker.ErrorCategory{
+	"EGW00102": broker.CategoryAuth,      // AppKey/AppSecret 오류
+	"EGW00103": broker.CategoryAuth,      // 유효하지 않은 AppKey
+	"EGW00105": broker.CategoryAuth,      // 유효하지 않은 AppSecret
+	"EGW00121": broker.CategoryAuth,      // 유효하지 않은 token
+	"EGW00123": broker.CategoryAuth,      // 기간이 만료된 token
+	"EGW00133": broker.CategoryRateLimit, // 접근토큰 발급 1분당 1회
+	"EGW00201": broker.CategoryRateLimit, // 초당 거래건수를 초과하였습니다
+	"EGW00215": broker.CategoryRateLimit, // 거래건수 초과
+}
+
+// msgKeywordCategories classifies business errors by msg1. Order/inquiry
+// message codes differ per TR, so the text is more reliable than the code.
+// Checked in order: funds and market hours before generic validation words.
+var msgKeywordCategories = []struct {
+	Keyword  string
+	Category broker.ErrorCategory
+}{
+	{"장운영", broker.CategoryMarketClosed},
+	{"장 운영", broker.CategoryMarketClosed},
+	{"장종료", broker.CategoryMarketClosed},
+	{"장 종료", broker.CategoryMarketClosed},
+	{"장마감", broker.CategoryMarketClosed},
+	{"주문가능시간", broker.CategoryMarketClosed},
+	{"주문 가능 시간", broker.CategoryMarketClosed},
+	{"휴장", broker.CategoryMarketClosed},
+	{"주문가능금액", broker.CategoryFunds},
+	{"매수가능금액", broker.CategoryFunds},
+	{"잔고", broker.CategoryFunds},
+	{"증거금", broker.CategoryFunds},
+	{"매도가능수량", broker.CategoryFunds},
+	{"부족", broker.CategoryFunds},
+	{"종목", broker.CategoryValidation},
+	{"호가", broker.CategoryValidation},
+	{"단가", broker.CategoryValidation},
+	{"수량", broker.CategoryValidation},
+	{"주문구분", broker.CategoryValidation},
+	{"입력", broker.CategoryValidation},
+	{"INPUT", broker.CategoryValidation},
+}
+
+// categorize maps a KIS response to an error category
+func categorize(status int, msgCd, msg1 string) broker.ErrorCategory {
+	if cat, ok := msgCodeCategories[msgCd]; ok {
+		return cat
+	}
+	if strings.HasPrefix(msgCd, "OPSQ") {
+		return broker.CategoryValidation // Gateway input checks (service code, field size)
+	}
+	for _, k := range msgKeywordCategories {
+		if strings.Contains(msg1, k.Keyword) {
+			return k.Category
+		}
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return broker.CategoryAuth
+	case status == http.StatusTooManyRequests:
+		return broker.CategoryRateLimit
+	case status >= 500:
+		return broker.CategoryUnavailable
+	}
+	return broker.CategoryUnknown
+}
+
+// apiError builds an APIError for api from a raw response
+func (c *Client) apiError(api string, status int, body []byte) *APIError {
+	var env envelope
+	json.Unmarshal(body, &env)
+	if env.Msg1 == "" && status != http.StatusOK {
+		env.Msg1 = strings.TrimSpace(string(body))
+		if len(env.Msg1) > 200 {
+			env.Msg1 = env.Msg1[:200] + "..."
+		}
+	}
+
+	return &APIError{
+		StatusCode: status,
+		RtCd:       env.RtCd,
+		MsgCd:      env.MsgCd,
+		Msg1:       env.Msg1,
+		TrID:       c.trID(api),
+		Category:   categorize(status, env.MsgCd, env.Msg1),
+	}
+}
+
+// tokenError builds an APIError from a failed /oauth2/tokenP response,
+// which reports error_code/error_description instead of msg_cd/msg1
+func tokenError(status int, body []byte) *APIError {
+	var tResp struct {
+		Code        string `json:"error_code"`
+		Description string `json:"error_description"`
+	}
+	json.Unmarshal(body, &tResp)
+
+	cat := categorize(status, tResp.Code, tResp.Description)
+	if cat == broker.CategoryUnknown {
+		cat = broker.CategoryAuth
+	}
+	return &APIError{
+		StatusCode: status,
+		MsgCd:      tResp.Code,
+		Msg1:       tResp.Description,
+		TrID:       "tokenP",
+		Category:   cat,
+	}
+}
@@ -0,0 +1,88 @@
+package kis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode selects the KIS trading environment.
+type Mode string
+
+const (
+	ModeReal    Mode = "real"    // 실전투자 (openapi.koreainvestment.com)
+	ModeVirtual Mode = "virtual" // 모의투자 (openapivts.koreainvestment.com)
+)
+
+// API names used to look up TR IDs
+const (
+	apiPrice        = "price"
+	apiDailyPrice   = "dailyprice"
+	apiBuy          = "order-buy"
+	apiSell         = "order-sell"
+	apiBalance      = "inquire-balance"
+	apiBuyingPower  = "inquire-psamount"
+	apiOrderHistory = "inquire-ccnl"
+	apiUnfilled     = "inquire-nccs"
+	apiReviseCancel = "order-rvsecncl"
+	apiResvBuy      = "order-resv-buy"
+	apiResvSell     = "order-resv-sell"
+	apiResvCancel   = "order-resv-ccnl"
+	apiResvList     = "order-resv-list"
+	apiPeriodProfit = "inquire-period-profit"
+	apiPeriodTrans  = "inquire-period-trans"
+	apiPresent      = "inquire-present-balance"
+)
+
+// trIDs maps every call to its TR ID per mode (US market).
+// Quotation TR IDs are shared; trading TR IDs use the V prefix on VTS.
+// The legacy JTTT1002U/JTTT1006U IDs are no longer accepted by either environment.
+var trIDs = map[string]map[Mode]string{
+	apiPrice:        {ModeReal: "HHDFS76200200", ModeVirtual: "HHDFS76200200"},
+	apiDailyPrice:   {ModeReal: "HHDFS76240000", ModeVirtual: "HHDFS76240000"},
+	apiBuy:          {ModeReal: "TTTT1002U", ModeVirtual: "VTTT1002U"},
+	apiSell:         {ModeReal: "TTTT1006U", ModeVirtual: "VTTT1006U"},
+	apiBalance:      {ModeReal: "TTTS3012R", ModeVirtual: "VTTS3012R"},
+	apiBuyingPower:  {ModeReal: "TTTS3007R", ModeVirtual: "VTTS3007R"},
+	apiOrderHistory: {ModeReal: "TTTS3035R", ModeVirtual: "VTTS3035R"},
+	apiUnfilled:     {ModeReal: "TTTS3018R"}, // Real only
+	apiReviseCancel: {ModeReal: "TTTT1004U", ModeVirtual: "VTTT1004U"},
+	apiResvBuy:      {ModeReal: "TTTT3014U", ModeVirtual: "VTTT3014U"},
+	apiResvSell:     {ModeReal: "TTTT3016U", ModeVirtual: "VTTT3016U"},
+	apiResvCancel:   {ModeReal: "TTTT3017U", ModeVirtual: "VTTT3017U"},
+	apiResvList:     {ModeReal: "TTTT3039R"}, // Real only
+	apiPeriodProfit: {ModeReal: "TTTS3039R"}, // Real only
+	apiPeriodTrans:  {ModeReal: "CTOS4001R"}, // Real only
+	apiPresent:      {ModeReal: "CTRP6504R", ModeVirtual: "VTRP6504R"},
+}
+
+// ParseMode accepts "real"/"virtual" plus a few common aliases ("prod", "vts")
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "real", "prod", "live":
+		return ModeReal, nil
+	case "virtual", "vts", "mock":
+		return ModeVirtual, nil
+	}
+	return "", fmt.Errorf("invalid KIS mode: %q (expected real or virtual)", s)
+}
+
+// modeFromURL infers the environment from the base URL host
+func modeFromURL(baseURL string) Mode {
+	if strings.Contains(strings.ToLower(baseURL), "openapivts") {
+		return ModeVirtual
+	}
+	return ModeReal
+}
+
+// checkMode refuses a URL that points at the other environment
+func checkMode(mode Mode, baseURL string) error {
+	if urlMode := modeFromURL(baseURL); urlMode != mode {
+		return fmt.Errorf("KIS_MODE=%s but KIS_BASE_URL (%s) is a %s endpoint", mode, baseURL, urlMode)
+	}
+	return nil
+}
+
+// trID returns the TR ID for api in the client's mode
+func (c *Client) trID(api string) string {
+	return trIDs[api][c.mode]
+}
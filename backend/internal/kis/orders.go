@@ -0,0 +1,220 @@
+package kis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// OrderHistoryItem is one row of the overseas order/fill inquiry (주문체결내역)
+type OrderHistoryItem struct {
+	OrderDate    string `json:"ord_dt"`          // YYYYMMDD
+	OrderTime    string `json:"ord_tmd"`         // HHMMSS
+	OrderNo      string `json:"odno"`            // Order number
+	OrigOrderNo  string `json:"orgn_odno"`       // Original order number (revise/cancel rows)
+	SideCode     string `json:"sll_buy_dvsn_cd"` // 01: Sell, 02: Buy
+	ReviseCancel string `json:"rvse_cncl_dvsn"`  // 01: Revise, 02: Cancel
+	Symbol       string `json:"pdno"`
+	OrderQty     string `json:"ft_ord_qty"`
+	OrderPrice   string `json:"ft_ord_unpr3"`
+	FilledQty    string `json:"ft_ccld_qty"`
+	FilledPrice  string `json:"ft_ccld_unpr3"` // Average fill price
+	FilledAmt    string `json:"ft_ccld_amt3"`
+	UnfilledQty  string `json:"nccs_qty"`
+	StatusName   string `json:"prcs_stat_name"` // 완료, 거부, 접수 ...
+	RejectReason string `json:"rjct_rson"`
+	ExchCode     string `json:"ovrs_excg_cd"`
+}
+
+type OrderHistoryResponse struct {
+	Output []OrderHistoryItem `json:"output"`
+	RtCd   string             `json:"rt_cd"`
+	MsgCd  string             `json:"msg_cd"`
+	Msg1   string             `json:"msg1"`
+}
+
+// UnfilledOrderItem is one row of the overseas unfilled order inquiry (미체결내역)
+type UnfilledOrderItem struct {
+	OrderDate   string `json:"ord_dt"`
+	OrderTime   string `json:"ord_tmd"`
+	OrderNo     string `json:"odno"`
+	SideCode    string `json:"sll_buy_dvsn_cd"` // 01: Sell, 02: Buy
+	Symbol      string `json:"pdno"`
+	OrderQty    string `json:"ft_ord_qty"`
+	OrderPrice  string `json:"ft_ord_unpr3"`
+	FilledQty   string `json:"ft_ccld_qty"`
+	UnfilledQty string `json:"nccs_qty"`
+	ExchCode    string `json:"ovrs_excg_cd"`
+}
+
+type UnfilledOrdersResponse struct {
+	Output []UnfilledOrderItem `json:"output"`
+	RtCd   string              `json:"rt_cd"`
+	MsgCd  string              `json:"msg_cd"`
+	Msg1   string              `json:"msg1"`
+}
+
+// GetOrderHistory fetches orders and their fills between startDate and endDate (YYYYMMDD, local US date).
+// orderNo narrows the result to a single order when not empty.
+func (c *Client) GetOrderHistory(ctx context.Context, startDate, endDate, orderNo string) ([]OrderHistoryItem, error) {
+	logKIS("GetOrderHistory: %s ~ %s (ODNO: %q)", startDate, endDate, orderNo)
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ GetOrderHistory: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	// PDNO/OVRS_EXCG_CD "%" = all symbols/exchanges, SLL_BUY_DVSN/CCLD_NCCS_DVSN "00" = all
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-ccnl?CANO=%s&ACNT_PRDT_CD=%s&PDNO=%%25&ORD_STRT_DT=%s&ORD_END_DT=%s&SLL_BUY_DVSN=00&CCLD_NCCS_DVSN=00&OVRS_EXCG_CD=%%25&SORT_SQN=DS&ORD_DT=&ORD_GNO_BRNO=&ODNO=%s&CTX_AREA_NK200=&CTX_AREA_FK200=",
+		c.Config.KisBaseURL, cano, prdt, startDate, endDate, orderNo)
+	logKIS("GET %s", url)
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiOrderHistory, Idempotent: true})
+	if err != nil {
+		logKIS("✗ GetOrderHistory: Request failed: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ GetOrderHistory: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, c.apiError(apiOrderHistory, resp.StatusCode, bodyBytes)
+	}
+
+	var hResp OrderHistoryResponse
+	if err := json.Unmarshal(bodyBytes, &hResp); err != nil {
+		logKIS("✗ GetOrderHistory: Failed to decode response: %v", err)
+		return nil, err
+	}
+
+	if hResp.RtCd != "0" && hResp.RtCd != "0000" {
+		logKIS("✗ GetOrderHistory: API error (RtCd=%s): %s", hResp.RtCd, hResp.Msg1)
+		return nil, c.apiError(apiOrderHistory, resp.StatusCode, bodyBytes)
+	}
+
+	logKIS("✓ GetOrderHistory: %d rows", len(hResp.Output))
+	return hResp.Output, nil
+}
+
+// GetUnfilledOrders lists orders still working on exchCode (NASD, NYSE, AMEX).
+// KIS only offers this inquiry on the real environment.
+func (c *Client) GetUnfilledOrders(ctx context.Context, exchCode string) ([]UnfilledOrderItem, error) {
+	logKIS("GetUnfilledOrders: Fetching open orders on %s...", exchCode)
+
+	if c.mode == ModeVirtual {
+		return nil, broker.NewError(broker.CategoryValidation, "unfilled order inquiry is not available in virtual mode")
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ GetUnfilledOrders: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-nccs?CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=%s&SORT_SQN=DS&CTX_AREA_FK200=&CTX_AREA_NK200=",
+		c.Config.KisBaseURL, cano, prdt, exchCode)
+	logKIS("GET %s", url)
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiUnfilled, Idempotent: true})
+	if err != nil {
+		logKIS("✗ GetUnfilledOrders: Request failed: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ GetUnfilledOrders: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, c.apiError(apiUnfilled, resp.StatusCode, bodyBytes)
+	}
+
+	var uResp UnfilledOrdersResponse
+	if err := json.Unmarshal(bodyBytes, &uResp); err != nil {
+		logKIS("✗ GetUnfilledOrders: Failed to decode response: %v", err)
+		return nil, err
+	}
+
+	if uResp.RtCd != "0" && uResp.RtCd != "0000" {
+		logKIS("✗ GetUnfilledOrders: API error (RtCd=%s): %s", uResp.RtCd, uResp.Msg1)
+		return nil, c.apiError(apiUnfilled, resp.StatusCode, bodyBytes)
+	}
+
+	logKIS("✓ GetUnfilledOrders: %d open orders on %s", len(uResp.Output), exchCode)
+	return uResp.Output, nil
+}
+
+// Revise/cancel division codes (RVSE_CNCL_DVSN_CD)
+const (
+	reviseCode = "01"
+	cancelCode = "02"
+)
+
+// ReviseOrder reprices/resizes an open order and returns the new order number
+func (c *Client) ReviseOrder(ctx context.Context, exchCode, symbol, origOrderNo string, qty int, price float64) (string, error) {
+	return c.reviseCancel(ctx, reviseCode, exchCode, symbol, origOrderNo, qty, price)
+}
+
+// CancelOrder cancels qty shares of an open order (the unfilled remainder)
+func (c *Client) CancelOrder(ctx context.Context, exchCode, symbol, origOrderNo string, qty int) (string, error) {
+	return c.reviseCancel(ctx, cancelCode, exchCode, symbol, origOrderNo, qty, 0)
+}
+
+func (c *Client) reviseCancel(ctx context.Context, dvsn, exchCode, symbol, origOrderNo string, qty int, price float64) (string, error) {
+	action := "ReviseOrder"
+	if dvsn == cancelCode {
+		action = "CancelOrder"
+	}
+	logKIS("%s: %s:%s ODNO=%s Qty=%d Price=$%.2f", action, exchCode, symbol, origOrderNo, qty, price)
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ %s: Token error: %v", action, err)
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/order-rvsecncl", c.Config.KisBaseURL)
+	logKIS("POST %s", url)
+
+	cano, prdt := c.getAccountParts()
+
+	body := map[string]string{
+		"CANO":              cano,
+		"ACNT_PRDT_CD":      prdt,
+		"OVRS_EXCG_CD":      exchCode,
+		"PDNO":              symbol,
+		"ORGN_ODNO":         origOrderNo,
+		"RVSE_CNCL_DVSN_CD": dvsn,
+		"ORD_QTY":           fmt.Sprintf("%d", qty),
+		"OVRS_ORD_UNPR":     formatOrderPrice(price),
+		"MGCO_APTM_ODNO":    "",
+		"ORD_SVR_DVSN_CD":   "0",
+	}
+	jsonBody, _ := json.Marshal(body)
+	logKIS("%s: Request body: %s", action, string(jsonBody))
+
+	// Not idempotent: a lost response is reported as ErrAmbiguous, never resent
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "POST", URL: url, API: apiReviseCancel, Body: jsonBody})
+	if err != nil {
+		logKIS("✗ %s: Request failed: %v", action, err)
+		return "", err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ %s: Failed with status %d: %s", action, resp.StatusCode, string(bodyBytes))
+		return "", c.apiError(apiReviseCancel, resp.StatusCode, bodyBytes)
+	}
+
+	var orderResp OrderResponse
+	if err := json.Unmarshal(bodyBytes, &orderResp); err != nil {
+		logKIS("✗ %s: Failed to decode response: %v", action, err)
+		return "", err
+	}
+	if orderResp.RtCd != "0" {
+		logKIS("⚠ %s: Response Code: %s, Msg: %s", action, orderResp.RtCd, orderResp.Msg1)
+		return "", c.apiError(apiReviseCancel, resp.StatusCode, bodyBytes)
+	}
+
+	logKIS("✓ %s: SUCCESS - Order ID: %s, Msg: %s", action, orderResp.Output.ODNO, orderResp.Msg1)
+	return orderResp.Output.ODNO, nil
+}
@@ -0,0 +1,91 @@
+package kis
+
+import (
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// OrdDvsn is the US order division (ORD_DVSN) of the overseas order API
+type OrdDvsn string
+
+const (
+	OrdLimit OrdDvsn = "00" // 지정가
+	OrdMOO   OrdDvsn = "31" // 장개시시장가, sell only
+	OrdLOO   OrdDvsn = "32" // 장개시지정가
+	OrdMOC   OrdDvsn = "33" // 장마감시장가, sell only
+	OrdLOC   OrdDvsn = "34" // 장마감지정가
+)
+
+// ordDvsnNames is used in logs and errors
+var ordDvsnNames = map[OrdDvsn]string{
+	OrdLimit: "LIMIT",
+	OrdMOO:   "MOO",
+	OrdLOO:   "LOO",
+	OrdMOC:   "MOC",
+	OrdLOC:   "LOC",
+}
+
+func (d OrdDvsn) String() string {
+	if name, ok := ordDvsnNames[d]; ok {
+		return name + "(" + string(d) + ")"
+	}
+	return string(d)
+}
+
+// HasPrice reports whether the order carries a limit price; market-type
+// auction orders are sent with OVRS_ORD_UNPR 0
+func (d OrdDvsn) HasPrice() bool {
+	return d != OrdMOO && d != OrdMOC
+}
+
+// allowedOrdDvsn lists what TTTT1002U (buy) and TTTT1006U (sell) accept.
+// KIS has no plain market order for US stocks, and VTS only takes limits.
+var allowedOrdDvsn = map[string][]OrdDvsn{
+	"BUY":  {OrdLimit, OrdLOO, OrdLOC},
+	"SELL": {OrdLimit, OrdMOO, OrdLOO, OrdMOC, OrdLOC},
+}
+
+// allowedResvOrdDvsn lists what the reservation order API (TTTT3014U buy,
+// TTTT3016U sell) accepts for US stocks
+var allowedResvOrdDvsn = map[string][]OrdDvsn{
+	"BUY":  {OrdLimit},
+	"SELL": {OrdLimit, OrdMOO},
+}
+
+// Validate checks d against the order side, trading environment and price
+func (d OrdDvsn) Validate(side string, mode Mode, price float64) error {
+	return d.validate(allowedOrdDvsn, "", side, mode, price)
+}
+
+// ValidateReservation is Validate for reservation orders
+func (d OrdDvsn) ValidateReservation(side string, mode Mode, price float64) error {
+	return d.validate(allowedResvOrdDvsn, " reservation", side, mode, price)
+}
+
+func (d OrdDvsn) validate(allowedBySide map[string][]OrdDvsn, kind, side string, mode Mode, price float64) error {
+	allowed := false
+	for _, a := range allowedBySide[side] {
+		if a == d {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return broker.NewError(broker.CategoryValidation, "order type %s is not available for %s%s orders", d, side, kind)
+	}
+	if mode == ModeVirtual && d != OrdLimit {
+		return broker.NewError(broker.CategoryValidation, "order type %s is not available on the virtual (VTS) account", d)
+	}
+	if d.HasPrice() && price <= 0 {
+		return broker.NewError(broker.CategoryValidation, "order type %s needs a limit price", d)
+	}
+	return nil
+}
+
+// ordDvsnFor maps broker order types to KIS order divisions
+var ordDvsnFor = map[broker.OrderType]OrdDvsn{
+	broker.OrderTypeLimit: OrdLimit,
+	broker.OrderTypeLOC:   OrdLOC,
+	broker.OrderTypeMOC:   OrdMOC,
+	broker.OrderTypeLOO:   OrdLOO,
+	broker.OrderTypeMOO:   OrdMOO,
+}
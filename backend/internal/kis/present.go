@@ -0,0 +1,101 @@
+package kis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PresentCurrencyItem is the per-currency deposit row of the settled balance
+// inquiry (체결기준현재잔고), including the exchange rate KIS applies today
+type PresentCurrencyItem struct {
+	Currency     string `json:"crcy_cd"` // USD
+	BuyAmt       string `json:"frcr_buy_amt_smtl"`
+	SellAmt      string `json:"frcr_sll_amt_smtl"`
+	Deposit      string `json:"frcr_dncl_amt_2"`      // Foreign currency deposit
+	Withdrawable string `json:"frcr_drwg_psbl_amt_1"` // Withdrawable amount
+	Evaluation   string `json:"frcr_evlu_amt2"`       // Deposit valued in KRW
+	ExchangeRate string `json:"frst_bltn_exrt"`       // First posted rate of the day (KRW per unit)
+	NextDayDrwg  string `json:"nxdy_frcr_drwg_psbl_amt"`
+}
+
+// PresentBalanceResponse is the settled balance inquiry reply: holdings
+// (output1), deposits per currency (output2) and account totals in KRW (output3)
+type PresentBalanceResponse struct {
+	Output1 []struct {
+		Symbol   string `json:"pdno"`
+		Qty      string `json:"cblc_qty13"`
+		AvgPrice string `json:"avg_unpr3"`
+		NowPrice string `json:"ovrs_now_pric1"`
+		EvalPL   string `json:"evlu_pfls_amt2"`
+		ExchCode string `json:"ovrs_excg_cd"`
+		Currency string `json:"buy_crcy_cd"`
+		BuyRate  string `json:"bass_exrt"` // Rate at purchase
+	} `json:"output1"`
+	Output2 []PresentCurrencyItem `json:"output2"`
+	Output3 struct {
+		PurchaseAmt  string `json:"pchs_amt_smtl"`      // KRW
+		EvalAmt      string `json:"evlu_amt_smtl"`      // KRW
+		EvalPL       string `json:"evlu_pfls_amt_smtl"` // KRW
+		Deposit      string `json:"tot_dncl_amt"`       // KRW
+		TotalAsset   string `json:"tot_asst_amt"`       // KRW
+		Withdrawable string `json:"wdrw_psbl_tot_amt"`  // KRW
+	} `json:"output3"`
+	RtCd  string `json:"rt_cd"`
+	MsgCd string `json:"msg_cd"`
+	Msg1  string `json:"msg1"`
+}
+
+// Currency returns the output2 row of cur, if any
+func (r *PresentBalanceResponse) Currency(cur string) (PresentCurrencyItem, bool) {
+	for _, it := range r.Output2 {
+		if it.Currency == cur {
+			return it, true
+		}
+	}
+	return PresentCurrencyItem{}, false
+}
+
+// GetPresentBalance fetches the US settled balance with amounts in KRW
+// (WCRC_FRCR_DVSN_CD 01) and the day's exchange rates
+func (c *Client) GetPresentBalance(ctx context.Context) (*PresentBalanceResponse, error) {
+	logKIS("GetPresentBalance: Fetching settled balance and exchange rate...")
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ GetPresentBalance: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	// NATN_CD 840 = US, TR_MKET_CD/INQR_DVSN_CD 00 = all markets and holdings
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-present-balance?CANO=%s&ACNT_PRDT_CD=%s&WCRC_FRCR_DVSN_CD=01&NATN_CD=840&TR_MKET_CD=00&INQR_DVSN_CD=00",
+		c.Config.KisBaseURL, cano, prdt)
+	logKIS("GET %s", url)
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiPresent, Idempotent: true})
+	if err != nil {
+		logKIS("✗ GetPresentBalance: Request failed: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ GetPresentBalance: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, c.apiError(apiPresent, resp.StatusCode, bodyBytes)
+	}
+
+	var pResp PresentBalanceResponse
+	if err := json.Unmarshal(bodyBytes, &pResp); err != nil {
+		logKIS("✗ GetPresentBalance: Failed to decode response: %v", err)
+		return nil, err
+	}
+	if pResp.RtCd != "0" && pResp.RtCd != "0000" {
+		logKIS("✗ GetPresentBalance: API error (RtCd=%s): %s", pResp.RtCd, pResp.Msg1)
+		return nil, c.apiError(apiPresent, resp.StatusCode, bodyBytes)
+	}
+
+	usd, _ := pResp.Currency("USD")
+	logKIS("✓ GetPresentBalance: %d holdings, total asset ₩%s, USD/KRW %s",
+		len(pResp.Output1), pResp.Output3.TotalAsset, usd.ExchangeRate)
+	return &pResp, nil
+}
@@ -0,0 +1,191 @@
+package kis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// PeriodProfitItem is one row of the overseas period profit inquiry
+// (해외주식 기간손익): the realized P&L of a symbol on one trade date
+type PeriodProfitItem struct {
+	TradeDate    string `json:"trad_day"` // YYYYMMDD
+	Symbol       string `json:"ovrs_pdno"`
+	Name         string `json:"ovrs_item_name"`
+	SoldQty      string `json:"slcl_qty"`
+	AvgBuyPrice  string `json:"pchs_avg_pric"`
+	BuyAmt       string `json:"frcr_pchs_amt1"`
+	AvgSellPrice string `json:"avg_sll_unpr"`
+	SellAmt      string `json:"frcr_sll_amt_smtl1"`
+	Fee          string `json:"stck_sll_tlex"` // Fees and taxes on the sale
+	RealizedPL   string `json:"ovrs_rlzt_pfls_amt"`
+	ProfitRate   string `json:"pftrt"` // Percent
+	ExchangeRate string `json:"exrt"`
+	ExchCode     string `json:"ovrs_excg_cd"`
+}
+
+type PeriodProfitResponse struct {
+	Output1 []PeriodProfitItem `json:"output1"`
+	Output2 struct {
+		SellAmt    string `json:"stck_sll_amt_smtl"`
+		BuyAmt     string `json:"stck_buy_amt_smtl"`
+		Fee        string `json:"smtl_fee1"`
+		RealizedPL string `json:"ovrs_rlzt_pfls_tot_amt"`
+		ProfitRate string `json:"tot_pftrt"`
+	} `json:"output2"`
+	CtxAreaFK200 string `json:"ctx_area_fk200"`
+	CtxAreaNK200 string `json:"ctx_area_nk200"`
+	RtCd         string `json:"rt_cd"`
+	MsgCd        string `json:"msg_cd"`
+	Msg1         string `json:"msg1"`
+}
+
+// PeriodTransItem is one row of the overseas trade history inquiry
+// (해외주식 일별거래내역): a single execution
+type PeriodTransItem struct {
+	TradeDate  string `json:"trad_dt"` // YYYYMMDD
+	SettleDate string `json:"sttl_dt"`
+	SideCode   string `json:"sll_buy_dvsn_cd"` // 01: Sell, 02: Buy
+	Symbol     string `json:"pdno"`
+	Name       string `json:"ovrs_item_name"`
+	Qty        string `json:"ccld_qty"`
+	Price      string `json:"ovrs_stck_ccld_unpr"`
+	Amount     string `json:"tr_frcr_amt2"`
+	SettleAmt  string `json:"frcr_excc_amt_1"`
+	Fee        string `json:"frcr_fee1"`
+	Currency   string `json:"crcy_cd"`
+}
+
+type PeriodTransResponse struct {
+	Output1      []PeriodTransItem `json:"output1"`
+	CtxAreaFK100 string            `json:"ctx_area_fk100"`
+	CtxAreaNK100 string            `json:"ctx_area_nk100"`
+	RtCd         string            `json:"rt_cd"`
+	MsgCd        string            `json:"msg_cd"`
+	Msg1         string            `json:"msg1"`
+}
+
+// maxReportPages guards the period inquiries against a continuation loop
+const maxReportPages = 50
+
+// GetPeriodProfit returns the realized P&L per trade date and symbol between
+// startDate and endDate (YYYYMMDD, US trade dates) in USD, following
+// CTX_AREA continuation. KIS only offers this inquiry on the real environment.
+func (c *Client) GetPeriodProfit(ctx context.Context, startDate, endDate string) ([]PeriodProfitItem, error) {
+	logKIS("GetPeriodProfit: %s ~ %s", startDate, endDate)
+
+	if c.mode == ModeVirtual {
+		return nil, broker.NewError(broker.CategoryValidation, "period profit inquiry is not available in virtual mode")
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ GetPeriodProfit: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	var items []PeriodProfitItem
+	fk, nk := "", ""
+	for page := 0; page < maxReportPages; page++ {
+		// OVRS_EXCG_CD/PDNO empty = every market and symbol, WCRC_FRCR_DVSN_CD 01 = amounts in USD
+		url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-period-profit?CANO=%s&ACNT_PRDT_CD=%s&OVRS_EXCG_CD=&NATN_CD=&CRCY_CD=USD&PDNO=&INQR_STRT_DT=%s&INQR_END_DT=%s&WCRC_FRCR_DVSN_CD=01&CTX_AREA_FK200=%s&CTX_AREA_NK200=%s",
+			c.Config.KisBaseURL, cano, prdt, startDate, endDate, neturl.QueryEscape(fk), neturl.QueryEscape(nk))
+		logKIS("GET %s", url)
+
+		resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiPeriodProfit, Cont: page > 0, Idempotent: true})
+		if err != nil {
+			logKIS("✗ GetPeriodProfit: Request failed: %v", err)
+			return nil, err
+		}
+
+		if resp.StatusCode != 200 {
+			logKIS("✗ GetPeriodProfit: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+			return nil, c.apiError(apiPeriodProfit, resp.StatusCode, bodyBytes)
+		}
+
+		var pResp PeriodProfitResponse
+		if err := json.Unmarshal(bodyBytes, &pResp); err != nil {
+			logKIS("✗ GetPeriodProfit: Failed to decode response: %v", err)
+			return nil, err
+		}
+		if pResp.RtCd != "0" && pResp.RtCd != "0000" {
+			logKIS("✗ GetPeriodProfit: API error (RtCd=%s): %s", pResp.RtCd, pResp.Msg1)
+			return nil, c.apiError(apiPeriodProfit, resp.StatusCode, bodyBytes)
+		}
+		items = append(items, pResp.Output1...)
+
+		trCont := resp.Header.Get("tr_cont")
+		if (trCont != "F" && trCont != "M") || strings.TrimSpace(pResp.CtxAreaNK200) == "" {
+			logKIS("✓ GetPeriodProfit: %d rows, realized $%s", len(items), pResp.Output2.RealizedPL)
+			return items, nil
+		}
+		fk, nk = pResp.CtxAreaFK200, pResp.CtxAreaNK200
+	}
+
+	logKIS("⚠ GetPeriodProfit: Stopped after %d pages (%d rows)", maxReportPages, len(items))
+	return items, nil
+}
+
+// GetPeriodTrans returns the executions between startDate and endDate
+// (YYYYMMDD, US trade dates), following CTX_AREA continuation.
+// KIS only offers this inquiry on the real environment.
+func (c *Client) GetPeriodTrans(ctx context.Context, startDate, endDate string) ([]PeriodTransItem, error) {
+	logKIS("GetPeriodTrans: %s ~ %s", startDate, endDate)
+
+	if c.mode == ModeVirtual {
+		return nil, broker.NewError(broker.CategoryValidation, "trade history inquiry is not available in virtual mode")
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ GetPeriodTrans: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	var items []PeriodTransItem
+	fk, nk := "", ""
+	for page := 0; page < maxReportPages; page++ {
+		// OVRS_EXCG_CD/PDNO empty = every market and symbol, SLL_BUY_DVSN_CD 00 = buys and sells
+		url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/inquire-period-trans?CANO=%s&ACNT_PRDT_CD=%s&ERLM_STRT_DT=%s&ERLM_END_DT=%s&OVRS_EXCG_CD=&PDNO=&SLL_BUY_DVSN_CD=00&LOAN_DVSN_CD=&CTX_AREA_FK100=%s&CTX_AREA_NK100=%s",
+			c.Config.KisBaseURL, cano, prdt, startDate, endDate, neturl.QueryEscape(fk), neturl.QueryEscape(nk))
+		logKIS("GET %s", url)
+
+		resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiPeriodTrans, Cont: page > 0, Idempotent: true})
+		if err != nil {
+			logKIS("✗ GetPeriodTrans: Request failed: %v", err)
+			return nil, err
+		}
+
+		if resp.StatusCode != 200 {
+			logKIS("✗ GetPeriodTrans: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+			return nil, c.apiError(apiPeriodTrans, resp.StatusCode, bodyBytes)
+		}
+
+		var tResp PeriodTransResponse
+		if err := json.Unmarshal(bodyBytes, &tResp); err != nil {
+			logKIS("✗ GetPeriodTrans: Failed to decode response: %v", err)
+			return nil, err
+		}
+		if tResp.RtCd != "0" && tResp.RtCd != "0000" {
+			logKIS("✗ GetPeriodTrans: API error (RtCd=%s): %s", tResp.RtCd, tResp.Msg1)
+			return nil, c.apiError(apiPeriodTrans, resp.StatusCode, bodyBytes)
+		}
+		items = append(items, tResp.Output1...)
+
+		trCont := resp.Header.Get("tr_cont")
+		if (trCont != "F" && trCont != "M") || strings.TrimSpace(tResp.CtxAreaNK100) == "" {
+			logKIS("✓ GetPeriodTrans: %d rows", len(items))
+			return items, nil
+		}
+		fk, nk = tResp.CtxAreaFK100, tResp.CtxAreaNK100
+	}
+
+	logKIS("⚠ GetPeriodTrans: Stopped after %d pages (%d rows)", maxReportPages, len(items))
+	return items, nil
+}
@@ -0,0 +1,440 @@
+package kis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/credstore"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+	"golang.org/x/net/websocket"
+)
+
+// Default realtime (WebSocket) endpoints per mode
+const (
+	wsURLReal    = "ws://ops.koreainvestment.com:21000"
+	wsURLVirtual = "ws://ops.koreainvestment.com:31000"
+)
+
+// Realtime TR IDs (overseas stock)
+const (
+	wsTrade    = "HDFSCNT0" // 해외주식 실시간지연체결가 (free, realtime for US)
+	wsQuote    = "HDFSASP0" // 해외주식 실시간호가 (level 1 for US)
+	wsPingPong = "PINGPONG"
+)
+
+const (
+	// maxWSSubscriptions is the KIS limit of registrations per session
+	maxWSSubscriptions = 41
+	// wsIdleTimeout drops a connection that went silent: KIS pings about every 10s
+	wsIdleTimeout = 2 * time.Minute
+	// approvalKeyTTL is how long an approval key is reused across reconnects
+	approvalKeyTTL = 12 * time.Hour
+	maxWSBackoff   = time.Minute
+)
+
+// Field counts of one record in the realtime data messages
+const (
+	tradeFields = 26
+	quoteFields = 17
+)
+
+// WSURL returns the realtime endpoint: KIS_WS_URL, else the default of the mode
+func (c *Client) WSURL() string {
+	if c.Config.KisWSURL != "" {
+		return c.Config.KisWSURL
+	}
+	if c.mode == ModeVirtual {
+		return wsURLVirtual
+	}
+	return wsURLReal
+}
+
+// IssueApprovalKey gets a WebSocket approval key (실시간 접속키)
+func (c *Client) IssueApprovalKey(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/oauth2/Approval", c.Config.KisBaseURL)
+	body := map[string]string{
+		"grant_type": "client_credentials",
+		"appkey":     c.Config.KisAppKey,
+		"secretkey":  c.Config.KisAppSecret,
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	logKIS("POST %s (requesting WebSocket approval key)", url)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		logKIS("✗ Approval key request failed: %v", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		logKIS("✗ Approval key failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		apiErr := tokenError(resp.StatusCode, bodyBytes)
+		apiErr.TrID = "Approval"
+		return "", apiErr
+	}
+
+	var aResp struct {
+		ApprovalKey string `json:"approval_key"`
+	}
+	if err := json.Unmarshal(bodyBytes, &aResp); err != nil {
+		return "", err
+	}
+	if aResp.ApprovalKey == "" {
+		return "", broker.NewError(broker.CategoryAuth, "KIS returned an empty approval key")
+	}
+	credstore.Register(aResp.ApprovalKey)
+	logKIS("✓ WebSocket approval key issued")
+	return aResp.ApprovalKey, nil
+}
+
+// Stream is a KIS realtime connection carrying the trade and quote feeds of
+// overseas symbols. Subscriptions survive reconnects.
+type Stream struct {
+	client *Client
+	URL    string
+
+	mu    sync.Mutex
+	conn  *websocket.Conn
+	subs  map[string]bool // tr_key, e.g. DNASTQQQ
+	key   string
+	keyAt time.Time
+}
+
+func (c *Client) NewStream() *Stream {
+	return &Stream{client: c, URL: c.WSURL(), subs: make(map[string]bool)}
+}
+
+// trKey is the free realtime key of a symbol: "D" + quote exchange code + symbol
+func trKey(exchCode, symbol string) string {
+	return "D" + exchCode + symbol
+}
+
+// Subscribe adds the trade and quote feeds of symbol (exchCode is the quote
+// code, e.g. NAS). It is sent right away when connected, else on connect.
+func (s *Stream) Subscribe(exchCode, symbol string) error {
+	key := trKey(exchCode, symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[key] {
+		return nil
+	}
+	if 2*(len(s.subs)+1) > maxWSSubscriptions {
+		return broker.NewError(broker.CategoryValidation, "realtime subscription limit reached (%d symbols)", maxWSSubscriptions/2)
+	}
+	s.subs[key] = true
+	logKIS("Realtime: Subscribing %s", key)
+	if s.conn != nil {
+		return s.subscribeLocked(key)
+	}
+	return nil
+}
+
+// Unsubscribe releases the trade and quote feeds of symbol, freeing their
+// registrations for other symbols
+func (s *Stream) Unsubscribe(exchCode, symbol string) error {
+	key := trKey(exchCode, symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.subs[key] {
+		return nil
+	}
+	delete(s.subs, key)
+	logKIS("Realtime: Releasing %s", key)
+	if s.conn == nil {
+		return nil
+	}
+	for _, trID := range []string{wsTrade, wsQuote} {
+		if err := s.sendLocked(wsRequest(s.key, "2", trID, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscribeLocked registers both feeds of key. Caller holds mu.
+func (s *Stream) subscribeLocked(key string) error {
+	for _, trID := range []string{wsTrade, wsQuote} {
+		if err := s.sendLocked(wsRequest(s.key, "1", trID, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendLocked writes one text frame. Caller holds mu.
+func (s *Stream) sendLocked(msg string) error {
+	if s.conn == nil {
+		return errors.New("realtime stream not connected")
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return websocket.Message.Send(s.conn, msg)
+}
+
+// wsRequest builds a registration (trType "1") or release ("2") message
+func wsRequest(approvalKey, trType, trID, key string) string {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"header": map[string]string{
+			"approval_key": approvalKey,
+			"custtype":     "P",
+			"tr_type":      trType,
+			"content-type": "utf-8",
+		},
+		"body": map[string]interface{}{
+			"input": map[string]string{"tr_id": trID, "tr_key": key},
+		},
+	})
+	return string(msg)
+}
+
+// Run keeps the stream connected until ctx ends, calling sink for every
+// update. Broken connections are redialled with backoff and resubscribed.
+func (s *Stream) Run(ctx context.Context, sink func(broker.Quote)) error {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := s.session(ctx, sink)
+		if ctx.Err() != nil {
+			logKIS("Realtime: Stopped")
+			return ctx.Err()
+		}
+		if time.Since(started) > maxWSBackoff {
+			backoff = time.Second // The connection was healthy for a while
+		}
+		logKIS("✗ Realtime: Connection lost: %v (reconnecting in %v)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWSBackoff)
+	}
+}
+
+// approvalKey returns the cached approval key, issuing one when missing or old
+func (s *Stream) approvalKey(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	key, at := s.key, s.keyAt
+	s.mu.Unlock()
+	if key != "" && time.Since(at) < approvalKeyTTL {
+		return key, nil
+	}
+	key, err := s.client.IssueApprovalKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.key, s.keyAt = key, time.Now()
+	s.mu.Unlock()
+	return key, nil
+}
+
+// session dials, subscribes everything and reads until the connection fails
+func (s *Stream) session(ctx context.Context, sink func(broker.Quote)) error {
+	if _, err := s.approvalKey(ctx); err != nil {
+		return err
+	}
+	cfg, err := websocket.NewConfig(s.URL, "http://localhost")
+	if err != nil {
+		return err
+	}
+	logKIS("Realtime: Connecting to %s", s.URL)
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	s.mu.Lock()
+	s.conn = conn
+	keys := make([]string, 0, len(s.subs))
+	for k := range s.subs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := s.subscribeLocked(k); err != nil {
+			s.conn = nil
+			s.mu.Unlock()
+			conn.Close()
+			return err
+		}
+	}
+	s.mu.Unlock()
+	logKIS("✓ Realtime: Connected, %d symbols subscribed", len(keys))
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(wsIdleTimeout))
+		var msg string
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			return err
+		}
+		if err := s.handle(msg, sink); err != nil {
+			return err
+		}
+	}
+}
+
+// wsControl is a JSON control message: subscription replies and PINGPONG
+type wsControl struct {
+	Header struct {
+		TrID  string `json:"tr_id"`
+		TrKey string `json:"tr_key"`
+	} `json:"header"`
+	Body struct {
+		RtCd  string `json:"rt_cd"`
+		MsgCd string `json:"msg_cd"`
+		Msg1  string `json:"msg1"`
+	} `json:"body"`
+}
+
+// handle processes one message. An error ends the session.
+func (s *Stream) handle(msg string, sink func(broker.Quote)) error {
+	if msg == "" {
+		return nil
+	}
+	switch msg[0] {
+	case '0':
+		for _, q := range parseRealtime(msg, time.Now()) {
+			sink(q)
+		}
+		return nil
+	case '1':
+		// Encrypted payloads are execution notices, which this stream does not subscribe
+		logKIS("Realtime: Dropping encrypted message")
+		return nil
+	}
+
+	var ctl wsControl
+	if err := json.Unmarshal([]byte(msg), &ctl); err != nil {
+		logKIS("Realtime: Unreadable message: %s", msg)
+		return nil
+	}
+	if ctl.Header.TrID == wsPingPong {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.sendLocked(msg)
+	}
+	if ctl.Body.RtCd != "0" {
+		logKIS("✗ Realtime: %s %s rejected (%s): %s", ctl.Header.TrID, ctl.Header.TrKey, ctl.Body.MsgCd, ctl.Body.Msg1)
+		if strings.Contains(strings.ToLower(ctl.Body.Msg1), "approval") {
+			// Reconnect with a new key
+			s.mu.Lock()
+			s.key = ""
+			s.mu.Unlock()
+			return fmt.Errorf("approval key rejected: %s", ctl.Body.Msg1)
+		}
+		return nil
+	}
+	logKIS("Realtime: %s %s: %s", ctl.Header.TrID, ctl.Header.TrKey, ctl.Body.Msg1)
+	return nil
+}
+
+// parseRealtime decodes a data message "0|TR_ID|count|f1^f2^..." into quotes
+func parseRealtime(msg string, received time.Time) []broker.Quote {
+	parts := strings.SplitN(msg, "|", 4)
+	if len(parts) != 4 {
+		return nil
+	}
+	n, _ := strconv.Atoi(parts[2])
+	fields := strings.Split(parts[3], "^")
+
+	var width int
+	var parse func(f []string) broker.Quote
+	switch parts[1] {
+	case wsTrade:
+		width, parse = tradeFields, parseTrade
+	case wsQuote:
+		width, parse = quoteFields, parseQuote
+	default:
+		return nil
+	}
+	if n > 0 && len(fields)/n > width {
+		width = len(fields) / n // Tolerate fields appended by KIS
+	}
+
+	var out []broker.Quote
+	for i := 0; i < n && (i+1)*width <= len(fields); i++ {
+		q := parse(fields[i*width : (i+1)*width])
+		q.Received = received
+		out = append(out, q)
+	}
+	return out
+}
+
+// exchangeOfKey maps the realtime key prefix (D + NAS/NYS/AMS) to an exchange
+func exchangeOfKey(key string) broker.Exchange {
+	if len(key) < 4 {
+		return ""
+	}
+	return symbols.ExchangeFromCode(key[1:4])
+}
+
+// realtimeTime reads exchange-local date and time (US = ET)
+func realtimeTime(date, hms string) time.Time {
+	t, err := time.ParseInLocation("20060102150405", date+hms, broker.Eastern())
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseTrade reads a HDFSCNT0 record:
+// RSYM SYMB ZDIV TYMD XYMD XHMS KYMD KHMS OPEN HIGH LOW LAST SIGN DIFF RATE
+// PBID PASK VBID VASK EVOL TVOL TAMT BIVL ASVL STRN MTYP
+func parseTrade(f []string) broker.Quote {
+	return broker.Quote{
+		Exchange: exchangeOfKey(f[0]),
+		Symbol:   f[1],
+		Last:     parseFloat(f[11]),
+		Bid:      parseFloat(f[15]),
+		Ask:      parseFloat(f[16]),
+		BidSize:  int64(parseFloat(f[17])),
+		AskSize:  int64(parseFloat(f[18])),
+		Volume:   int64(parseFloat(f[20])),
+		Time:     realtimeTime(f[4], f[5]),
+	}
+}
+
+// parseQuote reads a HDFSASP0 record (US carries level 1 only):
+// RSYM SYMB ZDIV XYMD XHMS KYMD KHMS BVOL AVOL BDVL ADVL PBID1 PASK1 VBID1 VASK1 DBID1 DASK1
+func parseQuote(f []string) broker.Quote {
+	return broker.Quote{
+		Exchange: exchangeOfKey(f[0]),
+		Symbol:   f[1],
+		Bid:      parseFloat(f[11]),
+		Ask:      parseFloat(f[12]),
+		BidSize:  int64(parseFloat(f[13])),
+		AskSize:  int64(parseFloat(f[14])),
+		Time:     realtimeTime(f[3], f[4]),
+	}
+}
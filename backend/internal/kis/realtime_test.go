@@ -0,0 +1,127 @@
+package kis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+)
+
+// waitFor polls cond until it holds or the deadline passes
+func waitFor(t *testing.T, what string, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// nextQuote returns the next quote of symbol with a trade price (last > 0)
+// or a book (bid > 0), skipping the other kind. Any other symbol fails the test.
+func nextQuote(t *testing.T, quotes <-chan broker.Quote, symbol string, trade bool) broker.Quote {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case q := <-quotes:
+			if q.Symbol != symbol {
+				t.Errorf("unsubscribed %s delivered", q.Symbol)
+				continue
+			}
+			if trade && q.Last > 0 || !trade && q.Bid > 0 {
+				return q
+			}
+		case <-timeout:
+			t.Fatalf("no %s quote received", symbol)
+		}
+	}
+}
+
+// The stream delivers trades and level 1 quotes of the subscribed symbols,
+// answers PINGPONG and resubscribes after the connection drops
+func TestQuoteStreamDeliversAndReconnects(t *testing.T) {
+	b, srv := newFakeBroker(t, kisfake.Scenario{Prices: map[string]float64{"TQQQ": 50, "SOXL": 20}})
+	if err := b.SubscribeQuotes(broker.ExchangeNASDAQ, "TQQQ"); err != nil {
+		t.Fatal(err)
+	}
+
+	quotes := make(chan broker.Quote, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.StreamQuotes(ctx, func(q broker.Quote) { quotes <- q }) }()
+	defer func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("stream did not stop")
+		}
+	}()
+
+	subscribed := func() bool { return len(srv.Subscriptions()) == 2 }
+	waitFor(t, "trade and quote registrations", 5*time.Second, subscribed)
+
+	srv.SetPrice("SOXL", 21) // Not subscribed: never delivered
+	srv.SetPrice("TQQQ", 51.25)
+	q := nextQuote(t, quotes, "TQQQ", true)
+	if q.Last != 51.25 || q.Exchange != broker.ExchangeNASDAQ {
+		t.Errorf("trade = %+v, want TQQQ on NASDAQ at 51.25", q)
+	}
+
+	srv.PushQuote("TQQQ", 51.2, 51.3, 10, 20)
+	q = nextQuote(t, quotes, "TQQQ", false)
+	if q.Bid != 51.2 || q.Ask != 51.3 || q.BidSize != 10 || q.AskSize != 20 {
+		t.Errorf("book = %+v, want 10 x 51.20 / 51.30 x 20", q)
+	}
+
+	srv.Ping()
+	waitFor(t, "PINGPONG echo", 5*time.Second, func() bool { return srv.Pongs() > 0 })
+
+	// A dropped connection is redialled and the registrations sent again
+	srv.DropStreams()
+	waitFor(t, "registrations to go away", 5*time.Second, func() bool { return len(srv.Subscriptions()) == 0 })
+	waitFor(t, "resubscription", 10*time.Second, subscribed)
+
+	srv.SetPrice("TQQQ", 52)
+	if q := nextQuote(t, quotes, "TQQQ", true); q.Last != 52 {
+		t.Errorf("trade after reconnect = %+v, want 52", q)
+	}
+}
+
+// Releasing a symbol sends tr_type "2" for both feeds while connected and
+// keeps it off the registrations sent after a reconnect
+func TestQuoteStreamReleasesSymbol(t *testing.T) {
+	b, srv := newFakeBroker(t, kisfake.Scenario{Prices: map[string]float64{"TQQQ": 50, "SOXL": 20}})
+	for _, sym := range []string{"TQQQ", "SOXL"} {
+		if err := b.SubscribeQuotes(broker.ExchangeNASDAQ, sym); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.StreamQuotes(ctx, func(broker.Quote) {}) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitFor(t, "registrations", 5*time.Second, func() bool { return len(srv.Subscriptions()) == 4 })
+
+	if err := b.UnsubscribeQuotes(broker.ExchangeNASDAQ, "SOXL"); err != nil {
+		t.Fatal(err)
+	}
+	onlyTQQQ := func() bool {
+		subs := srv.Subscriptions()
+		return len(subs) == 2 && subs[0] == "HDFSASP0|DNASTQQQ" && subs[1] == "HDFSCNT0|DNASTQQQ"
+	}
+	waitFor(t, "SOXL release", 5*time.Second, onlyTQQQ)
+
+	srv.DropStreams()
+	waitFor(t, "registrations to go away", 5*time.Second, func() bool { return len(srv.Subscriptions()) == 0 })
+	waitFor(t, "resubscription without SOXL", 10*time.Second, onlyTQQQ)
+}
@@ -0,0 +1,253 @@
+package kis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// Reservation orders (예약주문) are accepted outside the US session and
+// forwarded by KIS as regular orders when the next session opens.
+
+// ReservationResponse is the reservation order reply
+type ReservationResponse struct {
+	RtCd   string `json:"rt_cd"`
+	Msg1   string `json:"msg1"`
+	MsgCd  string `json:"msg_cd"`
+	Output struct {
+		ODNO        string `json:"ODNO"`
+		ReceiptDate string `json:"RSVN_ORD_RCIT_DT"` // YYYYMMDD (KST)
+		ResvOrderNo string `json:"OVRS_RSVN_ODNO"`
+	} `json:"output"`
+}
+
+// ReservationReceipt identifies a reservation: KIS needs both the receipt
+// date and the reservation number to cancel it
+type ReservationReceipt struct {
+	ReceiptDate string
+	ResvOrderNo string
+}
+
+// ReservationItem is one row of the reservation order inquiry (예약주문조회)
+type ReservationItem struct {
+	Cancelled    string `json:"cncl_yn"`          // Y when cancelled
+	ReceiptDate  string `json:"rsvn_ord_rcit_dt"` // YYYYMMDD (KST)
+	ResvOrderNo  string `json:"ovrs_rsvn_odno"`
+	OrderDate    string `json:"ord_dt"`          // Set once forwarded
+	OrderNo      string `json:"odno"`            // Live order number once forwarded
+	SideCode     string `json:"sll_buy_dvsn_cd"` // 01: Sell, 02: Buy
+	StatusCode   string `json:"ovrs_rsvn_ord_stat_cd"`
+	StatusName   string `json:"ovrs_rsvn_ord_stat_cd_name"`
+	Symbol       string `json:"pdno"`
+	ReceiptTime  string `json:"ord_rcit_tmd"` // HHMMSS (KST)
+	ExchCode     string `json:"ovrs_excg_cd"`
+	OrderQty     string `json:"ft_ord_qty"`
+	OrderPrice   string `json:"ft_ord_unpr3"`
+	FilledQty    string `json:"ft_ccld_qty"`
+	RejectReason string `json:"nprc_rson_text"` // 미처리사유
+}
+
+type ReservationListResponse struct {
+	Output       []ReservationItem `json:"output"`
+	CtxAreaFK200 string            `json:"ctx_area_fk200"`
+	CtxAreaNK200 string            `json:"ctx_area_nk200"`
+	RtCd         string            `json:"rt_cd"`
+	MsgCd        string            `json:"msg_cd"`
+	Msg1         string            `json:"msg1"`
+}
+
+// PlaceReservation queues o for the next US session
+func (c *Client) PlaceReservation(ctx context.Context, o OrderReq) (ReservationReceipt, error) {
+	logKIS("PlaceReservation: %s %d shares of %s:%s at $%.2f (type: %s)",
+		o.Side, o.Qty, o.ExchCode, o.Symbol, o.Price, o.OrdType)
+
+	if err := o.OrdType.ValidateReservation(o.Side, c.mode, o.Price); err != nil {
+		logKIS("✗ PlaceReservation: %v", err)
+		return ReservationReceipt{}, err
+	}
+	price := o.Price
+	if !o.OrdType.HasPrice() {
+		price = 0
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ PlaceReservation: Token error: %v", err)
+		return ReservationReceipt{}, err
+	}
+
+	// Shares the live order guard: a reservation that may have been booked
+	// must not be placed again either way
+	if err := c.checkAmbiguous(o); err != nil {
+		logKIS("✗ PlaceReservation: %v", err)
+		return ReservationReceipt{}, err
+	}
+
+	api := apiResvBuy
+	if o.Side == "SELL" {
+		api = apiResvSell
+	}
+
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/order-resv", c.Config.KisBaseURL)
+	logKIS("POST %s (TR_ID=%s)", url, c.trID(api))
+
+	cano, prdt := c.getAccountParts()
+
+	body := map[string]string{
+		"CANO":            cano,
+		"ACNT_PRDT_CD":    prdt,
+		"PDNO":            o.Symbol,
+		"OVRS_EXCG_CD":    o.ExchCode,
+		"FT_ORD_QTY":      fmt.Sprintf("%d", o.Qty),
+		"FT_ORD_UNPR3":    formatOrderPrice(price),
+		"ORD_SVR_DVSN_CD": "0",
+		"ORD_DVSN":        string(o.OrdType),
+	}
+	jsonBody, _ := json.Marshal(body)
+	logKIS("PlaceReservation: Request body: %s", string(jsonBody))
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "POST", URL: url, API: api, Body: jsonBody})
+	if err != nil {
+		if errors.Is(err, ErrAmbiguous) {
+			c.markAmbiguous(o)
+		}
+		logKIS("✗ PlaceReservation: Request failed: %v", err)
+		return ReservationReceipt{}, err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ PlaceReservation: Failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		return ReservationReceipt{}, c.apiError(api, resp.StatusCode, bodyBytes)
+	}
+
+	var rResp ReservationResponse
+	if err := json.Unmarshal(bodyBytes, &rResp); err != nil {
+		logKIS("✗ PlaceReservation: Failed to decode response: %v", err)
+		return ReservationReceipt{}, err
+	}
+	if rResp.RtCd != "0" {
+		logKIS("⚠ PlaceReservation: Response Code: %s, Msg: %s", rResp.RtCd, rResp.Msg1)
+		return ReservationReceipt{}, c.apiError(api, resp.StatusCode, bodyBytes)
+	}
+
+	receipt := ReservationReceipt{ReceiptDate: rResp.Output.ReceiptDate, ResvOrderNo: rResp.Output.ResvOrderNo}
+	if receipt.ResvOrderNo == "" {
+		receipt.ResvOrderNo = rResp.Output.ODNO
+	}
+	logKIS("✓ PlaceReservation: SUCCESS - Reservation %s (received %s), Msg: %s",
+		receipt.ResvOrderNo, receipt.ReceiptDate, rResp.Msg1)
+	return receipt, nil
+}
+
+// CancelReservation cancels a reservation that has not been forwarded yet
+func (c *Client) CancelReservation(ctx context.Context, r ReservationReceipt) error {
+	logKIS("CancelReservation: %s (received %s)", r.ResvOrderNo, r.ReceiptDate)
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ CancelReservation: Token error: %v", err)
+		return err
+	}
+
+	url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/order-resv-ccnl", c.Config.KisBaseURL)
+	logKIS("POST %s", url)
+
+	cano, prdt := c.getAccountParts()
+
+	// RSYN_ORD_RCIT_DT is spelled this way by the KIS API
+	body := map[string]string{
+		"CANO":             cano,
+		"ACNT_PRDT_CD":     prdt,
+		"RSYN_ORD_RCIT_DT": r.ReceiptDate,
+		"OVRS_RSVN_ODNO":   r.ResvOrderNo,
+	}
+	jsonBody, _ := json.Marshal(body)
+
+	resp, bodyBytes, err := c.send(ctx, apiCall{Method: "POST", URL: url, API: apiResvCancel, Body: jsonBody})
+	if err != nil {
+		logKIS("✗ CancelReservation: Request failed: %v", err)
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		logKIS("✗ CancelReservation: Failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		return c.apiError(apiResvCancel, resp.StatusCode, bodyBytes)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(bodyBytes, &env); err != nil {
+		logKIS("✗ CancelReservation: Failed to decode response: %v", err)
+		return err
+	}
+	if env.RtCd != "0" {
+		logKIS("⚠ CancelReservation: Response Code: %s, Msg: %s", env.RtCd, env.Msg1)
+		return c.apiError(apiResvCancel, resp.StatusCode, bodyBytes)
+	}
+
+	logKIS("✓ CancelReservation: SUCCESS - %s", env.Msg1)
+	return nil
+}
+
+// maxReservationPages guards against a continuation loop
+const maxReservationPages = 20
+
+// ListReservations returns the reservations received between startDate and
+// endDate (YYYYMMDD, KST), following CTX_AREA continuation.
+// KIS only offers this inquiry on the real environment.
+func (c *Client) ListReservations(ctx context.Context, startDate, endDate string) ([]ReservationItem, error) {
+	logKIS("ListReservations: %s ~ %s", startDate, endDate)
+
+	if c.mode == ModeVirtual {
+		return nil, broker.NewError(broker.CategoryValidation, "reservation order inquiry is not available in virtual mode")
+	}
+
+	if err := c.EnsureToken(ctx); err != nil {
+		logKIS("✗ ListReservations: Token error: %v", err)
+		return nil, err
+	}
+
+	cano, prdt := c.getAccountParts()
+
+	var items []ReservationItem
+	fk, nk := "", ""
+	for page := 0; page < maxReservationPages; page++ {
+		// INQR_DVSN_CD 00 = all, PRDT_TYPE_CD/OVRS_EXCG_CD empty = every US market
+		url := fmt.Sprintf("%s/uapi/overseas-stock/v1/trading/order-resv-list?CANO=%s&ACNT_PRDT_CD=%s&INQR_STRT_DT=%s&INQR_END_DT=%s&INQR_DVSN_CD=00&PRDT_TYPE_CD=&OVRS_EXCG_CD=&CTX_AREA_FK200=%s&CTX_AREA_NK200=%s",
+			c.Config.KisBaseURL, cano, prdt, startDate, endDate, neturl.QueryEscape(fk), neturl.QueryEscape(nk))
+		logKIS("GET %s", url)
+
+		resp, bodyBytes, err := c.send(ctx, apiCall{Method: "GET", URL: url, API: apiResvList, Cont: page > 0, Idempotent: true})
+		if err != nil {
+			logKIS("✗ ListReservations: Request failed: %v", err)
+			return nil, err
+		}
+
+		if resp.StatusCode != 200 {
+			logKIS("✗ ListReservations: Bad status %d: %s", resp.StatusCode, string(bodyBytes))
+			return nil, c.apiError(apiResvList, resp.StatusCode, bodyBytes)
+		}
+
+		var lResp ReservationListResponse
+		if err := json.Unmarshal(bodyBytes, &lResp); err != nil {
+			logKIS("✗ ListReservations: Failed to decode response: %v", err)
+			return nil, err
+		}
+		if lResp.RtCd != "0" && lResp.RtCd != "0000" {
+			logKIS("✗ ListReservations: API error (RtCd=%s): %s", lResp.RtCd, lResp.Msg1)
+			return nil, c.apiError(apiResvList, resp.StatusCode, bodyBytes)
+		}
+		items = append(items, lResp.Output...)
+
+		trCont := resp.Header.Get("tr_cont")
+		if (trCont != "F" && trCont != "M") || strings.TrimSpace(lResp.CtxAreaNK200) == "" {
+			break
+		}
+		fk, nk = lResp.CtxAreaFK200, lResp.CtxAreaNK200
+	}
+
+	logKIS("✓ ListReservations: %d rows", len(items))
+	return items, nil
+}
@@ -0,0 +1,263 @@
+package kis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// KIS per-second request quotas (per app key). We stay slightly below them.
+const (
+	realRatePerSec    = 18
+	virtualRatePerSec = 2
+)
+
+// rateLimiter is a token bucket shared by every call of a Client
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // tokens per second
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newRateLimiter(perSec float64) *rateLimiter {
+	return &rateLimiter{rate: perSec, burst: perSec, tokens: perSec, lastFill: time.Now()}
+}
+
+// Wait blocks until a token is available or ctx is done
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.lastFill).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.lastFill = now
+
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Retry/backoff settings
+const (
+	maxAttempts  = 4
+	baseBackoff  = 500 * time.Millisecond
+	maxBackoff   = 8 * time.Second
+	ambiguousTTL = 24 * time.Hour
+)
+
+// tokenMsgCodes are auth failures a fresh token fixes (the request was not processed)
+var tokenMsgCodes = map[string]bool{
+	"EGW00121": true, // 유효하지 않은 token
+	"EGW00123": true, // 기간이 만료된 token
+}
+
+// ErrAmbiguous means the request may or may not have reached KIS.
+// Non-idempotent calls (orders) are never retried after it.
+var ErrAmbiguous = broker.NewError(broker.CategoryUnavailable, "request outcome unknown")
+
+// envelope is the part of every KIS response used to classify failures
+type envelope struct {
+	RtCd  string `json:"rt_cd"`
+	MsgCd string `json:"msg_cd"`
+	Msg1  string `json:"msg1"`
+}
+
+// apiCall describes one KIS request
+type apiCall struct {
+	Method string
+	URL    string
+	API    string // TR ID lookup key
+	Body   []byte
+	// Cont sets tr_cont: N for continuation pages
+	Cont bool
+	// Idempotent calls may be retried after timeouts and 5xx responses.
+	// Orders are not: only failures KIS reports as "not processed" are retried.
+	Idempotent bool
+}
+
+// send executes call through the rate limiter with retries. It returns the
+// final response (body already read) or an error after the last attempt.
+// Cancelling ctx aborts waiting, the in-flight request and any further retry.
+func (c *Client) send(ctx context.Context, call apiCall) (*http.Response, []byte, error) {
+	refreshed := false
+
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, nil, err
+		}
+
+		var body io.Reader
+		if call.Body != nil {
+			body = bytes.NewReader(call.Body)
+		}
+		req, err := c.newRequest(ctx, call.Method, call.URL, call.API, body)
+		if err != nil {
+			return nil, nil, err
+		}
+		if call.Cont {
+			req.Header.Set("tr_cont", "N")
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			switch {
+			case !isDialError(err) && !call.Idempotent:
+				logKIS("✗ %s: Network failure after sending, NOT retrying: %v", call.API, err)
+				return nil, nil, fmt.Errorf("%w: %v", ErrAmbiguous, err)
+			case ctx.Err() != nil:
+				return nil, nil, ctx.Err()
+			case attempt >= maxAttempts:
+				return nil, nil, err
+			}
+			if err := c.backoff(ctx, call.API, attempt, err.Error()); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		bodyBytes, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			if !call.Idempotent {
+				return nil, nil, fmt.Errorf("%w: %v", ErrAmbiguous, readErr)
+			}
+			if attempt >= maxAttempts || ctx.Err() != nil {
+				return nil, nil, readErr
+			}
+			if err := c.backoff(ctx, call.API, attempt, readErr.Error()); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		var env envelope
+		json.Unmarshal(bodyBytes, &env)
+
+		switch {
+		case msgCodeCategories[env.MsgCd] == broker.CategoryRateLimit:
+			if attempt >= maxAttempts {
+				return resp, bodyBytes, nil
+			}
+			if err := c.backoff(ctx, call.API, attempt, "rate limited ("+env.MsgCd+")"); err != nil {
+				return nil, nil, err
+			}
+			continue
+
+		case tokenMsgCodes[env.MsgCd] || resp.StatusCode == http.StatusUnauthorized:
+			if refreshed {
+				return resp, bodyBytes, nil
+			}
+			logKIS("⚠ %s: Auth error (%s %s), refreshing token and retrying...", call.API, env.MsgCd, env.Msg1)
+			if err := c.ForceRefresh(ctx); err != nil {
+				return nil, nil, fmt.Errorf("token refresh failed: %w", err)
+			}
+			refreshed = true
+			continue
+
+		case resp.StatusCode >= 500:
+			if !call.Idempotent {
+				logKIS("✗ %s: Server error %d after sending, NOT retrying", call.API, resp.StatusCode)
+				return nil, nil, fmt.Errorf("%w: status %d: %s", ErrAmbiguous, resp.StatusCode, string(bodyBytes))
+			}
+			if attempt >= maxAttempts {
+				return resp, bodyBytes, nil
+			}
+			if err := c.backoff(ctx, call.API, attempt, fmt.Sprintf("status %d", resp.StatusCode)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		return resp, bodyBytes, nil
+	}
+}
+
+// backoff sleeps with exponential backoff and jitter before the next attempt
+func (c *Client) backoff(ctx context.Context, api string, attempt int, reason string) error {
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	d += time.Duration(rand.Int63n(int64(d / 2)))
+	logKIS("⚠ %s: %s, retrying in %v (attempt %d/%d)", api, reason, d.Round(time.Millisecond), attempt+1, maxAttempts)
+	return sleepCtx(ctx, d)
+}
+
+// sleepCtx sleeps for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isDialError reports failures that happened before the request was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// orderKey identifies an order for the ambiguous-outcome guard
+func orderKey(o OrderReq) string {
+	return fmt.Sprintf("%s|%s|%s|%d|%.4f|%s", o.ExchCode, o.Symbol, o.Side, o.Qty, o.Price, o.OrdType)
+}
+
+// checkAmbiguous refuses an order identical to one whose outcome is unknown
+func (c *Client) checkAmbiguous(o OrderReq) error {
+	c.ambMu.Lock()
+	defer c.ambMu.Unlock()
+
+	key := orderKey(o)
+	if at, ok := c.ambiguous[key]; ok {
+		if time.Since(at) < ambiguousTTL {
+			return broker.NewError(broker.CategoryConflict,
+				"identical order (%s %d %s @ %.2f) had an unknown outcome at %s; check open orders before resubmitting",
+				o.Side, o.Qty, o.Symbol, o.Price, at.Format("15:04:05"))
+		}
+		delete(c.ambiguous, key)
+	}
+	return nil
+}
+
+func (c *Client) markAmbiguous(o OrderReq) {
+	c.ambMu.Lock()
+	defer c.ambMu.Unlock()
+	c.ambiguous[orderKey(o)] = time.Now()
+}
+
+// ClearAmbiguous lifts the guard for o once its outcome has been verified
+func (c *Client) ClearAmbiguous(o OrderReq) {
+	c.ambMu.Lock()
+	defer c.ambMu.Unlock()
+	delete(c.ambiguous, orderKey(o))
+}
@@ -0,0 +1,116 @@
+package kis_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+)
+
+const pricePath = "/quotations/price"
+
+var tqqqBuy = kis.OrderReq{ExchCode: "NASD", Symbol: "TQQQ", Side: "BUY", Qty: 1, Price: 50, OrdType: kis.OrdLimit}
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	c, srv := newFakeClient(t, kisfake.Scenario{Prices: map[string]float64{"TQQQ": 50}}, 5)
+	ctx := t.Context()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := c.GetCurrentPrice(ctx, "NAS", "TQQQ"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A burst of 5, then one request every 200ms
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("10 requests at 5/s took %v", elapsed)
+	}
+	if n := srv.Count(pricePath); n != 10 {
+		t.Errorf("%d price requests, want 10", n)
+	}
+}
+
+func TestServerErrorOnInquiryRetried(t *testing.T) {
+	c, srv := newFakeClient(t, kisfake.Scenario{
+		Prices: map[string]float64{"TQQQ": 50},
+		Faults: []kisfake.Fault{{Path: pricePath, Times: 1, Status: 502}},
+	}, 0)
+
+	price, err := c.GetCurrentPrice(t.Context(), "NAS", "TQQQ")
+	if err != nil || price != 50 {
+		t.Fatalf("price after a 502 = %.2f, %v", price, err)
+	}
+	if n := srv.Count(pricePath); n != 2 {
+		t.Errorf("%d price requests, want the failed one and a retry", n)
+	}
+}
+
+func TestOrderNotResentAfterAmbiguousFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fault   kisfake.Fault
+		timeout time.Duration
+	}{
+		{"server error", kisfake.Fault{Path: "/trading/order", Times: 1, Status: 500, AfterApply: true}, 0},
+		{"timeout", kisfake.Fault{Path: "/trading/order", Times: 1, DelayMs: 2000}, 300 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, srv := newFakeClient(t, kisfake.Scenario{
+				Cash: 10000, Prices: map[string]float64{"TQQQ": 50}, Faults: []kisfake.Fault{tc.fault},
+			}, 0)
+			ctx := t.Context()
+			if err := c.EnsureToken(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if tc.timeout > 0 {
+				c.Client.Timeout = tc.timeout
+			}
+
+			if _, err := c.PlaceOrder(ctx, tqqqBuy); !errors.Is(err, kis.ErrAmbiguous) {
+				t.Fatalf("err = %v, want ErrAmbiguous", err)
+			}
+			if n := srv.Count("/trading/order"); n != 1 {
+				t.Errorf("order sent %d times, want once", n)
+			}
+
+			// The identical order stays blocked until its outcome is cleared
+			_, err := c.PlaceOrder(ctx, tqqqBuy)
+			if broker.CategoryOf(err) != broker.CategoryConflict || srv.Count("/trading/order") != 1 {
+				t.Fatalf("resend: err = %v, %d sent", err, srv.Count("/trading/order"))
+			}
+			c.ClearAmbiguous(tqqqBuy)
+			if _, err := c.PlaceOrder(ctx, tqqqBuy); err != nil {
+				t.Errorf("after ClearAmbiguous: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpiredTokenRefreshedOnce(t *testing.T) {
+	c, srv := newFakeClient(t, kisfake.Scenario{Prices: map[string]float64{"TQQQ": 50}}, 0)
+	ctx := t.Context()
+	if _, err := c.GetCurrentPrice(ctx, "NAS", "TQQQ"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake rejects the old token with EGW00123: one refresh, one retry
+	srv.ExpireTokens()
+	if _, err := c.GetCurrentPrice(ctx, "NAS", "TQQQ"); err != nil {
+		t.Fatalf("after token expiry: %v", err)
+	}
+	if n := srv.Count(pricePath); n != 3 {
+		t.Errorf("%d price requests, want 3 (first, rejected, retried)", n)
+	}
+
+	// A token error that survives the refresh is returned, not retried again
+	srv.Inject(kisfake.Fault{Path: pricePath, Status: 500, MsgCd: "EGW00123", Msg1: "기간이 만료된 token 입니다."})
+	if _, err := c.GetCurrentPrice(ctx, "NAS", "TQQQ"); err == nil {
+		t.Fatal("persistent token error: no error")
+	}
+	if n := srv.Count(pricePath); n != 5 {
+		t.Errorf("%d price requests, want 5 (two more: the rejected one and a single retry)", n)
+	}
+}
@@ -0,0 +1,62 @@
+package kisfake
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// handlePresentBalance serves inquire-present-balance: holdings, the USD
+// deposit with the scenario's exchange rate and KRW totals at that rate
+func (s *Server) handlePresentBalance(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(q.Get("CANO")); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	fx := s.sc.ExchangeRate
+	krw := func(usd float64) string { return strconv.FormatFloat(usd*fx, 'f', 0, 64) }
+
+	rows := []map[string]string{}
+	var purchase, eval float64
+	for _, h := range s.holdings {
+		if h.Qty <= 0 {
+			continue
+		}
+		price := s.lastPrice(h.Symbol, h.AvgPrice)
+		purchase += float64(h.Qty) * h.AvgPrice
+		eval += float64(h.Qty) * price
+		rows = append(rows, map[string]string{
+			"pdno":           h.Symbol,
+			"cblc_qty13":     strconv.Itoa(h.Qty),
+			"avg_unpr3":      fmtPrice(h.AvgPrice),
+			"ovrs_now_pric1": fmtPrice(price),
+			"evlu_pfls_amt2": krw(float64(h.Qty) * (price - h.AvgPrice)),
+			"ovrs_excg_cd":   h.Exchange,
+			"buy_crcy_cd":    "USD",
+			"bass_exrt":      fmtPrice(fx),
+		})
+	}
+
+	resp := withOutput(envelope("0", "KIOK0510", "조회가 완료되었습니다"), "output1", rows)
+	resp["output2"] = []map[string]string{{
+		"crcy_cd":                 "USD",
+		"frcr_dncl_amt_2":         fmtPrice(s.cash),
+		"frcr_drwg_psbl_amt_1":    fmtPrice(s.cash),
+		"frcr_evlu_amt2":          krw(s.cash),
+		"frst_bltn_exrt":          fmtPrice(fx),
+		"nxdy_frcr_drwg_psbl_amt": fmtPrice(s.cash),
+	}}
+	resp["output3"] = map[string]string{
+		"pchs_amt_smtl":      krw(purchase),
+		"evlu_amt_smtl":      krw(eval),
+		"evlu_pfls_amt_smtl": krw(eval - purchase),
+		"tot_dncl_amt":       krw(s.cash),
+		"tot_asst_amt":       krw(s.cash + eval),
+		"wdrw_psbl_tot_amt":  krw(s.cash),
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,153 @@
+package kisfake
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Execution is a fill recorded by the fake for the period profit and trade
+// history inquiries. The fake charges no fees.
+type Execution struct {
+	Date        string // YYYYMMDD (ET)
+	OrderNo     string
+	Exchange    string
+	Symbol      string
+	Sell        bool
+	Qty         int
+	Price       float64
+	AvgBuyPrice float64 // Holding average before the fill
+}
+
+// Executions returns a snapshot of the fills, oldest first
+func (s *Server) Executions() []Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Execution(nil), s.execs...)
+}
+
+// inRange reports whether a YYYYMMDD date falls in start..end (empty = open)
+func inRange(date, start, end string) bool {
+	return (start == "" || date >= start) && (end == "" || date <= end)
+}
+
+// handlePeriodProfit serves inquire-period-profit: sells summed per trade
+// date and symbol, newest first, in a single page
+func (s *Server) handlePeriodProfit(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(q.Get("CANO")); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	type key struct{ date, symbol string }
+	type agg struct {
+		exch            string
+		qty             int
+		buyAmt, sellAmt float64
+	}
+	sums := map[key]*agg{}
+	var keys []key
+	for _, e := range s.execs {
+		if !e.Sell || !inRange(e.Date, q.Get("INQR_STRT_DT"), q.Get("INQR_END_DT")) {
+			continue
+		}
+		k := key{e.Date, e.Symbol}
+		a, ok := sums[k]
+		if !ok {
+			a = &agg{exch: e.Exchange}
+			sums[k] = a
+			keys = append(keys, k)
+		}
+		a.qty += e.Qty
+		a.buyAmt += e.AvgBuyPrice * float64(e.Qty)
+		a.sellAmt += e.Price * float64(e.Qty)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].date > keys[j].date })
+
+	rows := []map[string]string{}
+	var totBuy, totSell float64
+	for _, k := range keys {
+		a := sums[k]
+		pl := a.sellAmt - a.buyAmt
+		rate := 0.0
+		if a.buyAmt > 0 {
+			rate = pl / a.buyAmt * 100
+		}
+		totBuy += a.buyAmt
+		totSell += a.sellAmt
+		rows = append(rows, map[string]string{
+			"trad_day":           k.date,
+			"ovrs_pdno":          k.symbol,
+			"ovrs_item_name":     k.symbol,
+			"slcl_qty":           strconv.Itoa(a.qty),
+			"pchs_avg_pric":      fmtPrice(a.buyAmt / float64(a.qty)),
+			"frcr_pchs_amt1":     fmtPrice(a.buyAmt),
+			"avg_sll_unpr":       fmtPrice(a.sellAmt / float64(a.qty)),
+			"frcr_sll_amt_smtl1": fmtPrice(a.sellAmt),
+			"stck_sll_tlex":      fmtPrice(0),
+			"ovrs_rlzt_pfls_amt": fmtPrice(pl),
+			"pftrt":              fmtPrice(rate),
+			"ovrs_excg_cd":       a.exch,
+		})
+	}
+
+	resp := withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output1", rows)
+	resp["output2"] = map[string]string{
+		"stck_sll_amt_smtl":      fmtPrice(totSell),
+		"stck_buy_amt_smtl":      fmtPrice(totBuy),
+		"smtl_fee1":              fmtPrice(0),
+		"ovrs_rlzt_pfls_tot_amt": fmtPrice(totSell - totBuy),
+	}
+	w.Header().Set("tr_cont", "D")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handlePeriodTrans serves inquire-period-trans: every fill in the range,
+// newest first, in a single page. Settlement is dated T+1.
+func (s *Server) handlePeriodTrans(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(q.Get("CANO")); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	rows := []map[string]string{}
+	for i := len(s.execs) - 1; i >= 0; i-- {
+		e := s.execs[i]
+		if !inRange(e.Date, q.Get("ERLM_STRT_DT"), q.Get("ERLM_END_DT")) {
+			continue
+		}
+		side, sideName := "02", "매수"
+		if e.Sell {
+			side, sideName = "01", "매도"
+		}
+		settle := e.Date
+		if d, err := time.Parse("20060102", e.Date); err == nil {
+			settle = d.AddDate(0, 0, 1).Format("20060102")
+		}
+		amt := e.Price * float64(e.Qty)
+		rows = append(rows, map[string]string{
+			"trad_dt":             e.Date,
+			"sttl_dt":             settle,
+			"sll_buy_dvsn_cd":     side,
+			"sll_buy_dvsn_name":   sideName,
+			"pdno":                e.Symbol,
+			"ovrs_item_name":      e.Symbol,
+			"ccld_qty":            strconv.Itoa(e.Qty),
+			"ovrs_stck_ccld_unpr": fmtPrice(e.Price),
+			"tr_frcr_amt2":        fmtPrice(amt),
+			"frcr_excc_amt_1":     fmtPrice(amt),
+			"frcr_fee1":           fmtPrice(0),
+			"crcy_cd":             "USD",
+		})
+	}
+	w.Header().Set("tr_cont", "D")
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output1", rows))
+}
@@ -0,0 +1,272 @@
+package kisfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Realtime paths: KIS serves the WebSocket on its own host, the fake under pathWebSocket
+const (
+	pathApproval  = "/oauth2/Approval"
+	pathWebSocket = "/ws"
+)
+
+// Realtime TR IDs
+const (
+	wsTrade    = "HDFSCNT0"
+	wsQuote    = "HDFSASP0"
+	wsPingPong = "PINGPONG"
+)
+
+// maxWSSubscriptions is the KIS limit of registrations per session
+const maxWSSubscriptions = 41
+
+// wsConn is one realtime session and its registrations (tr_id|tr_key)
+type wsConn struct {
+	ws   *websocket.Conn
+	mu   sync.Mutex // Guards subs and serializes writes
+	subs map[string]bool
+}
+
+func (c *wsConn) send(msg string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return websocket.Message.Send(c.ws, msg)
+}
+
+// WSURL is the realtime endpoint of a fake started by NewServer
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + pathWebSocket
+}
+
+// handleApproval issues a WebSocket approval key
+func (s *Server) handleApproval(w http.ResponseWriter, body map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sc.AppKey != "" && body["appkey"] != s.sc.AppKey {
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"error_code":        "EGW00103",
+			"error_description": "유효하지 않은 AppKey입니다.",
+		})
+		return
+	}
+	s.approvalSeq++
+	key := fmt.Sprintf("fake-approval-%d", s.approvalSeq)
+	s.approvals[key] = true
+	writeJSON(w, http.StatusOK, map[string]string{"approval_key": key})
+}
+
+// handleWebSocket runs one realtime session: registrations, releases and PINGPONG echoes
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(func(ws *websocket.Conn) {
+		c := &wsConn{ws: ws, subs: make(map[string]bool)}
+		s.mu.Lock()
+		s.wsConns[c] = true
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.wsConns, c)
+			s.mu.Unlock()
+			ws.Close()
+		}()
+
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			if reply := s.wsMessage(c, msg); reply != "" {
+				if err := c.send(reply); err != nil {
+					return
+				}
+			}
+		}
+	}).ServeHTTP(w, r)
+}
+
+// wsMessage applies one client message and returns the reply, if any
+func (s *Server) wsMessage(c *wsConn, msg string) string {
+	var req struct {
+		Header struct {
+			ApprovalKey string `json:"approval_key"`
+			TrType      string `json:"tr_type"`
+			TrID        string `json:"tr_id"` // Set on PINGPONG echoes
+		} `json:"header"`
+		Body struct {
+			Input struct {
+				TrID  string `json:"tr_id"`
+				TrKey string `json:"tr_key"`
+			} `json:"input"`
+		} `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(msg), &req); err != nil {
+		return wsReply("", "", "1", "OPSP9999", "JSON PARSING ERROR")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Header.TrID == wsPingPong {
+		s.pongs++
+		return ""
+	}
+	trID, trKey := req.Body.Input.TrID, req.Body.Input.TrKey
+	if !s.approvals[req.Header.ApprovalKey] {
+		return wsReply(trID, trKey, "1", "OPSP8996", "invalid approval : NOT FOUND")
+	}
+	if trID != wsTrade && trID != wsQuote {
+		return wsReply(trID, trKey, "1", "OPSP0011", "invalid tr_id")
+	}
+
+	sub := trID + "|" + trKey
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch req.Header.TrType {
+	case "1":
+		if c.subs[sub] {
+			return wsReply(trID, trKey, "0", "OPSP0002", "ALREADY IN SUBSCRIBE")
+		}
+		if len(c.subs) >= maxWSSubscriptions {
+			return wsReply(trID, trKey, "1", "OPSP0008", "MAX SUBSCRIBE OVER")
+		}
+		c.subs[sub] = true
+		return wsReply(trID, trKey, "0", "OPSP0000", "SUBSCRIBE SUCCESS")
+	case "2":
+		delete(c.subs, sub)
+		return wsReply(trID, trKey, "0", "OPSP0001", "UNSUBSCRIBE SUCCESS")
+	}
+	return wsReply(trID, trKey, "1", "OPSP0009", "invalid tr_type")
+}
+
+func wsReply(trID, trKey, rtCd, msgCd, msg1 string) string {
+	out, _ := json.Marshal(map[string]interface{}{
+		"header": map[string]string{"tr_id": trID, "tr_key": trKey, "encrypt": "N"},
+		"body":   map[string]string{"rt_cd": rtCd, "msg_cd": msgCd, "msg1": msg1},
+	})
+	return string(out)
+}
+
+// Subscriptions returns the registrations of every open session (tr_id|tr_key), sorted
+func (s *Server) Subscriptions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for c := range s.wsConns {
+		c.mu.Lock()
+		for sub := range c.subs {
+			out = append(out, sub)
+		}
+		c.mu.Unlock()
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Pongs returns how many PINGPONG echoes the sessions sent back
+func (s *Server) Pongs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pongs
+}
+
+// Ping sends a PINGPONG to every open session
+func (s *Server) Ping() {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"header": map[string]string{"tr_id": wsPingPong, "datetime": s.Now().Format("20060102150405")},
+	})
+	s.broadcast(func(*wsConn) string { return string(msg) })
+}
+
+// DropStreams closes every open realtime session, as a network failure would
+func (s *Server) DropStreams() {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.wsConns))
+	for c := range s.wsConns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.ws.Close()
+	}
+}
+
+// PushQuote sends a level 1 quote of symbol to the sessions subscribed to it
+func (s *Server) PushQuote(symbol string, bid, ask float64, bidSize, askSize int) {
+	now := s.Now().In(s.loc)
+	s.broadcast(func(c *wsConn) string {
+		key, ok := c.subscribed(wsQuote, symbol)
+		if !ok {
+			return ""
+		}
+		f := []string{key, symbol, "4",
+			now.Format("20060102"), now.Format("150405"),
+			now.In(kst).Format("20060102"), now.In(kst).Format("150405"),
+			strconv.Itoa(bidSize), strconv.Itoa(askSize), "0", "0",
+			fmtPrice(bid), fmtPrice(ask), strconv.Itoa(bidSize), strconv.Itoa(askSize), "0", "0"}
+		return "0|" + wsQuote + "|001|" + strings.Join(f, "^")
+	})
+}
+
+// pushTrade sends the last price of symbol to the sessions subscribed to it.
+// Caller must not hold mu.
+func (s *Server) pushTrade(symbol string, price float64, qty int) {
+	now := s.Now().In(s.loc)
+	s.mu.Lock()
+	s.wsVolume[symbol] += int64(qty)
+	vol := s.wsVolume[symbol]
+	s.mu.Unlock()
+	s.broadcast(func(c *wsConn) string {
+		key, ok := c.subscribed(wsTrade, symbol)
+		if !ok {
+			return ""
+		}
+		date, hms := now.Format("20060102"), now.Format("150405")
+		p := fmtPrice(price)
+		f := []string{key, symbol, "4", date, date, hms,
+			now.In(kst).Format("20060102"), now.In(kst).Format("150405"),
+			p, p, p, p, "3", "0", "0",
+			fmtPrice(price - 0.01), fmtPrice(price + 0.01), "100", "100",
+			strconv.Itoa(qty), strconv.FormatInt(vol, 10), fmtPrice(price * float64(vol)),
+			"0", "0", "100", "1"}
+		return "0|" + wsTrade + "|001|" + strings.Join(f, "^")
+	})
+}
+
+// subscribed returns the tr_key under which c registered trID for symbol
+func (c *wsConn) subscribed(trID, symbol string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for sub := range c.subs {
+		id, key, _ := strings.Cut(sub, "|")
+		// tr_key is D + 3-letter exchange + symbol
+		if id == trID && len(key) == 4+len(symbol) && strings.HasSuffix(key, symbol) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// broadcast sends msg(c) to every open session, skipping empty messages
+func (s *Server) broadcast(msg func(c *wsConn) string) {
+	s.mu.Lock()
+	conns := make([]*wsConn, 0, len(s.wsConns))
+	for c := range s.wsConns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		if m := msg(c); m != "" {
+			c.send(m)
+		}
+	}
+}
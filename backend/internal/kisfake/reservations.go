@@ -0,0 +1,190 @@
+package kisfake
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// resvOrdDvsnAllowed lists the ORD_DVSN codes reservations accept: buys are
+// limit only, sells also take MOO
+var resvOrdDvsnAllowed = map[bool]map[string]bool{
+	false: {"00": true},
+	true:  {"00": true, "31": true},
+}
+
+// kst dates reservation receipts, as KIS does
+var kst = time.FixedZone("KST", 9*60*60)
+
+// Reservation is a reservation order held by the fake until ReleaseReservations
+type Reservation struct {
+	ResvNo       string
+	ReceiptDate  string // YYYYMMDD (KST)
+	ReceiptTime  string // HHMMSS (KST)
+	Exchange     string
+	Symbol       string
+	Sell         bool
+	Qty          int
+	Price        float64
+	OrdType      string
+	Cancelled    bool
+	OrderNo      string // Live order once released
+	RejectReason string // Why the release failed
+}
+
+// Pending reports whether the reservation still waits for the open
+func (r *Reservation) Pending() bool {
+	return !r.Cancelled && r.OrderNo == "" && r.RejectReason == ""
+}
+
+// Reservations returns a snapshot of the reservations, oldest first
+func (s *Server) Reservations() []Reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Reservation, 0, len(s.resvs))
+	for _, r := range s.resvs {
+		out = append(out, *r)
+	}
+	return out
+}
+
+// ReleaseReservations simulates the session open: every pending reservation
+// is booked as a regular order, or marked unprocessed when cash or holdings
+// no longer cover it. It returns how many orders were booked.
+func (s *Server) ReleaseReservations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := 0
+	for _, r := range s.resvs {
+		if !r.Pending() {
+			continue
+		}
+		if msg := s.checkOrder(r.Symbol, r.Sell, r.Qty, r.Price, r.OrdType, resvOrdDvsnAllowed); msg != nil {
+			r.RejectReason = fmt.Sprint(msg["msg1"])
+			log.Printf("[KISFAKE] Reservation %s not sent: %s", r.ResvNo, r.RejectReason)
+			continue
+		}
+		o := s.book(&Order{
+			Exchange: r.Exchange,
+			Symbol:   r.Symbol,
+			Sell:     r.Sell,
+			Qty:      r.Qty,
+			Price:    r.Price,
+			OrdType:  r.OrdType,
+		})
+		r.OrderNo = o.OrderNo
+		sent++
+		if s.sc.AutoFill {
+			s.autoFill(o)
+		}
+	}
+	return sent
+}
+
+func (s *Server) handleReservation(w http.ResponseWriter, body map[string]string, sell bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(body["CANO"]); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	qty, _ := strconv.Atoi(body["FT_ORD_QTY"])
+	price, _ := strconv.ParseFloat(body["FT_ORD_UNPR3"], 64)
+	if msg := checkOrderFields(sell, qty, price, body["ORD_DVSN"], resvOrdDvsnAllowed); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	now := s.Now().In(kst)
+	s.resvSeq++
+	r := &Reservation{
+		ResvNo:      fmt.Sprintf("%010d", s.resvSeq),
+		ReceiptDate: now.Format("20060102"),
+		ReceiptTime: now.Format("150405"),
+		Exchange:    body["OVRS_EXCG_CD"],
+		Symbol:      body["PDNO"],
+		Sell:        sell,
+		Qty:         qty,
+		Price:       price,
+		OrdType:     body["ORD_DVSN"],
+	}
+	s.resvs = append(s.resvs, r)
+	log.Printf("[KISFAKE] Reserved %s: sell=%v %d %s @ %.2f", r.ResvNo, r.Sell, r.Qty, r.Symbol, r.Price)
+
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "APBK0013", "예약주문 접수 완료 되었습니다."), "output", map[string]string{
+		"ODNO":             r.ResvNo,
+		"RSVN_ORD_RCIT_DT": r.ReceiptDate,
+		"OVRS_RSVN_ODNO":   r.ResvNo,
+	}))
+}
+
+func (s *Server) handleReservationCancel(w http.ResponseWriter, body map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(body["CANO"]); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+	for _, r := range s.resvs {
+		if r.ResvNo == body["OVRS_RSVN_ODNO"] && r.ReceiptDate == body["RSYN_ORD_RCIT_DT"] && r.Pending() {
+			r.Cancelled = true
+			writeJSON(w, http.StatusOK, envelope("0", "APBK0014", "예약주문 취소 완료 되었습니다."))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, envelope("1", codeNoOrder, "취소 가능한 예약주문이 없습니다."))
+}
+
+// handleReservationList serves order-resv-list rows received in the date range, newest first
+func (s *Server) handleReservationList(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, end := q.Get("INQR_STRT_DT"), q.Get("INQR_END_DT")
+	rows := []map[string]string{}
+	for i := len(s.resvs) - 1; i >= 0; i-- {
+		r := s.resvs[i]
+		if (start != "" && r.ReceiptDate < start) || (end != "" && r.ReceiptDate > end) {
+			continue
+		}
+		rows = append(rows, s.reservationRow(r))
+	}
+	w.Header().Set("tr_cont", "D")
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output", rows))
+}
+
+func (s *Server) reservationRow(r *Reservation) map[string]string {
+	side := "02"
+	if r.Sell {
+		side = "01"
+	}
+	cancelled := "N"
+	if r.Cancelled {
+		cancelled = "Y"
+	}
+	filled := 0
+	if o := s.findOrder(r.OrderNo); r.OrderNo != "" && o != nil {
+		filled = o.FilledQty
+	}
+	return map[string]string{
+		"cncl_yn":          cancelled,
+		"rsvn_ord_rcit_dt": r.ReceiptDate,
+		"ovrs_rsvn_odno":   r.ResvNo,
+		"odno":             r.OrderNo,
+		"sll_buy_dvsn_cd":  side,
+		"pdno":             r.Symbol,
+		"ord_rcit_tmd":     r.ReceiptTime,
+		"ovrs_excg_cd":     r.Exchange,
+		"ft_ord_qty":       strconv.Itoa(r.Qty),
+		"ft_ord_unpr3":     fmtPrice(r.Price),
+		"ft_ccld_qty":      strconv.Itoa(filled),
+		"nprc_rson_text":   r.RejectReason,
+	}
+}
@@ -0,0 +1,77 @@
+package kisfake
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Holding is a position the fake account starts with
+type Holding struct {
+	Symbol   string  `json:"symbol"`
+	Exchange string  `json:"exchange"` // NASD, NYSE, AMEX (default NASD)
+	Qty      int     `json:"qty"`
+	AvgPrice float64 `json:"avg_price"`
+}
+
+// Bar is one daily row served by the daily price API
+type Bar struct {
+	Date   string  `json:"date"` // YYYYMMDD
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume int64   `json:"volume"`
+}
+
+// Fault makes matching requests fail. Requests are matched by URL path suffix.
+type Fault struct {
+	Path  string `json:"path"`  // Path suffix, e.g. "/trading/order". Empty matches every API call
+	Skip  int    `json:"skip"`  // Let this many matching requests through first
+	Times int    `json:"times"` // Number of requests to fail, 0 = every matching request
+
+	DelayMs int    `json:"delay_ms"` // Sleep before answering (client timeouts)
+	Status  int    `json:"status"`   // HTTP status of the error response (default 500)
+	MsgCd   string `json:"msg_cd"`   // KIS error envelope (rt_cd=1)
+	Msg1    string `json:"msg1"`
+	Drop    bool   `json:"drop"` // Close the connection without a response
+
+	// AfterApply processes the request (e.g. books the order) before failing,
+	// simulating a response lost after KIS accepted it
+	AfterApply bool `json:"after_apply"`
+}
+
+// Scenario is the initial state of the fake account and market
+type Scenario struct {
+	AppKey     string `json:"app_key"`     // Required appkey header when set
+	AccountNum string `json:"account_num"` // Required CANO (8 digits) when set
+
+	Cash       float64            `json:"cash"`
+	RealizedPL float64            `json:"realized_pl"`
+	Prices     map[string]float64 `json:"prices"` // Last price per symbol
+	Daily      map[string][]Bar   `json:"daily"`  // Newest first
+	Holdings   []Holding          `json:"holdings"`
+	// ExchangeRate is the USD/KRW rate of the present balance inquiry (default 1350)
+	ExchangeRate float64 `json:"exchange_rate"`
+
+	MarketClosed bool `json:"market_closed"` // Reject orders as outside trading hours
+	AutoFill     bool `json:"auto_fill"`     // Fill marketable orders at the last price on arrival
+
+	BalancePageSize int `json:"balance_page_size"` // Holdings per balance page (default 50)
+	DailyPageSize   int `json:"daily_page_size"`   // Rows per daily price page (default 100)
+	// BalanceAllOnNASD makes NASD balance inquiries return the holdings of
+	// every US exchange, as some real accounts do
+	BalanceAllOnNASD bool `json:"balance_all_on_nasd"`
+
+	Faults []Fault `json:"faults"`
+}
+
+// LoadScenario reads a Scenario from a JSON file
+func LoadScenario(path string) (Scenario, error) {
+	var sc Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sc, err
+	}
+	err = json.Unmarshal(data, &sc)
+	return sc, err
+}
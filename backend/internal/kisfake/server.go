@@ -0,0 +1,1126 @@
+// Package kisfake is an in-process fake of the KIS overseas stock REST API.
+//
+// It serves token issuance, price, daily price (BYMD paging), balance
+// (CTX_AREA continuation), buying power, order, revise/cancel, reservation
+// orders and order inquiries from a scriptable Scenario, with fault
+// injection, so kis.Client, the Strategy and the API handlers can run
+// end-to-end without a KIS account. The realtime WebSocket (approval key,
+// trade/quote registrations, PINGPONG) is served at WSURL:
+//
+//	fake := kisfake.NewServer(kisfake.Scenario{Cash: 10000, Prices: map[string]float64{"TQQQ": 50}})
+//	defer fake.Close()
+//	cfg.KisBaseURL = fake.URL
+package kisfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// API paths
+const (
+	pathToken       = "/oauth2/tokenP"
+	pathPrice       = "/uapi/overseas-price/v1/quotations/price"
+	pathDailyPrice  = "/uapi/overseas-price/v1/quotations/dailyprice"
+	pathOrder       = "/uapi/overseas-stock/v1/trading/order"
+	pathReviseCncl  = "/uapi/overseas-stock/v1/trading/order-rvsecncl"
+	pathBalance     = "/uapi/overseas-stock/v1/trading/inquire-balance"
+	pathBuyingPower = "/uapi/overseas-stock/v1/trading/inquire-psamount"
+	pathOrderHist   = "/uapi/overseas-stock/v1/trading/inquire-ccnl"
+	pathUnfilled    = "/uapi/overseas-stock/v1/trading/inquire-nccs"
+	pathResv        = "/uapi/overseas-stock/v1/trading/order-resv"
+	pathResvCncl    = "/uapi/overseas-stock/v1/trading/order-resv-ccnl"
+	pathResvList    = "/uapi/overseas-stock/v1/trading/order-resv-list"
+	pathProfit      = "/uapi/overseas-stock/v1/trading/inquire-period-profit"
+	pathTrans       = "/uapi/overseas-stock/v1/trading/inquire-period-trans"
+	pathPresent     = "/uapi/overseas-stock/v1/trading/inquire-present-balance"
+)
+
+// trIDs lists the TR IDs accepted on each path (real and virtual)
+var trIDs = map[string][]string{
+	pathPrice:       {"HHDFS76200200"},
+	pathDailyPrice:  {"HHDFS76240000"},
+	pathOrder:       {"TTTT1002U", "VTTT1002U", "TTTT1006U", "VTTT1006U"},
+	pathReviseCncl:  {"TTTT1004U", "VTTT1004U"},
+	pathBalance:     {"TTTS3012R", "VTTS3012R"},
+	pathBuyingPower: {"TTTS3007R", "VTTS3007R"},
+	pathOrderHist:   {"TTTS3035R", "VTTS3035R"},
+	pathUnfilled:    {"TTTS3018R"},
+	pathResv:        {"TTTT3014U", "VTTT3014U", "TTTT3016U", "VTTT3016U"},
+	pathResvCncl:    {"TTTT3017U", "VTTT3017U"},
+	pathResvList:    {"TTTT3039R"},
+	pathProfit:      {"TTTS3039R"},
+	pathTrans:       {"CTOS4001R"},
+	pathPresent:     {"CTRP6504R", "VTRP6504R"},
+}
+
+// Business error codes are specific to the fake; msg1 mirrors the KIS wording,
+// which is what the client classifies on. Gateway codes (EGW*, OPSQ0002) are KIS's own.
+const (
+	codeNoURL        = "FAKE0404"
+	codeAccount      = "FAKE0001"
+	codeTrCont       = "FAKE0002"
+	codeMarketClosed = "FAKE0100"
+	codeBadQty       = "FAKE0101"
+	codeBadPrice     = "FAKE0102"
+	codeBadOrdDvsn   = "FAKE0103"
+	codeFunds        = "FAKE0200"
+	codeSellable     = "FAKE0201"
+	codeNoOrder      = "FAKE0300"
+)
+
+// sellTrIDs are the order and reservation TR IDs that sell
+var sellTrIDs = map[string]bool{"TTTT1006U": true, "VTTT1006U": true, "TTTT3016U": true, "VTTT3016U": true}
+
+// ordDvsnAllowed lists the ORD_DVSN codes each side accepts:
+// 00 limit, 31 MOO, 32 LOO, 33 MOC, 34 LOC
+var ordDvsnAllowed = map[bool]map[string]bool{
+	false: {"00": true, "32": true, "34": true},
+	true:  {"00": true, "31": true, "32": true, "33": true, "34": true},
+}
+
+// Auction order divisions, filled only by Auction
+var (
+	openAuctionDvsn  = map[string]bool{"31": true, "32": true}
+	closeAuctionDvsn = map[string]bool{"33": true, "34": true}
+)
+
+// Order is an order booked by the fake
+type Order struct {
+	OrderNo      string
+	OrigOrderNo  string
+	ReviseCancel string // "01" revise / "02" cancel rows
+	Date         string // YYYYMMDD (ET)
+	Time         string // HHMMSS
+	Exchange     string
+	Symbol       string
+	Sell         bool
+	Qty          int
+	Price        float64
+	OrdType      string
+	FilledQty    int
+	FilledAmt    float64
+	Closed       bool // Cancelled, revised away or rejected
+	RejectReason string
+}
+
+// Open reports whether the order still works
+func (o *Order) Open() bool {
+	return !o.Closed && o.FilledQty < o.Qty
+}
+
+// Request is a call recorded by the fake
+type Request struct {
+	Method string
+	Path   string
+	TrID   string
+	TrCont string
+	Query  url.Values
+	Body   map[string]string
+}
+
+type faultState struct {
+	Fault
+	seen int
+	hits int
+}
+
+// Server is the fake KIS API. It implements http.Handler; NewServer also
+// starts it on a local port.
+type Server struct {
+	URL string // Base URL when started by NewServer
+	ts  *httptest.Server
+
+	// Now is the clock used for order timestamps (ET)
+	Now func() time.Time
+
+	mu       sync.Mutex
+	sc       Scenario
+	cash     float64
+	realized float64
+	holdings []*Holding
+	orders   []*Order
+	faults   []*faultState
+	tokens   map[string]bool
+	tokenSeq int
+	orderSeq int
+	resvs    []*Reservation
+	resvSeq  int
+	execs    []Execution
+	requests []Request
+	loc      *time.Location
+
+	approvals   map[string]bool
+	approvalSeq int
+	wsConns     map[*wsConn]bool
+	wsVolume    map[string]int64
+	pongs       int
+}
+
+// New builds a fake from sc without starting a listener
+func New(sc Scenario) *Server {
+	if sc.BalancePageSize <= 0 {
+		sc.BalancePageSize = 50
+	}
+	if sc.DailyPageSize <= 0 {
+		sc.DailyPageSize = 100
+	}
+	if sc.ExchangeRate <= 0 {
+		sc.ExchangeRate = 1350
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.UTC
+	}
+
+	s := &Server{
+		Now:      time.Now,
+		sc:       sc,
+		cash:     sc.Cash,
+		realized: sc.RealizedPL,
+		tokens:   make(map[string]bool),
+		loc:      loc,
+
+		approvals: make(map[string]bool),
+		wsConns:   make(map[*wsConn]bool),
+		wsVolume:  make(map[string]int64),
+	}
+	for i := range sc.Holdings {
+		h := sc.Holdings[i]
+		if h.Exchange == "" {
+			h.Exchange = "NASD"
+		}
+		s.holdings = append(s.holdings, &h)
+	}
+	for _, f := range sc.Faults {
+		s.Inject(f)
+	}
+	return s
+}
+
+// NewServer starts a fake on a local port; URL is its base URL
+func NewServer(sc Scenario) *Server {
+	s := New(sc)
+	s.ts = httptest.NewServer(s)
+	s.URL = s.ts.URL
+	return s
+}
+
+// Close stops a server started by NewServer
+func (s *Server) Close() {
+	if s.ts != nil {
+		s.ts.Close()
+	}
+}
+
+// Inject adds a fault
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultState{Fault: f})
+}
+
+// ClearFaults removes every fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// ExpireTokens invalidates every issued token (EGW00123 on next use)
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+// SetPrice sets the last price of symbol, fills marketable orders when
+// AutoFill is on and sends the trade to realtime subscribers
+func (s *Server) SetPrice(symbol string, price float64) {
+	s.mu.Lock()
+	if s.sc.Prices == nil {
+		s.sc.Prices = make(map[string]float64)
+	}
+	s.sc.Prices[symbol] = price
+	if s.sc.AutoFill {
+		for _, o := range s.orders {
+			if o.Symbol == symbol && o.Open() {
+				s.autoFill(o)
+			}
+		}
+	}
+	s.mu.Unlock()
+	s.pushTrade(symbol, price, 1)
+}
+
+// SetMarketClosed toggles rejection of orders as outside trading hours
+func (s *Server) SetMarketClosed(closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sc.MarketClosed = closed
+}
+
+// Fill fills qty shares of an open order at price
+func (s *Server) Fill(orderNo string, qty int, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrder(orderNo)
+	if o == nil {
+		return fmt.Errorf("order not found: %s", orderNo)
+	}
+	if !o.Open() {
+		return fmt.Errorf("order %s is not open", orderNo)
+	}
+	if remaining := o.Qty - o.FilledQty; qty > remaining {
+		qty = remaining
+	}
+	s.fill(o, qty, price)
+	return nil
+}
+
+// Orders returns a snapshot of the booked orders, oldest first
+func (s *Server) Orders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		out = append(out, *o)
+	}
+	return out
+}
+
+// Cash returns the fake account's cash
+func (s *Server) Cash() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cash
+}
+
+// Holdings returns a snapshot of the positions
+func (s *Server) Holdings() []Holding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Holding
+	for _, h := range s.holdings {
+		if h.Qty > 0 {
+			out = append(out, *h)
+		}
+	}
+	return out
+}
+
+// Requests returns every recorded API call (token calls excluded)
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Count returns how many recorded calls hit a path ending in suffix
+func (s *Server) Count(suffix string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if strings.HasSuffix(r.Path, suffix) {
+			n++
+		}
+	}
+	return n
+}
+
+// ServeHTTP dispatches one request, applying faults first
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == pathWebSocket {
+		s.handleWebSocket(w, r)
+		return
+	}
+
+	var body map[string]string
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	if r.URL.Path == pathToken {
+		s.handleToken(w, body)
+		return
+	}
+	if r.URL.Path == pathApproval {
+		s.handleApproval(w, body)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		TrID:   r.Header.Get("tr_id"),
+		TrCont: r.Header.Get("tr_cont"),
+		Query:  r.URL.Query(),
+		Body:   body,
+	})
+	fault := s.matchFault(r.URL.Path)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.DelayMs > 0 {
+			select {
+			case <-time.After(time.Duration(fault.DelayMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if !fault.fails() {
+			fault = nil // Slow response only
+		}
+	}
+	if fault != nil && !fault.AfterApply {
+		s.fail(w, fault)
+		return
+	}
+
+	if fault != nil {
+		s.dispatch(httptest.NewRecorder(), r, body)
+		s.fail(w, fault)
+		return
+	}
+	s.dispatch(w, r, body)
+}
+
+func (f *faultState) fails() bool {
+	return f.Drop || f.Status != 0 || f.MsgCd != "" || f.Msg1 != ""
+}
+
+// matchFault returns the first active fault for path. Caller holds mu.
+func (s *Server) matchFault(path string) *faultState {
+	for _, f := range s.faults {
+		if f.Path != "" && !strings.HasSuffix(path, f.Path) {
+			continue
+		}
+		f.seen++
+		if f.seen <= f.Skip {
+			continue
+		}
+		if f.Times > 0 && f.hits >= f.Times {
+			continue
+		}
+		f.hits++
+		return f
+	}
+	return nil
+}
+
+func (s *Server) fail(w http.ResponseWriter, f *faultState) {
+	if f.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, envelope("1", f.MsgCd, f.Msg1))
+}
+
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request, body map[string]string) {
+	trID := r.Header.Get("tr_id")
+	allowed, known := trIDs[r.URL.Path]
+	if !known {
+		writeJSON(w, http.StatusNotFound, envelope("1", codeNoURL, "없는 URL 입니다."))
+		return
+	}
+	if !contains(allowed, trID) {
+		writeJSON(w, http.StatusOK, envelope("1", "OPSQ0002", "없는 서비스 코드 입니다. (tr_id="+trID+")"))
+		return
+	}
+	if msg := s.checkAuth(r); msg != nil {
+		writeJSON(w, http.StatusInternalServerError, msg)
+		return
+	}
+
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case pathPrice:
+		s.handlePrice(w, q)
+	case pathDailyPrice:
+		s.handleDailyPrice(w, q)
+	case pathBalance:
+		s.handleBalance(w, r, q)
+	case pathBuyingPower:
+		s.handleBuyingPower(w, q)
+	case pathOrder:
+		s.handleOrder(w, body, sellTrIDs[trID])
+	case pathReviseCncl:
+		s.handleReviseCancel(w, body)
+	case pathOrderHist:
+		s.handleOrderHistory(w, q)
+	case pathUnfilled:
+		s.handleUnfilled(w, q)
+	case pathResv:
+		s.handleReservation(w, body, sellTrIDs[trID])
+	case pathResvCncl:
+		s.handleReservationCancel(w, body)
+	case pathResvList:
+		s.handleReservationList(w, q)
+	case pathProfit:
+		s.handlePeriodProfit(w, q)
+	case pathTrans:
+		s.handlePeriodTrans(w, q)
+	case pathPresent:
+		s.handlePresentBalance(w, q)
+	}
+}
+
+// checkAuth validates the bearer token and app key; nil means OK
+func (s *Server) checkAuth(r *http.Request) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sc.AppKey != "" && r.Header.Get("appkey") != s.sc.AppKey {
+		return envelope("1", "EGW00103", "유효하지 않은 AppKey입니다.")
+	}
+	token := strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ")
+	if !s.tokens[token] {
+		return envelope("1", "EGW00123", "기간이 만료된 token 입니다.")
+	}
+	return nil
+}
+
+// checkAccount validates CANO when the scenario pins an account; nil means OK
+func (s *Server) checkAccount(cano string) map[string]interface{} {
+	acc := s.sc.AccountNum
+	if acc != "" && len(acc) >= 8 && cano != acc[:8] {
+		return envelope("1", codeAccount, "INVALID_CHECK_ACNO")
+	}
+	return nil
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, body map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sc.AppKey != "" && body["appkey"] != s.sc.AppKey {
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"error_code":        "EGW00103",
+			"error_description": "유효하지 않은 AppKey입니다.",
+		})
+		return
+	}
+	s.tokenSeq++
+	token := fmt.Sprintf("fake-token-%d", s.tokenSeq)
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   86400,
+	})
+}
+
+func (s *Server) handlePrice(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sym := q.Get("SYMB")
+	last, ok := s.sc.Prices[sym]
+	if !ok {
+		if bars := s.sc.Daily[sym]; len(bars) > 0 {
+			last, ok = bars[0].Close, true
+		}
+	}
+	if !ok {
+		// KIS answers unknown symbols with empty fields
+		writeJSON(w, http.StatusOK, withOutput(envelope("0", "MCA00000", "정상처리 되었습니다."), "output", map[string]string{"last": "", "base": ""}))
+		return
+	}
+	base := last
+	if bars := s.sc.Daily[sym]; len(bars) > 1 {
+		base = bars[1].Close
+	}
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "MCA00000", "정상처리 되었습니다."), "output", map[string]string{
+		"last": fmtPrice(last),
+		"base": fmtPrice(base),
+	}))
+}
+
+// handleDailyPrice serves up to DailyPageSize rows on or before BYMD, newest
+// first. GUBN=1/2 aggregates the daily bars into weeks/months dated by their
+// last session; MODP is ignored since scenario bars carry no corporate actions.
+func (s *Server) handleDailyPrice(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bars := s.sc.Daily[q.Get("SYMB")]
+	switch q.Get("GUBN") {
+	case "1":
+		bars = aggregateBars(bars, func(d time.Time) string {
+			y, wk := d.ISOWeek()
+			return fmt.Sprintf("%d-%02d", y, wk)
+		})
+	case "2":
+		bars = aggregateBars(bars, func(d time.Time) string { return d.Format("200601") })
+	}
+
+	bymd := q.Get("BYMD")
+	rows := []map[string]string{}
+	for _, b := range bars {
+		if bymd != "" && b.Date > bymd {
+			continue
+		}
+		rows = append(rows, map[string]string{
+			"xymd": b.Date,
+			"clos": fmtPrice(b.Close),
+			"open": fmtPrice(b.Open),
+			"high": fmtPrice(b.High),
+			"low":  fmtPrice(b.Low),
+			"tvol": strconv.FormatInt(b.Volume, 10),
+			"tamt": fmtPrice(b.Close * float64(b.Volume)),
+		})
+		if len(rows) >= s.sc.DailyPageSize {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "MCA00000", "정상처리 되었습니다."), "output2", rows))
+}
+
+// aggregateBars merges newest-first daily bars that share a period key
+func aggregateBars(daily []Bar, key func(time.Time) string) []Bar {
+	var out []Bar
+	lastKey := ""
+	for _, b := range daily {
+		d, err := time.Parse("20060102", b.Date)
+		if err != nil {
+			continue
+		}
+		k := key(d)
+		if n := len(out); n > 0 && k == lastKey {
+			// Walking back in time: this bar opens the period
+			agg := &out[n-1]
+			agg.Open = b.Open
+			agg.High = math.Max(agg.High, b.High)
+			agg.Low = math.Min(agg.Low, b.Low)
+			agg.Volume += b.Volume
+			continue
+		}
+		lastKey = k
+		out = append(out, b)
+	}
+	return out
+}
+
+// handleBalance pages the holdings of one exchange (all of them for NASD with
+// BalanceAllOnNASD) via CTX_AREA_NK200 (row offset)
+func (s *Server) handleBalance(w http.ResponseWriter, r *http.Request, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(q.Get("CANO")); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	nk := q.Get("CTX_AREA_NK200")
+	if nk != "" && r.Header.Get("tr_cont") != "N" {
+		writeJSON(w, http.StatusOK, envelope("1", codeTrCont, "연속조회 시 tr_cont=N 헤더가 필요합니다."))
+		return
+	}
+	offset, _ := strconv.Atoi(strings.TrimSpace(nk))
+
+	exch := q.Get("OVRS_EXCG_CD")
+	var all []*Holding
+	for _, h := range s.holdings {
+		if h.Qty > 0 && (exch == "" || h.Exchange == exch || exch == "NASD" && s.sc.BalanceAllOnNASD) {
+			all = append(all, h)
+		}
+	}
+
+	rows := []map[string]string{}
+	var purchase, eval float64
+	for _, h := range all {
+		price := s.lastPrice(h.Symbol, h.AvgPrice)
+		purchase += float64(h.Qty) * h.AvgPrice
+		eval += float64(h.Qty) * price
+	}
+	end := offset + s.sc.BalancePageSize
+	if end > len(all) {
+		end = len(all)
+	}
+	for _, h := range all[min(offset, len(all)):end] {
+		price := s.lastPrice(h.Symbol, h.AvgPrice)
+		rows = append(rows, map[string]string{
+			"ovrs_excg_cd":       h.Exchange,
+			"ovrs_pdno":          h.Symbol,
+			"ovrs_cblc_qty":      strconv.Itoa(h.Qty),
+			"pchs_avg_pric":      fmtPrice(h.AvgPrice),
+			"now_pric2":          fmtPrice(price),
+			"frcr_pchs_amt1":     fmtPrice(float64(h.Qty) * h.AvgPrice),
+			"ovrs_stck_evlu_amt": fmtPrice(float64(h.Qty) * price),
+			"frcr_evlu_pfls_amt": fmtPrice(float64(h.Qty) * (price - h.AvgPrice)),
+		})
+	}
+
+	rate := 0.0
+	if purchase > 0 {
+		rate = (eval - purchase) / purchase * 100
+	}
+	resp := withOutput(envelope("0", "KIOK0510", "조회가 완료되었습니다"), "output1", rows)
+	resp["output2"] = map[string]string{
+		"tot_evlu_pfls_amt":  fmtPrice(eval),
+		"frcr_pchs_amt1":     fmtPrice(purchase),
+		"ovrs_tot_pfls":      fmtPrice(eval - purchase),
+		"tot_pftrt":          fmtPrice(rate),
+		"ovrs_rlzt_pfls_amt": fmtPrice(s.realized),
+	}
+
+	trCont := "D"
+	resp["ctx_area_fk200"] = q.Get("CTX_AREA_FK200")
+	resp["ctx_area_nk200"] = ""
+	if end < len(all) {
+		trCont = "M"
+		resp["ctx_area_nk200"] = strconv.Itoa(end)
+	}
+	w.Header().Set("tr_cont", trCont)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleBuyingPower(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(q.Get("CANO")); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	avail := s.available()
+	out := map[string]string{
+		"ovrs_ord_psbl_amt": fmtPrice(avail),
+		"ord_psbl_frcr_amt": fmtPrice(avail),
+		"frcr_ord_psbl_amt": fmtPrice(avail),
+		"max_buy_amt":       fmtPrice(avail),
+	}
+	// OVRS_ORD_UNPR 0 means the current price of ITEM_CD
+	price, _ := strconv.ParseFloat(q.Get("OVRS_ORD_UNPR"), 64)
+	price = s.orderPrice(q.Get("ITEM_CD"), price)
+	if price > 0 && avail > 0 {
+		qty := strconv.Itoa(int(avail/price + 1e-9))
+		out["max_ord_psbl_qty"] = qty
+		out["ord_psbl_qty"] = qty
+		out["ovrs_max_ord_psbl_qty"] = qty
+	}
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output", out))
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, body map[string]string, sell bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(body["CANO"]); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	qty, _ := strconv.Atoi(body["ORD_QTY"])
+	price, _ := strconv.ParseFloat(body["OVRS_ORD_UNPR"], 64)
+	sym := body["PDNO"]
+
+	if s.sc.MarketClosed {
+		writeJSON(w, http.StatusOK, envelope("1", codeMarketClosed, "장운영시간이 아닙니다."))
+		return
+	}
+	if msg := s.checkOrder(sym, sell, qty, price, body["ORD_DVSN"], ordDvsnAllowed); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+
+	o := s.book(&Order{
+		Exchange: body["OVRS_EXCG_CD"],
+		Symbol:   sym,
+		Sell:     sell,
+		Qty:      qty,
+		Price:    price,
+		OrdType:  body["ORD_DVSN"],
+	})
+	if s.sc.AutoFill {
+		s.autoFill(o)
+	}
+	writeJSON(w, http.StatusOK, s.orderAccepted(o))
+}
+
+// checkOrder validates an order against allowed divisions, cash and
+// holdings; nil means OK. Caller holds mu.
+func (s *Server) checkOrder(sym string, sell bool, qty int, price float64, dvsn string, allowed map[bool]map[string]bool) map[string]interface{} {
+	if msg := checkOrderFields(sell, qty, price, dvsn, allowed); msg != nil {
+		return msg
+	}
+	switch {
+	case !sell && float64(qty)*s.orderPrice(sym, price) > s.available()+1e-9:
+		return envelope("1", codeFunds, "주문가능금액을 초과 했습니다.")
+	case sell && qty > s.sellable(sym):
+		return envelope("1", codeSellable, "매도가능수량을 초과 했습니다.")
+	}
+	return nil
+}
+
+// checkOrderFields validates quantity, order division and price
+func checkOrderFields(sell bool, qty int, price float64, dvsn string, allowed map[bool]map[string]bool) map[string]interface{} {
+	switch {
+	case qty <= 0:
+		return envelope("1", codeBadQty, "주문수량을 확인하여 주십시오.")
+	case !allowed[sell][dvsn]:
+		return envelope("1", codeBadOrdDvsn, "주문구분을 확인하여 주십시오.")
+	case price <= 0 && dvsn != "31" && dvsn != "33":
+		return envelope("1", codeBadPrice, "주문단가를 확인하여 주십시오.")
+	}
+	return nil
+}
+
+func (s *Server) handleReviseCancel(w http.ResponseWriter, body map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg := s.checkAccount(body["CANO"]); msg != nil {
+		writeJSON(w, http.StatusOK, msg)
+		return
+	}
+	if s.sc.MarketClosed {
+		writeJSON(w, http.StatusOK, envelope("1", codeMarketClosed, "장운영시간이 아닙니다."))
+		return
+	}
+
+	orig := s.findOrder(body["ORGN_ODNO"])
+	if orig == nil || !orig.Open() {
+		writeJSON(w, http.StatusOK, envelope("1", codeNoOrder, "정정/취소 가능한 주문이 없습니다."))
+		return
+	}
+
+	remaining := orig.Qty - orig.FilledQty
+	qty, _ := strconv.Atoi(body["ORD_QTY"])
+	orig.Closed = true
+
+	if body["RVSE_CNCL_DVSN_CD"] == "02" {
+		row := s.book(&Order{
+			OrigOrderNo:  orig.OrderNo,
+			ReviseCancel: "02",
+			Exchange:     orig.Exchange,
+			Symbol:       orig.Symbol,
+			Sell:         orig.Sell,
+			Qty:          remaining,
+			Price:        orig.Price,
+			OrdType:      orig.OrdType,
+			Closed:       true,
+		})
+		writeJSON(w, http.StatusOK, s.orderAccepted(row))
+		return
+	}
+
+	price, _ := strconv.ParseFloat(body["OVRS_ORD_UNPR"], 64)
+	if qty <= 0 {
+		qty = remaining
+	}
+	row := s.book(&Order{
+		OrigOrderNo:  orig.OrderNo,
+		ReviseCancel: "01",
+		Exchange:     orig.Exchange,
+		Symbol:       orig.Symbol,
+		Sell:         orig.Sell,
+		Qty:          qty,
+		Price:        price,
+		OrdType:      orig.OrdType,
+	})
+	if s.sc.AutoFill {
+		s.autoFill(row)
+	}
+	writeJSON(w, http.StatusOK, s.orderAccepted(row))
+}
+
+// handleOrderHistory serves inquire-ccnl rows, newest first
+func (s *Server) handleOrderHistory(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, end, odno := q.Get("ORD_STRT_DT"), q.Get("ORD_END_DT"), q.Get("ODNO")
+	rows := []map[string]string{}
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		o := s.orders[i]
+		if odno != "" && o.OrderNo != odno {
+			continue
+		}
+		if (start != "" && o.Date < start) || (end != "" && o.Date > end) {
+			continue
+		}
+		rows = append(rows, s.historyRow(o))
+	}
+	w.Header().Set("tr_cont", "D")
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output", rows))
+}
+
+func (s *Server) handleUnfilled(w http.ResponseWriter, q url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exch := q.Get("OVRS_EXCG_CD")
+	rows := []map[string]string{}
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		o := s.orders[i]
+		if !o.Open() || (exch != "" && o.Exchange != exch) {
+			continue
+		}
+		row := s.historyRow(o)
+		delete(row, "prcs_stat_name")
+		delete(row, "rjct_rson")
+		rows = append(rows, row)
+	}
+	w.Header().Set("tr_cont", "D")
+	writeJSON(w, http.StatusOK, withOutput(envelope("0", "KIOK0460", "조회 되었습니다."), "output", rows))
+}
+
+func (s *Server) historyRow(o *Order) map[string]string {
+	side := "02"
+	if o.Sell {
+		side = "01"
+	}
+	status := "접수"
+	switch {
+	case o.RejectReason != "":
+		status = "거부"
+	case !o.Open():
+		status = "완료"
+	}
+	unfilled := 0
+	if o.Open() {
+		unfilled = o.Qty - o.FilledQty
+	}
+	avg := 0.0
+	if o.FilledQty > 0 {
+		avg = o.FilledAmt / float64(o.FilledQty)
+	}
+	return map[string]string{
+		"ord_dt":          o.Date,
+		"ord_tmd":         o.Time,
+		"odno":            o.OrderNo,
+		"orgn_odno":       o.OrigOrderNo,
+		"sll_buy_dvsn_cd": side,
+		"rvse_cncl_dvsn":  o.ReviseCancel,
+		"pdno":            o.Symbol,
+		"ft_ord_qty":      strconv.Itoa(o.Qty),
+		"ft_ord_unpr3":    fmtPrice(o.Price),
+		"ft_ccld_qty":     strconv.Itoa(o.FilledQty),
+		"ft_ccld_unpr3":   fmtPrice(avg),
+		"ft_ccld_amt3":    fmtPrice(o.FilledAmt),
+		"nccs_qty":        strconv.Itoa(unfilled),
+		"prcs_stat_name":  status,
+		"rjct_rson":       o.RejectReason,
+		"ovrs_excg_cd":    o.Exchange,
+	}
+}
+
+// book assigns an order number and timestamps. Caller holds mu.
+func (s *Server) book(o *Order) *Order {
+	now := s.Now().In(s.loc)
+	s.orderSeq++
+	o.OrderNo = fmt.Sprintf("%010d", s.orderSeq)
+	o.Date = now.Format("20060102")
+	o.Time = now.Format("150405")
+	s.orders = append(s.orders, o)
+	log.Printf("[KISFAKE] Booked order %s: sell=%v %d %s @ %.2f", o.OrderNo, o.Sell, o.Qty, o.Symbol, o.Price)
+	return o
+}
+
+func (s *Server) orderAccepted(o *Order) map[string]interface{} {
+	return withOutput(envelope("0", "APBK0013", "주문 전송 완료 되었습니다."), "output", map[string]string{
+		"KRX_FWDG_ORD_ORGNO": "01790",
+		"ODNO":               o.OrderNo,
+		"ORD_TMD":            o.Time,
+	})
+}
+
+func (s *Server) findOrder(orderNo string) *Order {
+	for _, o := range s.orders {
+		if o.OrderNo == orderNo {
+			return o
+		}
+	}
+	return nil
+}
+
+// autoFill fills a limit order at the last price when marketable. Auction
+// orders wait for Auction. Caller holds mu.
+func (s *Server) autoFill(o *Order) {
+	last, ok := s.sc.Prices[o.Symbol]
+	if !ok || last <= 0 || o.OrdType != "00" {
+		return
+	}
+	if (!o.Sell && o.Price >= last) || (o.Sell && o.Price <= last) {
+		s.fill(o, o.Qty-o.FilledQty, last)
+	}
+}
+
+// Auction runs the opening (open=true) or closing auction of symbol at price:
+// open MOO/LOO or MOC/LOC orders fill when their limit allows, the rest expire.
+// It returns how many orders filled.
+func (s *Server) Auction(symbol string, price float64, open bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kinds := closeAuctionDvsn
+	if open {
+		kinds = openAuctionDvsn
+	}
+	filled := 0
+	for _, o := range s.orders {
+		if o.Symbol != symbol || !o.Open() || !kinds[o.OrdType] {
+			continue
+		}
+		market := o.OrdType == "31" || o.OrdType == "33"
+		if market || (!o.Sell && price <= o.Price) || (o.Sell && price >= o.Price) {
+			s.fill(o, o.Qty-o.FilledQty, price)
+			filled++
+			continue
+		}
+		o.Closed = true
+	}
+	if s.sc.Prices == nil {
+		s.sc.Prices = make(map[string]float64)
+	}
+	s.sc.Prices[symbol] = price
+	return filled
+}
+
+// fill books qty shares of o at price against cash and holdings. Caller holds mu.
+func (s *Server) fill(o *Order, qty int, price float64) {
+	if qty <= 0 {
+		return
+	}
+	o.FilledQty += qty
+	o.FilledAmt += float64(qty) * price
+
+	h := s.holding(o.Symbol, o.Exchange)
+	s.execs = append(s.execs, Execution{
+		Date:        s.Now().In(s.loc).Format("20060102"),
+		OrderNo:     o.OrderNo,
+		Exchange:    h.Exchange,
+		Symbol:      o.Symbol,
+		Sell:        o.Sell,
+		Qty:         qty,
+		Price:       price,
+		AvgBuyPrice: h.AvgPrice,
+	})
+	if o.Sell {
+		s.cash += float64(qty) * price
+		s.realized += (price - h.AvgPrice) * float64(qty)
+		h.Qty -= qty
+		if h.Qty <= 0 {
+			h.Qty, h.AvgPrice = 0, 0
+		}
+	} else {
+		s.cash -= float64(qty) * price
+		total := h.AvgPrice*float64(h.Qty) + price*float64(qty)
+		h.Qty += qty
+		h.AvgPrice = total / float64(h.Qty)
+	}
+	log.Printf("[KISFAKE] Filled order %s: %d %s @ %.2f", o.OrderNo, qty, o.Symbol, price)
+}
+
+func (s *Server) holding(symbol, exch string) *Holding {
+	for _, h := range s.holdings {
+		if h.Symbol == symbol {
+			return h
+		}
+	}
+	if exch == "" {
+		exch = "NASD"
+	}
+	h := &Holding{Symbol: symbol, Exchange: exch}
+	s.holdings = append(s.holdings, h)
+	return h
+}
+
+// available is cash minus what open buy orders reserve. Caller holds mu.
+func (s *Server) available() float64 {
+	reserved := 0.0
+	for _, o := range s.orders {
+		if o.Open() && !o.Sell {
+			reserved += float64(o.Qty-o.FilledQty) * s.orderPrice(o.Symbol, o.Price)
+		}
+	}
+	return s.cash - reserved
+}
+
+// sellable is the held quantity not committed to open sells. Caller holds mu.
+func (s *Server) sellable(symbol string) int {
+	qty := 0
+	for _, h := range s.holdings {
+		if h.Symbol == symbol {
+			qty = h.Qty
+		}
+	}
+	for _, o := range s.orders {
+		if o.Open() && o.Sell && o.Symbol == symbol {
+			qty -= o.Qty - o.FilledQty
+		}
+	}
+	return qty
+}
+
+// orderPrice is the limit price, or the last price for market orders
+func (s *Server) orderPrice(symbol string, limit float64) float64 {
+	if limit > 0 {
+		return limit
+	}
+	return s.sc.Prices[symbol]
+}
+
+func (s *Server) lastPrice(symbol string, fallback float64) float64 {
+	if p, ok := s.sc.Prices[symbol]; ok {
+		return p
+	}
+	if bars := s.sc.Daily[symbol]; len(bars) > 0 {
+		return bars[0].Close
+	}
+	return fallback
+}
+
+// SortDaily orders bars newest first, as the daily price API returns them
+func SortDaily(bars []Bar) {
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date > bars[j].Date })
+}
+
+func envelope(rtCd, msgCd, msg1 string) map[string]interface{} {
+	return map[string]interface{}{"rt_cd": rtCd, "msg_cd": msgCd, "msg1": msg1}
+}
+
+func withOutput(resp map[string]interface{}, key string, output interface{}) map[string]interface{} {
+	resp[key] = output
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func fmtPrice(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
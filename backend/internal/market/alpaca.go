@@ -2,6 +2,7 @@ package market
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
@@ -22,16 +23,22 @@ type Candle struct {
 	Volume    uint64  `parquet:"volume"`
 }
 
-func NewAlpacaClient(cfg *config.Config) *AlpacaClient {
+// NewAlpacaClient returns nil without API keys. transport (e.g. a cassette
+// recorder) replaces the default HTTP transport when non-nil.
+func NewAlpacaClient(cfg *config.Config, transport http.RoundTripper) *AlpacaClient {
 	if cfg.AlpacaApiKey == "" || cfg.AlpacaSecret == "" {
 		return nil
 	}
 
-	client := marketdata.NewClient(marketdata.ClientOpts{
+	opts := marketdata.ClientOpts{
 		APIKey:    cfg.AlpacaApiKey,
 		APISecret: cfg.AlpacaSecret,
 		Feed:      marketdata.IEX, // Free Tier
-	})
+	}
+	if transport != nil {
+		opts.HTTPClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+	client := marketdata.NewClient(opts)
 
 	return &AlpacaClient{Client: client}
 }
@@ -8,31 +8,161 @@ import (
 
 type UserSettings struct {
 	gorm.Model
-	Principal  float64 // Total Investment (e.g. 10000)
-	SplitCount int     // Default 40
-	TargetRate float64 // Default 0.10 (10%)
-	Symbols    string  // Comma separated, e.g., "TQQQ,SOXL"
-	IsActive   bool    // Logic On/Off
+	Principal         float64 // Total Investment (e.g. 10000)
+	PrincipalCurrency string  `gorm:"default:USD"` // USD or KRW, converted at the day's rate when trading
+	SplitCount        int     // Default 40
+	TargetRate        float64 // Default 0.10 (10%)
+	LadderRate        float64 `gorm:"default:0.05"`    // X: upper LOC buy and 1/4 LOC sell at avg × (1 + X)
+	ExhaustPolicy     string  `gorm:"default:quarter"` // quarter or reverse, once the splits are spent
+	Symbols           string  // Comma separated, e.g., "TQQQ,SOXL"
+	IsActive          bool    // Logic On/Off
+}
+
+// CycleConfig overrides the infinite-buy settings of one symbol of
+// UserSettings.Symbols; zero values fall back to UserSettings
+type CycleConfig struct {
+	gorm.Model
+	Symbol     string  `gorm:"uniqueIndex"`
+	Exchange   string  // NASDAQ, NYSE, AMEX; empty = symbol master
+	Allocation float64 // Share of Principal (0.5 = half), 0 = an equal share of what the others leave
+	SplitCount int
+	TargetRate float64
+	LadderRate float64
+}
+
+// StrategyConfig is the per-strategy configuration of a registered strategy plug-in
+type StrategyConfig struct {
+	gorm.Model
+	Name      string `gorm:"uniqueIndex"` // Plug-in name: rebalance, infinite
+	Enabled   bool   // Run by the scheduler
+	Schedule  string // Cron spec (ET), empty = the plug-in default
+	Placement string // live or reserve, empty = ORDER_PLACEMENT
+	Params    string // Plug-in specific JSON
 }
 
 type TradeLog struct {
 	gorm.Model
-	Date   time.Time
-	Symbol string
-	Side   string // BUY, SELL
-	Type   string // LIMIT, LOC, MARKET
-	Qty    int
-	Price  float64
-	Amount float64
-	Profit float64 // Only for SELL
+	Date    time.Time
+	Symbol  string
+	Side    string // BUY, SELL
+	Type    string // LIMIT, LOC, MARKET
+	Qty     int
+	Price   float64
+	Amount  float64
+	Profit  float64 // Only for SELL
+	OrderID uint    `gorm:"index"` // Order this fill belongs to
+}
+
+// RealizedProfit is the broker's realized P&L of a symbol on a trade date,
+// imported from its period profit report (USD)
+type RealizedProfit struct {
+	gorm.Model
+	Broker       string `gorm:"index:idx_realized_profit_key"`
+	TradeDate    string `gorm:"index:idx_realized_profit_key"` // YYYY-MM-DD (US trade date)
+	Symbol       string `gorm:"index:idx_realized_profit_key"`
+	Exchange     string
+	Qty          int // Shares sold
+	AvgBuyPrice  float64
+	AvgSellPrice float64
+	BuyAmount    float64
+	SellAmount   float64
+	Fee          float64
+	Profit       float64 // After fees
+	ExchangeRate float64 // KRW per USD applied by the broker
+}
+
+// BrokerTrade is one execution imported from the broker's trade history
+type BrokerTrade struct {
+	gorm.Model
+	Broker     string `gorm:"index:idx_broker_trade_key"`
+	TradeDate  string `gorm:"index:idx_broker_trade_key"` // YYYY-MM-DD (US trade date)
+	SettleDate string
+	Symbol     string `gorm:"index:idx_broker_trade_key"`
+	Side       string // BUY, SELL
+	Qty        int
+	Price      float64
+	Amount     float64
+	Fee        float64
+}
+
+// FXRate is the USD/KRW rate of one day, as posted by the broker
+type FXRate struct {
+	gorm.Model
+	Date   string  `gorm:"uniqueIndex"` // YYYY-MM-DD (KST)
+	Rate   float64 // KRW per USD
+	Source string  // Broker name, or "config" for the FX_RATE fallback
+}
+
+// Order tracks a broker order from submission to its final state
+type Order struct {
+	gorm.Model
+	BrokerOrderID string `gorm:"index"` // KIS ODNO / paper ID (empty while RESERVED)
+	ReservationID string `gorm:"index"` // Broker reservation number for reservation orders
+	Broker        string // kis, paper
+	Source        string // DAILY, REBALANCE, MANUAL
+	Exchange      string
+	Symbol        string `gorm:"index"`
+	Side          string // BUY, SELL
+	Type          string // LIMIT, MARKET
+	Qty           int
+	Price         float64
+	Status        string `gorm:"index"` // RESERVED, SUBMITTED, PARTIALLY_FILLED, FILLED, CANCELLED, REJECTED
+	FilledQty     int
+	AvgFillPrice  float64
+	CostBasis     float64 // SELL: average cost per share when placed; fills book profit against it
+	Message       string  // Rejection reason / last error
+	SubmittedAt   time.Time
+	LastCheckedAt *time.Time
+	ClosedAt      *time.Time // When the order reached a final state
 }
 
 // Daily status ensuring we track the 40-day cycle
 type CycleStatus struct {
 	gorm.Model
 	Symbol          string `gorm:"uniqueIndex"`
-	CurrentCycleDay int    // 1 to 40
+	Exchange        string
+	StartedAt       *time.Time // First ladder buy (or first sync) of the cycle, nil while flat
+	CurrentCycleDay int        // Sessions with a ladder buy, 1 to 40
 	TotalBoughtQty  int
 	AvgPrice        float64
 	TotalInvested   float64
+	UnitsUsed       float64 // T: TotalInvested / (Principal / SplitCount)
+	Phase           string  // FIRST_HALF, SECOND_HALF, EXHAUSTED (empty until the first ladder)
+	QuarterStops    int     // Quarter stops of the current cycle
+	// Exhausted cycles
+	Mode         string     // Empty, or REVERSE while reverse mode recovers the cycle
+	ExhaustedAt  *time.Time // When the splits ran out, nil once the cycle recovers
+	ReverseDays  int        // Reverse mode sessions with a portion sold
+	ReversePrice float64    // Quote at the last reverse sell; the next rebuy is X below it
+}
+
+// Cycle is a finished infinite-buy cycle, recorded when its position is gone
+type Cycle struct {
+	gorm.Model
+	Symbol         string `gorm:"index"`
+	StartDate      time.Time
+	EndDate        time.Time
+	Days           int     // Sessions with a ladder buy
+	SplitCount     int     // Of the symbol when the cycle ended
+	UnitsUsed      float64 // T at the last ladder
+	TotalInvested  float64 // Buy fills of the cycle (USD)
+	TotalSold      float64 // Sell fills of the cycle (USD)
+	RealizedProfit float64
+	ReturnRate     float64 // RealizedProfit / TotalInvested
+	QuarterStops   int
+	EndReason      string // TARGET, QUARTER_STOP, REVERSE, MANUAL
+}
+
+// CycleEvent is a state change of an infinite-buy cycle
+type CycleEvent struct {
+	gorm.Model
+	Date     time.Time
+	Symbol   string `gorm:"index"`
+	Event    string // EXHAUSTED, QUARTER_STOP, REVERSE_START, REVERSE_SELL, REVERSE_END
+	Policy   string // quarter, reverse
+	T        float64
+	Qty      int // Shares sold by the event's order
+	Price    float64
+	AvgPrice float64
+	Note     string
 }
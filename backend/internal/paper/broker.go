@@ -0,0 +1,695 @@
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
+)
+
+// CandleSource is where the paper broker reads its 1-minute candles from;
+// *market.MarketRepository in production
+type CandleSource interface {
+	QueryCandles(symbol string, start, end time.Time) ([]market.Candle, error)
+}
+
+// Broker is a simulated broker.Broker that keeps its own ledger and fills
+// orders against the 1-minute candles stored under data/market_data.
+//
+// Fills are evaluated lazily: every call that reads account state first scans
+// the candles recorded since each open order was submitted. Orders are DAY
+// orders and expire once the candles for their session are on disk without a fill.
+type Broker struct {
+	Repo       CandleSource
+	LedgerPath string
+	// Now is the simulation clock, defaults to time.Now. Order timestamps,
+	// session windows and settlement all read it, so a fixed clock replays
+	// the same fills.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	loc    *time.Location
+	ledger ledger
+}
+
+type position struct {
+	Exchange broker.Exchange `json:"exchange"`
+	Qty      int             `json:"qty"`
+	AvgPrice float64         `json:"avg_price"`
+}
+
+type ledger struct {
+	Cash       float64                  `json:"cash"`
+	RealizedPL float64                  `json:"realized_pl"`
+	Positions  map[string]*position     `json:"positions"`
+	Orders     map[string]*broker.Order `json:"orders"`
+	NextID     int                      `json:"next_id"`
+}
+
+// Regular session in ET. Candles outside it never fill orders.
+const (
+	sessionOpenMin  = 9*60 + 30
+	sessionCloseMin = 16 * 60
+)
+
+// NewBroker loads the ledger from ledgerPath, or starts a new one with initialCash
+func NewBroker(repo CandleSource, ledgerPath string, initialCash float64) (*Broker, error) {
+	if repo == nil {
+		return nil, errors.New("paper broker requires market data repository")
+	}
+	if r, ok := repo.(*market.MarketRepository); ok && r == nil {
+		return nil, errors.New("paper broker requires market data repository")
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broker{
+		Repo:       repo,
+		LedgerPath: ledgerPath,
+		Now:        time.Now,
+		loc:        loc,
+		ledger: ledger{
+			Cash:      initialCash,
+			Positions: make(map[string]*position),
+			Orders:    make(map[string]*broker.Order),
+			NextID:    1,
+		},
+	}
+
+	if err := b.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load paper ledger: %v", err)
+	}
+	logPaper("Initialized (Cash: $%.2f, Positions: %d, Orders: %d, Ledger: %s)",
+		b.ledger.Cash, len(b.ledger.Positions), len(b.ledger.Orders), ledgerPath)
+	return b, nil
+}
+
+func logPaper(format string, v ...interface{}) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	log.Printf("[%s][PAPER] "+format, append([]interface{}{timestamp}, v...)...)
+}
+
+func (b *Broker) load() error {
+	if b.LedgerPath == "" {
+		return os.ErrNotExist
+	}
+	file, err := os.Open(b.LedgerPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var l ledger
+	if err := json.NewDecoder(file).Decode(&l); err != nil {
+		return err
+	}
+	if l.Positions == nil {
+		l.Positions = make(map[string]*position)
+	}
+	if l.Orders == nil {
+		l.Orders = make(map[string]*broker.Order)
+	}
+	b.ledger = l
+	return nil
+}
+
+// save persists the ledger. It assumes the caller holds the lock.
+// The ledger is written to a temp file next to it and renamed into place,
+// so a crash mid-write leaves the previous ledger intact.
+func (b *Broker) save() {
+	if b.LedgerPath == "" {
+		return
+	}
+	if err := b.writeLedger(); err != nil {
+		logPaper("Warning: Failed to save ledger: %v", err)
+	}
+}
+
+func (b *Broker) writeLedger() error {
+	file, err := os.CreateTemp(filepath.Dir(b.LedgerPath), filepath.Base(b.LedgerPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	defer os.Remove(tmp) // No-op once renamed
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b.ledger); err != nil {
+		file.Close()
+		return fmt.Errorf("encode: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.LedgerPath)
+}
+
+func (b *Broker) Name() string {
+	return "paper"
+}
+
+func (b *Broker) Mode() string {
+	return "paper"
+}
+
+func (b *Broker) GetBalance(ctx context.Context) (*broker.Balance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	bal := &broker.Balance{RealizedPL: b.ledger.RealizedPL}
+	for sym, p := range b.ledger.Positions {
+		if p.Qty == 0 {
+			continue
+		}
+		price, err := b.lastPrice(sym)
+		if err != nil {
+			price = p.AvgPrice
+		}
+		bal.Positions = append(bal.Positions, broker.Position{
+			Symbol:       sym,
+			Exchange:     p.Exchange,
+			Qty:          p.Qty,
+			AvgPrice:     p.AvgPrice,
+			CurrentPrice: price,
+		})
+		bal.TotalPurchase += float64(p.Qty) * p.AvgPrice
+		bal.TotalEvaluation += float64(p.Qty) * price
+	}
+	sort.Slice(bal.Positions, func(i, j int) bool { return bal.Positions[i].Symbol < bal.Positions[j].Symbol })
+
+	bal.TotalPL = bal.TotalEvaluation - bal.TotalPurchase
+	if bal.TotalPurchase > 0 {
+		bal.TotalPLRate = bal.TotalPL / bal.TotalPurchase * 100
+	}
+	return bal, nil
+}
+
+// GetCash returns ledger cash minus what open BUY orders have reserved
+func (b *Broker) GetCash(ctx context.Context) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+	return b.ledger.Cash - b.reservedCash(), nil
+}
+
+// GetBuyingPower divides the available cash by price, or the last price when price is 0
+func (b *Broker) GetBuyingPower(ctx context.Context, exch broker.Exchange, symbol string, price float64) (*broker.BuyingPower, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	if price <= 0 {
+		last, err := b.lastPrice(symbol)
+		if err != nil {
+			return nil, err
+		}
+		price = last
+	}
+	cash := b.ledger.Cash - b.reservedCash()
+	bp := &broker.BuyingPower{Symbol: symbol, Price: price, Cash: cash}
+	if cash > 0 {
+		bp.MaxQty = int(math.Floor(cash/price + 1e-9))
+	}
+	return bp, nil
+}
+
+func (b *Broker) GetQuote(ctx context.Context, exch broker.Exchange, symbol string) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastPrice(symbol)
+}
+
+// GetHistory aggregates stored 1-minute session candles into OHLCV bars
+// (newest first). Weekly and monthly bars are dated by their last session.
+// Stored candles are unadjusted, so Adjusted is ignored.
+func (b *Broker) GetHistory(ctx context.Context, req broker.HistoryRequest) ([]broker.Bar, error) {
+	if req.Start == "" && req.Limit <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "history request needs a start date or a row limit")
+	}
+	switch req.Period {
+	case "", broker.PeriodDay, broker.PeriodWeek, broker.PeriodMonth:
+	default:
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported history period: %s", req.Period)
+	}
+
+	now := b.Now()
+	end := now
+	if req.End != "" {
+		t, err := time.ParseInLocation("20060102", req.End, b.loc)
+		if err != nil {
+			return nil, broker.NewError(broker.CategoryValidation, "invalid end date: %s", req.End)
+		}
+		end = t.AddDate(0, 0, 1)
+	}
+	var start time.Time
+	if req.Start != "" {
+		t, err := time.ParseInLocation("20060102", req.Start, b.loc)
+		if err != nil {
+			return nil, broker.NewError(broker.CategoryValidation, "invalid start date: %s", req.Start)
+		}
+		start = t
+	} else {
+		// ~252 sessions per 365 days, plus slack for holidays
+		days := req.Limit*3/2 + 10
+		switch req.Period {
+		case broker.PeriodWeek:
+			days = req.Limit*7 + 7
+		case broker.PeriodMonth:
+			days = req.Limit*31 + 31
+		}
+		start = end.AddDate(0, 0, -days)
+	}
+
+	candles, err := b.Repo.QueryCandles(req.Symbol, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []broker.Bar
+	lastKey := ""
+	for _, c := range candles {
+		t := time.UnixMilli(c.Timestamp).In(b.loc)
+		if !inSession(t) {
+			continue
+		}
+		key := periodKey(t, req.Period)
+		date := t.Format("20060102")
+		if n := len(bars); n > 0 && key == lastKey {
+			// candles are ascending: extend the current bar
+			bar := &bars[n-1]
+			bar.Date = date
+			bar.High = math.Max(bar.High, c.High)
+			bar.Low = math.Min(bar.Low, c.Low)
+			bar.Close = c.Close
+			bar.Volume += int64(c.Volume)
+			continue
+		}
+		lastKey = key
+		bars = append(bars, broker.Bar{
+			Date:   date,
+			Open:   c.Open,
+			High:   c.High,
+			Low:    c.Low,
+			Close:  c.Close,
+			Volume: int64(c.Volume),
+		})
+	}
+
+	// Newest first, like the KIS daily price API
+	for i, j := 0, len(bars)-1; i < j; i, j = i+1, j-1 {
+		bars[i], bars[j] = bars[j], bars[i]
+	}
+	if req.Limit > 0 && len(bars) > req.Limit {
+		bars = bars[:req.Limit]
+	}
+	return bars, nil
+}
+
+// periodKey groups session timestamps into days, ISO weeks or months
+func periodKey(t time.Time, period broker.Period) string {
+	switch period {
+	case broker.PeriodWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case broker.PeriodMonth:
+		return t.Format("200601")
+	default:
+		return t.Format("20060102")
+	}
+}
+
+func (b *Broker) PlaceOrder(ctx context.Context, req broker.OrderRequest) (*broker.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	if req.Qty <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "invalid order quantity: %d", req.Qty)
+	}
+	if !req.Type.Valid() {
+		return nil, broker.NewError(broker.CategoryValidation, "unsupported order type: %s", req.Type)
+	}
+	if req.Type.HasLimit() && req.Price <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "invalid limit price: %.2f", req.Price)
+	}
+	if err := broker.CheckOrderWindow(req.Type, req.Exchange, b.Now()); err != nil {
+		return nil, err
+	}
+
+	switch req.Side {
+	case broker.SideBuy:
+		cost := b.orderCost(req)
+		if avail := b.ledger.Cash - b.reservedCash(); cost > avail {
+			return nil, broker.NewError(broker.CategoryFunds, "insufficient cash: need $%.2f, available $%.2f", cost, avail)
+		}
+	case broker.SideSell:
+		held := 0
+		if p, ok := b.ledger.Positions[req.Symbol]; ok {
+			held = p.Qty
+		}
+		if avail := held - b.pendingSellQty(req.Symbol); req.Qty > avail {
+			return nil, broker.NewError(broker.CategoryFunds, "insufficient quantity: want %d, sellable %d", req.Qty, avail)
+		}
+	default:
+		return nil, broker.NewError(broker.CategoryValidation, "invalid side: %s", req.Side)
+	}
+
+	order := &broker.Order{
+		ID:           fmt.Sprintf("PAPER-%06d", b.ledger.NextID),
+		OrderRequest: req,
+		Status:       broker.OrderStatusSubmitted,
+		SubmittedAt:  b.Now(),
+	}
+	b.ledger.NextID++
+	b.ledger.Orders[order.ID] = order
+	b.save()
+
+	logPaper("✓ Order %s accepted: %s %d %s @ $%.2f (%s)",
+		order.ID, req.Side, req.Qty, req.Symbol, req.Price, req.Type)
+
+	copied := *order
+	return &copied, nil
+}
+
+func (b *Broker) GetOrderStatus(ctx context.Context, orderID string) (*broker.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	order, ok := b.ledger.Orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order not found: %s", orderID)
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (b *Broker) CancelOrder(ctx context.Context, o broker.Order) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	order, ok := b.ledger.Orders[o.ID]
+	if !ok {
+		return fmt.Errorf("order not found: %s", o.ID)
+	}
+	if !isOpen(order.Status) {
+		return fmt.Errorf("order %s is not open (%s)", o.ID, order.Status)
+	}
+	order.Status = broker.OrderStatusCancelled
+	b.save()
+
+	logPaper("✓ Order %s cancelled (%d/%d filled)", order.ID, order.FilledQty, order.Qty)
+	return nil
+}
+
+// AmendOrder revises the order in place, keeping its ID
+func (b *Broker) AmendOrder(ctx context.Context, o broker.Order, qty int, price float64) (*broker.Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settle()
+
+	order, ok := b.ledger.Orders[o.ID]
+	if !ok {
+		return nil, fmt.Errorf("order not found: %s", o.ID)
+	}
+	if !isOpen(order.Status) {
+		return nil, fmt.Errorf("order %s is not open (%s)", o.ID, order.Status)
+	}
+	if qty <= order.FilledQty {
+		return nil, broker.NewError(broker.CategoryValidation, "invalid quantity %d: %d already filled", qty, order.FilledQty)
+	}
+	if order.Type == broker.OrderTypeLimit && price <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "invalid limit price: %.2f", price)
+	}
+
+	revised := order.OrderRequest
+	revised.Qty = qty
+	revised.Price = price
+
+	switch order.Side {
+	case broker.SideBuy:
+		extra := b.orderCost(revised) - b.orderCost(order.OrderRequest)
+		if avail := b.ledger.Cash - b.reservedCash(); extra > avail {
+			return nil, broker.NewError(broker.CategoryFunds, "insufficient cash: need $%.2f more, available $%.2f", extra, avail)
+		}
+	case broker.SideSell:
+		held := 0
+		if p, ok := b.ledger.Positions[order.Symbol]; ok {
+			held = p.Qty
+		}
+		others := b.pendingSellQty(order.Symbol) - (order.Qty - order.FilledQty)
+		if avail := held - others; qty-order.FilledQty > avail {
+			return nil, broker.NewError(broker.CategoryFunds, "insufficient quantity: want %d, sellable %d", qty-order.FilledQty, avail)
+		}
+	}
+
+	order.OrderRequest = revised
+	b.save()
+
+	logPaper("✓ Order %s amended: %d @ $%.2f", order.ID, qty, price)
+	copied := *order
+	return &copied, nil
+}
+
+// orderCost is the cash a BUY order reserves while it is open
+func (b *Broker) orderCost(req broker.OrderRequest) float64 {
+	price := req.Price
+	if !req.Type.HasLimit() {
+		if last, err := b.lastPrice(req.Symbol); err == nil {
+			price = last
+		}
+	}
+	return float64(req.Qty) * price
+}
+
+func (b *Broker) reservedCash() float64 {
+	total := 0.0
+	for _, o := range b.ledger.Orders {
+		if o.Side == broker.SideBuy && isOpen(o.Status) {
+			remaining := o.OrderRequest
+			remaining.Qty = o.Qty - o.FilledQty
+			total += b.orderCost(remaining)
+		}
+	}
+	return total
+}
+
+func (b *Broker) pendingSellQty(symbol string) int {
+	qty := 0
+	for _, o := range b.ledger.Orders {
+		if o.Side == broker.SideSell && o.Symbol == symbol && isOpen(o.Status) {
+			qty += o.Qty - o.FilledQty
+		}
+	}
+	return qty
+}
+
+func isOpen(s broker.OrderStatus) bool {
+	return s == broker.OrderStatusSubmitted || s == broker.OrderStatusPartiallyFilled
+}
+
+func inSession(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	return m >= sessionOpenMin && m < sessionCloseMin
+}
+
+// sessionClose returns the close of the first regular session ending after t
+func (b *Broker) sessionClose(t time.Time) time.Time {
+	et := t.In(b.loc)
+	d := time.Date(et.Year(), et.Month(), et.Day(), 16, 0, 0, 0, b.loc)
+	if !et.Before(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// lastPrice returns the close of the latest stored candle at or before Now
+func (b *Broker) lastPrice(symbol string) (float64, error) {
+	now := b.Now()
+	candles, err := b.Repo.QueryCandles(symbol, now.AddDate(0, 0, -7), now)
+	if err != nil {
+		return 0, err
+	}
+	if len(candles) == 0 {
+		return 0, fmt.Errorf("no market data for %s", symbol)
+	}
+	return candles[len(candles)-1].Close, nil
+}
+
+// settle walks the candles recorded for every open order and applies fills.
+// It assumes the caller holds the lock
+func (b *Broker) settle() {
+	now := b.Now()
+	changed := false
+
+	for _, o := range b.ledger.Orders {
+		if !isOpen(o.Status) {
+			continue
+		}
+
+		closeAt := b.sessionClose(o.SubmittedAt)
+		end := now
+		if end.After(closeAt) {
+			end = closeAt
+		}
+		if !end.After(o.SubmittedAt) {
+			continue
+		}
+
+		candles, err := b.Repo.QueryCandles(o.Symbol, o.SubmittedAt, end)
+		if err != nil {
+			logPaper("⚠ Failed to load candles for %s: %v", o.Symbol, err)
+			continue
+		}
+
+		sawSessionEnd := false
+		var last *market.Candle
+		for i, c := range candles {
+			t := time.UnixMilli(c.Timestamp).In(b.loc)
+			if !inSession(t) {
+				continue
+			}
+			if !t.Before(closeAt.Add(-30 * time.Minute)) {
+				sawSessionEnd = true
+			}
+			last = &candles[i]
+
+			if o.Type.OnClose() {
+				continue // Decided by the last candle of the session
+			}
+			if o.Type.OnOpen() {
+				// The first session candle stands in for the opening auction
+				if price, ok := auctionPrice(o.OrderRequest, c.Open); ok {
+					b.fill(o, o.Qty-o.FilledQty, price, t)
+				} else {
+					o.Status = broker.OrderStatusCancelled
+					logPaper("Order %s missed the opening auction (%s %d %s @ $%.2f, open $%.2f)",
+						o.ID, o.Side, o.Qty, o.Symbol, o.Price, c.Open)
+				}
+				changed = true
+				break
+			}
+			if price, ok := fillPrice(o.OrderRequest, c); ok {
+				b.fill(o, o.Qty-o.FilledQty, price, t)
+				changed = true
+				break
+			}
+		}
+
+		// The last candle's close stands in for the closing auction
+		if o.Type.OnClose() && last != nil && now.After(closeAt) && sawSessionEnd {
+			t := time.UnixMilli(last.Timestamp).In(b.loc)
+			if price, ok := auctionPrice(o.OrderRequest, last.Close); ok {
+				b.fill(o, o.Qty-o.FilledQty, price, t)
+				changed = true
+			}
+		}
+
+		// Session data is on disk and the limit was never reached: DAY order expires
+		if isOpen(o.Status) && now.After(closeAt) && sawSessionEnd {
+			o.Status = broker.OrderStatusCancelled
+			changed = true
+			logPaper("Order %s expired unfilled (%s %d %s @ $%.2f)", o.ID, o.Side, o.Qty, o.Symbol, o.Price)
+		}
+	}
+
+	if changed {
+		b.save()
+	}
+}
+
+// fillPrice decides whether candle c trades through the order and at what price.
+// A limit that the candle gaps through fills at the open, otherwise at the limit.
+func fillPrice(req broker.OrderRequest, c market.Candle) (float64, bool) {
+	if req.Type == broker.OrderTypeMarket {
+		return c.Open, true
+	}
+	switch req.Side {
+	case broker.SideBuy:
+		if c.Low <= req.Price {
+			return math.Min(req.Price, c.Open), true
+		}
+	case broker.SideSell:
+		if c.High >= req.Price {
+			return math.Max(req.Price, c.Open), true
+		}
+	}
+	return 0, false
+}
+
+// auctionPrice decides whether an auction order trades at the auction price:
+// market types always do, limit types only at their limit or better
+func auctionPrice(req broker.OrderRequest, price float64) (float64, bool) {
+	if !req.Type.HasLimit() {
+		return price, true
+	}
+	if req.Side == broker.SideBuy && price <= req.Price {
+		return price, true
+	}
+	if req.Side == broker.SideSell && price >= req.Price {
+		return price, true
+	}
+	return 0, false
+}
+
+// fill books qty shares of o at price into the ledger
+func (b *Broker) fill(o *broker.Order, qty int, price float64, at time.Time) {
+	if qty <= 0 {
+		return
+	}
+	p, ok := b.ledger.Positions[o.Symbol]
+	if !ok {
+		p = &position{Exchange: o.Exchange}
+		b.ledger.Positions[o.Symbol] = p
+	}
+
+	amount := float64(qty) * price
+	if o.Side == broker.SideBuy {
+		p.AvgPrice = (p.AvgPrice*float64(p.Qty) + amount) / float64(p.Qty+qty)
+		p.Qty += qty
+		b.ledger.Cash -= amount
+	} else {
+		b.ledger.RealizedPL += (price - p.AvgPrice) * float64(qty)
+		p.Qty -= qty
+		b.ledger.Cash += amount
+		if p.Qty <= 0 {
+			delete(b.ledger.Positions, o.Symbol)
+		}
+	}
+
+	o.AvgFillPrice = (o.AvgFillPrice*float64(o.FilledQty) + amount) / float64(o.FilledQty+qty)
+	o.FilledQty += qty
+	if o.FilledQty >= o.Qty {
+		o.Status = broker.OrderStatusFilled
+	} else {
+		o.Status = broker.OrderStatusPartiallyFilled
+	}
+
+	logPaper("✓ Order %s filled: %s %d %s @ $%.2f at %s (Cash: $%.2f)",
+		o.ID, o.Side, qty, o.Symbol, price, at.Format("2006-01-02 15:04 MST"), b.ledger.Cash)
+}
@@ -0,0 +1,98 @@
+package paper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
+)
+
+// sessionCandles serves one regular session of TQQQ 1-minute candles that
+// open at 50 and close at 48 on the last minute
+type sessionCandles struct {
+	day time.Time
+}
+
+func (s sessionCandles) QueryCandles(symbol string, start, end time.Time) ([]market.Candle, error) {
+	var out []market.Candle
+	for m := sessionOpenMin; m < sessionCloseMin; m++ {
+		t := s.day.Add(time.Duration(m) * time.Minute)
+		if t.Before(start) || t.After(end) {
+			continue
+		}
+		price := 50.0
+		if m == sessionCloseMin-1 {
+			price = 48
+		}
+		out = append(out, market.Candle{
+			Symbol: symbol, Timestamp: t.UnixMilli(),
+			Open: price, High: price + 0.5, Low: price - 0.5, Close: price, Volume: 100,
+		})
+	}
+	return out, nil
+}
+
+func TestLOCSettlesAtCloseWithFixedClock(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zone database:", err)
+	}
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, loc) // Monday
+	now := day.Add(15 * time.Hour)
+	ledgerPath := filepath.Join(t.TempDir(), "ledger.json")
+
+	b, err := NewBroker(sessionCandles{day}, ledgerPath, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Now = func() time.Time { return now }
+
+	ctx := context.Background()
+	order, err := b.PlaceOrder(ctx, broker.OrderRequest{
+		Symbol: "TQQQ", Exchange: broker.ExchangeNASDAQ, Side: broker.SideBuy,
+		Type: broker.OrderTypeLOC, Qty: 10, Price: 49,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.SubmittedAt.Equal(now) {
+		t.Errorf("SubmittedAt = %v, want the clock %v", order.SubmittedAt, now)
+	}
+
+	// Before the close the LOC waits for the auction
+	if got, _ := b.GetOrderStatus(ctx, order.ID); got.Status != broker.OrderStatusSubmitted {
+		t.Fatalf("status before the close = %s", got.Status)
+	}
+
+	now = day.Add(16*time.Hour + 5*time.Minute)
+	bal, err := b.GetBalance(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bal.Positions) != 1 || bal.Positions[0].Qty != 10 || bal.Positions[0].AvgPrice != 48 {
+		t.Fatalf("positions after the close = %+v, want 10 @ 48", bal.Positions)
+	}
+	if cash, _ := b.GetCash(ctx); cash != 520 {
+		t.Errorf("cash = %.2f, want 520", cash)
+	}
+
+	// The renamed ledger reloads to the same state and no temp file is left
+	reloaded, err := NewBroker(sessionCandles{day}, ledgerPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.ledger.Cash != 520 || reloaded.ledger.Positions["TQQQ"].Qty != 10 {
+		t.Errorf("reloaded ledger = %+v", reloaded.ledger)
+	}
+	entries, err := os.ReadDir(filepath.Dir(ledgerPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ledger dir holds %d files, want only the ledger", len(entries))
+	}
+}
@@ -21,6 +21,14 @@ func NewDB(path string) (*DB, error) {
 		&model.UserSettings{},
 		&model.TradeLog{},
 		&model.CycleStatus{},
+		&model.CycleEvent{},
+		&model.CycleConfig{},
+		&model.Cycle{},
+		&model.Order{},
+		&model.RealizedProfit{},
+		&model.BrokerTrade{},
+		&model.FXRate{},
+		&model.StrategyConfig{},
 	)
 	if err != nil {
 		return nil, err
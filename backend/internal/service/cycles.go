@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+	"gorm.io/gorm"
+)
+
+// defaultCycleSymbol is traded when UserSettings.Symbols is empty. It is not
+// one of rebalanceWeights, so both strategies can run on their defaults.
+const defaultCycleSymbol = "SOXL"
+
+// SymbolCycle is the effective infinite-buy setup of one symbol
+type SymbolCycle struct {
+	Symbol     string          `json:"symbol"`
+	Exchange   broker.Exchange `json:"exchange,omitempty"` // Empty = symbol master
+	Allocation float64         `json:"allocation"`         // Effective share of the principal
+	Principal  float64         `json:"principal"`          // In the principal currency
+	SplitCount int             `json:"split_count"`
+	TargetRate float64         `json:"target_rate"`
+	LadderRate float64         `json:"ladder_rate"`
+	// Config is the stored override, nil when the symbol runs on UserSettings
+	Config *model.CycleConfig `json:"config"`
+}
+
+// settings is UserSettings as the ladder of this symbol sees it
+func (c SymbolCycle) settings(base model.UserSettings) model.UserSettings {
+	base.Principal = c.Principal
+	base.SplitCount = c.SplitCount
+	base.TargetRate = c.TargetRate
+	base.LadderRate = c.LadderRate
+	return base
+}
+
+// lookup resolves the symbol, with the configured exchange over the master's
+func (c SymbolCycle) lookup(syms *symbols.Master) (symbols.Symbol, error) {
+	info, err := syms.Lookup(c.Symbol)
+	if err != nil {
+		return info, err
+	}
+	if c.Exchange != "" {
+		info.Exchange = c.Exchange
+	}
+	return info, nil
+}
+
+// CycleSymbols parses UserSettings.Symbols: upper-cased, without blanks and
+// duplicates, defaultCycleSymbol when empty
+func CycleSymbols(settings model.UserSettings) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, sym := range strings.Split(settings.Symbols, ",") {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if sym == "" || seen[sym] {
+			continue
+		}
+		seen[sym] = true
+		out = append(out, sym)
+	}
+	if len(out) == 0 {
+		out = []string{defaultCycleSymbol}
+	}
+	return out
+}
+
+// CheckCycleSymbols validates a new list of cycle symbols. A symbol whose
+// cycle still holds shares cannot be dropped: SyncState only follows the
+// cycle symbols, so the cycle would never close. While the infinite-buy
+// strategy is enabled, symbols another enabled strategy trades are rejected.
+func (s *Strategy) CheckCycleSymbols(syms []string) error {
+	var held []model.CycleStatus
+	if err := s.DB.Where("total_bought_qty > 0 AND symbol NOT IN ?", syms).Find(&held).Error; err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		names := make([]string, 0, len(held))
+		for _, c := range held {
+			names = append(names, fmt.Sprintf("%s (%d shares)", c.Symbol, c.TotalBoughtQty))
+		}
+		return broker.NewError(broker.CategoryValidation,
+			"cannot remove %s: the cycle still holds shares; sell them or keep the symbol until the cycle ends",
+			strings.Join(names, ", "))
+	}
+
+	cfg, err := s.StrategyConfig(PluginInfinite)
+	if err != nil || !cfg.Enabled {
+		return err
+	}
+	return s.CheckSymbolClaims(PluginInfinite, syms)
+}
+
+// SymbolCycles returns the cycle setup of every symbol of settings. Explicit
+// allocations are taken first; the symbols without one share the rest equally.
+func (s *Strategy) SymbolCycles(settings model.UserSettings) ([]SymbolCycle, error) {
+	syms := CycleSymbols(settings)
+	var configs []model.CycleConfig
+	if err := s.DB.Where("symbol IN ?", syms).Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]*model.CycleConfig)
+	for i := range configs {
+		bySymbol[configs[i].Symbol] = &configs[i]
+	}
+
+	allocated, open := 0.0, 0
+	for _, sym := range syms {
+		if cfg := bySymbol[sym]; cfg != nil && cfg.Allocation > 0 {
+			allocated += cfg.Allocation
+		} else {
+			open++
+		}
+	}
+	rest := 0.0
+	if open > 0 && allocated < 1 {
+		rest = (1 - allocated) / float64(open)
+	}
+
+	out := make([]SymbolCycle, 0, len(syms))
+	for _, sym := range syms {
+		c := SymbolCycle{
+			Symbol:     sym,
+			Allocation: rest,
+			SplitCount: settings.SplitCount,
+			TargetRate: settings.TargetRate,
+			LadderRate: ladderRate(settings),
+		}
+		if cfg := bySymbol[sym]; cfg != nil {
+			c.Config = cfg
+			c.Exchange = broker.Exchange(cfg.Exchange)
+			if cfg.Allocation > 0 {
+				c.Allocation = cfg.Allocation
+			}
+			if cfg.SplitCount > 0 {
+				c.SplitCount = cfg.SplitCount
+			}
+			if cfg.TargetRate > 0 {
+				c.TargetRate = cfg.TargetRate
+			}
+			if cfg.LadderRate > 0 {
+				c.LadderRate = cfg.LadderRate
+			}
+		}
+		c.Principal = settings.Principal * c.Allocation
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// SaveCycleConfig validates and stores the override of cfg.Symbol
+func (s *Strategy) SaveCycleConfig(cfg model.CycleConfig) (model.CycleConfig, error) {
+	cfg.Symbol = strings.ToUpper(strings.TrimSpace(cfg.Symbol))
+	if _, err := s.Symbols.Lookup(cfg.Symbol); err != nil {
+		return cfg, err
+	}
+	if cfg.Exchange != "" {
+		exch := symbols.ExchangeFromCode(cfg.Exchange)
+		if _, ok := symbols.OrderCode(exch); !ok {
+			return cfg, broker.NewError(broker.CategoryValidation, "%s: unsupported exchange %q", cfg.Symbol, cfg.Exchange)
+		}
+		cfg.Exchange = string(exch)
+	}
+	switch {
+	case cfg.Allocation < 0 || cfg.Allocation > 1:
+		return cfg, broker.NewError(broker.CategoryValidation, "%s: allocation must be between 0 and 1", cfg.Symbol)
+	case cfg.SplitCount < 0:
+		return cfg, broker.NewError(broker.CategoryValidation, "%s: split count must not be negative", cfg.Symbol)
+	case cfg.TargetRate < 0 || cfg.LadderRate < 0:
+		return cfg, broker.NewError(broker.CategoryValidation, "%s: rates must not be negative", cfg.Symbol)
+	case cfg.TargetRate > 0 && cfg.LadderRate >= cfg.TargetRate:
+		return cfg, broker.NewError(broker.CategoryValidation, "%s: ladder rate must be below the target rate", cfg.Symbol)
+	}
+
+	// The explicit allocations of the other symbols leave room for this one
+	var others []model.CycleConfig
+	if err := s.DB.Where("symbol <> ? AND allocation > 0", cfg.Symbol).Find(&others).Error; err != nil {
+		return cfg, err
+	}
+	var settings model.UserSettings
+	s.DB.First(&settings)
+	active := make(map[string]bool)
+	for _, sym := range CycleSymbols(settings) {
+		active[sym] = true
+	}
+	total := cfg.Allocation
+	for _, o := range others {
+		if active[o.Symbol] {
+			total += o.Allocation
+		}
+	}
+	if total > 1+1e-9 {
+		return cfg, broker.NewError(broker.CategoryValidation, "allocations add up to %.2f, more than the whole principal", total)
+	}
+
+	var stored model.CycleConfig
+	err := s.DB.Where("symbol = ?", cfg.Symbol).First(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cfg, err
+	}
+	cfg.Model = stored.Model
+	if err := s.DB.Save(&cfg).Error; err != nil {
+		return cfg, err
+	}
+	logWithTime("[CYCLE] %s config saved (exchange: %q, allocation: %.2f, splits: %d, target: %.2f, X: %.2f)",
+		cfg.Symbol, cfg.Exchange, cfg.Allocation, cfg.SplitCount, cfg.TargetRate, cfg.LadderRate)
+	return cfg, nil
+}
+
+// DeleteCycleConfig drops the override of symbol; it runs on UserSettings again
+func (s *Strategy) DeleteCycleConfig(symbol string) error {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	res := s.DB.Unscoped().Where("symbol = ?", symbol).Delete(&model.CycleConfig{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return broker.NewError(broker.CategoryValidation, "no cycle config for %s", symbol)
+	}
+	logWithTime("[CYCLE] %s config removed", symbol)
+	return nil
+}
+
+func (c SymbolCycle) String() string {
+	return fmt.Sprintf("%s (%.0f%%, %d splits, target %.1f%%)", c.Symbol, c.Allocation*100, c.SplitCount, c.TargetRate*100)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// SyncState only follows the cycle symbols: a cycle holding shares must stay one
+func TestDroppingHeldCycleSymbolRejected(t *testing.T) {
+	s := newTestStrategy(t)
+	s.DB.Create(&model.CycleStatus{Symbol: "SOXL", TotalBoughtQty: 10, AvgPrice: 30, TotalInvested: 300})
+	s.DB.Create(&model.CycleStatus{Symbol: "TQQQ"})
+
+	if err := s.CheckCycleSymbols([]string{"TQQQ"}); broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("dropping SOXL with 10 shares: err = %v", err)
+	}
+	// A cycle without shares may go
+	if err := s.CheckCycleSymbols([]string{"SOXL"}); err != nil {
+		t.Errorf("dropping the empty TQQQ cycle: %v", err)
+	}
+}
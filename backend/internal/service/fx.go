@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Principal currencies
+const (
+	CurrencyUSD = "USD"
+	CurrencyKRW = "KRW"
+)
+
+// ParseCurrency accepts "USD" (or empty) and "KRW"
+func ParseCurrency(s string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", CurrencyUSD:
+		return CurrencyUSD, nil
+	case CurrencyKRW:
+		return CurrencyKRW, nil
+	}
+	return "", fmt.Errorf("invalid currency: %q (expected USD or KRW)", s)
+}
+
+// kst dates the broker's rate postings
+var kst = time.FixedZone("KST", 9*60*60)
+
+// fxSourceConfig marks the FX_RATE fallback
+const fxSourceConfig = "config"
+
+// ExchangeRate is the USD/KRW rate a conversion used
+type ExchangeRate struct {
+	Rate   float64 `json:"rate"`   // KRW per USD
+	Date   string  `json:"date"`   // YYYY-MM-DD (KST) the rate was posted
+	Source string  `json:"source"` // Broker name, or "config"
+}
+
+// KRW converts a USD amount, rounded to the won
+func (r *ExchangeRate) KRW(usd float64) float64 {
+	return math.Round(usd * r.Rate)
+}
+
+// USD converts a KRW amount
+func (r *ExchangeRate) USD(krw float64) float64 {
+	return krw / r.Rate
+}
+
+// FXBook keeps the daily USD/KRW table (FXRate) fed by the broker's quote
+type FXBook struct {
+	DB     *repository.DB
+	Broker broker.Broker
+	// Fallback is the rate used while no quote is stored (FX_RATE), 0 = none
+	Fallback float64
+}
+
+func NewFXBook(db *repository.DB, b broker.Broker) *FXBook {
+	return &FXBook{DB: db, Broker: b}
+}
+
+func rateOf(row model.FXRate) *ExchangeRate {
+	return &ExchangeRate{Rate: row.Rate, Date: row.Date, Source: row.Source}
+}
+
+// Refresh quotes the broker and stores the rate as the row of its posting date
+func (f *FXBook) Refresh(ctx context.Context) (*ExchangeRate, error) {
+	q, ok := f.Broker.(broker.FXQuoter)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "%s broker does not quote exchange rates", f.Broker.Name())
+	}
+	r, err := q.GetFXRate(ctx)
+	if err != nil {
+		logWithTime("[FX] ✗ Failed to quote USD/KRW: %v", err)
+		return nil, err
+	}
+
+	date := isoDate(r.Date)
+	var row model.FXRate
+	if err := f.DB.Where("date = ?", date).First(&row).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	row.Date, row.Rate, row.Source = date, r.Rate, f.Broker.Name()
+	if err := f.DB.Save(&row).Error; err != nil {
+		return nil, err
+	}
+	logWithTime("[FX] ✓ USD/KRW %.2f (%s, %s)", row.Rate, row.Date, row.Source)
+	return rateOf(row), nil
+}
+
+// On returns the rate in effect on date (YYYY-MM-DD): that day's row or the
+// latest one before it, else the fallback
+func (f *FXBook) On(date string) (*ExchangeRate, error) {
+	var row model.FXRate
+	err := f.DB.Where("date <= ?", date).Order("date DESC").First(&row).Error
+	if err == nil {
+		return rateOf(row), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if f.Fallback > 0 {
+		return &ExchangeRate{Rate: f.Fallback, Date: date, Source: fxSourceConfig}, nil
+	}
+	return nil, broker.NewError(broker.CategoryValidation, "no USD/KRW rate known on %s (set FX_RATE or refresh from the broker)", date)
+}
+
+// Current returns today's rate, quoting the broker when today has no row
+// yet. A failed quote falls back to the last stored rate.
+func (f *FXBook) Current(ctx context.Context) (*ExchangeRate, error) {
+	today := time.Now().In(kst).Format("2006-01-02")
+	var row model.FXRate
+	if err := f.DB.Where("date = ?", today).First(&row).Error; err == nil {
+		return rateOf(row), nil
+	}
+	if _, ok := f.Broker.(broker.FXQuoter); ok {
+		r, err := f.Refresh(ctx)
+		if err == nil {
+			return r, nil
+		}
+		logWithTime("[FX] ⚠ Using the last known rate")
+	}
+	return f.On(today)
+}
+
+// History returns the stored rates between from and to (YYYY-MM-DD), oldest first
+func (f *FXBook) History(from, to string) ([]ExchangeRate, error) {
+	var rows []model.FXRate
+	if err := f.DB.Where("date BETWEEN ? AND ?", from, to).Order("date").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]ExchangeRate, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *rateOf(row))
+	}
+	return out, nil
+}
+
+// principalUSD returns the strategy principal in USD; a KRW principal is
+// converted at the current rate
+func (s *Strategy) principalUSD(ctx context.Context, settings model.UserSettings) (float64, error) {
+	if settings.PrincipalCurrency != CurrencyKRW {
+		return settings.Principal, nil
+	}
+	fx, err := s.FX.Current(ctx)
+	if err != nil {
+		return 0, err
+	}
+	usd := fx.USD(settings.Principal)
+	logWithTime("[FX] Principal ₩%.0f = $%.2f at %.2f (%s)", settings.Principal, usd, fx.Rate, fx.Date)
+	return usd, nil
+}
+
+// Amounts are the account figures in one currency
+type Amounts struct {
+	Principal    float64 `json:"principal"`
+	Cash         float64 `json:"cash"`
+	Invested     float64 `json:"invested"`
+	Evaluation   float64 `json:"evaluation"`
+	Equity       float64 `json:"equity"` // Cash + Evaluation
+	UnrealizedPL float64 `json:"unrealized_pl"`
+	RealizedPL   float64 `json:"realized_pl"`
+}
+
+// convert values a in KRW at fx
+func (a Amounts) convert(fx *ExchangeRate) Amounts {
+	return Amounts{
+		Principal:    fx.KRW(a.Principal),
+		Cash:         fx.KRW(a.Cash),
+		Invested:     fx.KRW(a.Invested),
+		Evaluation:   fx.KRW(a.Evaluation),
+		Equity:       fx.KRW(a.Equity),
+		UnrealizedPL: fx.KRW(a.UnrealizedPL),
+		RealizedPL:   fx.KRW(a.RealizedPL),
+	}
+}
+
+// AccountValue is the account in USD and, once a rate is known, in KRW
+type AccountValue struct {
+	USD Amounts       `json:"usd"`
+	KRW *Amounts      `json:"krw"` // nil without a USD/KRW rate
+	FX  *ExchangeRate `json:"fx"`
+}
+
+// AccountValue values the broker balance and the principal in both
+// currencies. A KRW principal is reported as entered, not round-tripped.
+func (s *Strategy) AccountValue(ctx context.Context) (*AccountValue, error) {
+	bal, err := s.Broker.GetBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cash, err := s.Broker.GetCash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var settings model.UserSettings
+	s.DB.First(&settings)
+
+	v := &AccountValue{USD: Amounts{
+		Cash:         cash,
+		Invested:     bal.TotalPurchase,
+		Evaluation:   bal.TotalEvaluation,
+		Equity:       cash + bal.TotalEvaluation,
+		UnrealizedPL: bal.TotalPL,
+		RealizedPL:   bal.RealizedPL,
+	}}
+	if settings.PrincipalCurrency != CurrencyKRW {
+		v.USD.Principal = settings.Principal
+	}
+
+	fx, err := s.FX.Current(ctx)
+	if err != nil {
+		logWithTime("[FX] ⚠ No USD/KRW rate, reporting USD only: %v", err)
+		return v, nil
+	}
+	v.FX = fx
+	if settings.PrincipalCurrency == CurrencyKRW {
+		v.USD.Principal = fx.USD(settings.Principal)
+	}
+	krw := v.USD.convert(fx)
+	if settings.PrincipalCurrency == CurrencyKRW {
+		krw.Principal = settings.Principal
+	}
+	v.KRW = &krw
+	return v, nil
+}
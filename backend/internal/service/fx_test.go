@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// A date without a row takes the latest rate before it; before any row the
+// FX_RATE fallback, and without one a VALIDATION error
+func TestFXRateOnFallsBack(t *testing.T) {
+	s := newTestStrategy(t)
+	fx := s.FX
+	s.DB.Create(&model.FXRate{Date: "2025-03-10", Rate: 1450, Source: "kis"})
+	s.DB.Create(&model.FXRate{Date: "2025-03-12", Rate: 1460, Source: "kis"})
+
+	for date, want := range map[string]float64{
+		"2025-03-10": 1450,
+		"2025-03-11": 1450, // No posting: the day before
+		"2025-03-12": 1460,
+		"2025-03-16": 1460, // Weekend
+	} {
+		r, err := fx.On(date)
+		if err != nil || r.Rate != want {
+			t.Errorf("On(%s) = %+v, %v; want %.0f", date, r, err, want)
+		}
+	}
+
+	if _, err := fx.On("2025-03-01"); broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("before any rate without FX_RATE: err = %v, want VALIDATION", err)
+	}
+	fx.Fallback = 1400
+	if r, err := fx.On("2025-03-01"); err != nil || r.Rate != 1400 || r.Source != fxSourceConfig {
+		t.Errorf("before any rate with FX_RATE: %+v, %v; want 1400 from config", r, err)
+	}
+}
+
+// A failed broker quote falls back to the last stored rate
+func TestFXRateCurrentUsesLastRateWhenQuoteFails(t *testing.T) {
+	tr, _ := newKISTracker(t, kisfake.Scenario{
+		Faults: []kisfake.Fault{{Path: "/inquire-present-balance", Status: 400, MsgCd: "OPSQ0001", Msg1: "조회할 자료가 없습니다."}},
+	})
+	fx := NewFXBook(tr.DB, tr.Broker)
+	tr.DB.Create(&model.FXRate{Date: "2025-03-12", Rate: 1460, Source: "kis"})
+
+	r, err := fx.Current(t.Context())
+	if err != nil || r.Rate != 1460 || r.Date != "2025-03-12" {
+		t.Errorf("Current with a failing quote = %+v, %v; want the 2025-03-12 rate", r, err)
+	}
+}
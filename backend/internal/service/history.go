@@ -0,0 +1,154 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// How a cycle ended (model.Cycle.EndReason)
+const (
+	EndTarget      = "TARGET"       // Sold by the ladder at or above the average
+	EndQuarterStop = "QUARTER_STOP" // Sold by the ladder below the average
+	EndReverse     = "REVERSE"      // Sold out in reverse mode
+	EndManual      = "MANUAL"       // Sold by a manual or rebalance order, or outside the app
+)
+
+// startCycle dates a cycle that begins now, unless it already has a start
+func startCycle(cycle *model.CycleStatus) {
+	if cycle.StartedAt == nil {
+		now := time.Now()
+		cycle.StartedAt = &now
+	}
+}
+
+// closeCycle records the cycle whose position is gone. cycle still holds its
+// last average price, cost basis and ladder state; the figures come from the
+// TradeLog fills since the start. A cycle synced from shares bought outside
+// the app has no buy fills for them, so its invested amount is at least the
+// last synced cost basis.
+func (s *Strategy) closeCycle(cycle model.CycleStatus, splitCount int) {
+	end := time.Now()
+	start := cycle.CreatedAt
+	if cycle.StartedAt != nil {
+		start = *cycle.StartedAt
+	} else {
+		// Started before cycles were dated: right after the previous one
+		var prev model.Cycle
+		if err := s.DB.Where("symbol = ?", cycle.Symbol).Order("end_date DESC").First(&prev).Error; err == nil {
+			start = prev.EndDate
+		}
+	}
+
+	rec := model.Cycle{
+		Symbol:       cycle.Symbol,
+		StartDate:    start,
+		EndDate:      end,
+		Days:         cycle.CurrentCycleDay,
+		SplitCount:   splitCount,
+		UnitsUsed:    cycle.UnitsUsed,
+		QuarterStops: cycle.QuarterStops,
+		EndReason:    EndManual,
+	}
+
+	var logs []model.TradeLog
+	s.DB.Where("symbol = ? AND date >= ?", cycle.Symbol, start).Find(&logs)
+	for _, l := range logs {
+		if l.Side == string(broker.SideBuy) {
+			rec.TotalInvested += l.Amount
+		} else {
+			rec.TotalSold += l.Amount
+			rec.RealizedProfit += l.Profit
+		}
+	}
+	rec.TotalInvested = math.Max(rec.TotalInvested, cycle.TotalInvested)
+	if rec.TotalInvested > 0 {
+		rec.ReturnRate = math.Round(rec.RealizedProfit/rec.TotalInvested*10000) / 10000
+	}
+
+	// The last sell decides how the cycle ended
+	var last model.Order
+	err := s.DB.Where("symbol = ? AND side = ? AND filled_qty > 0 AND submitted_at >= ?",
+		cycle.Symbol, broker.SideSell, start).Order("updated_at DESC").First(&last).Error
+	switch {
+	case err != nil || last.Source != SourceDaily:
+		rec.EndReason = EndManual
+	case last.AvgFillPrice >= cycle.AvgPrice:
+		rec.EndReason = EndTarget
+	case cycle.Mode == ModeReverse:
+		rec.EndReason = EndReverse
+	default:
+		rec.EndReason = EndQuarterStop
+	}
+
+	if err := s.DB.Create(&rec).Error; err != nil {
+		logWithTime("[SYNC] ⚠ Failed to record the finished cycle of %s: %v", cycle.Symbol, err)
+		return
+	}
+	logWithTime("[SYNC] ✓ Cycle of %s recorded: %s ~ %s, %d sessions, invested $%.2f, profit $%.2f (%.2f%%), ended by %s",
+		rec.Symbol, start.Format("2006-01-02"), end.Format("2006-01-02"), rec.Days,
+		rec.TotalInvested, rec.RealizedProfit, rec.ReturnRate*100, rec.EndReason)
+}
+
+// CycleStats aggregates the finished cycles of one symbol
+type CycleStats struct {
+	Symbol         string         `json:"symbol"`
+	Cycles         int            `json:"cycles"`
+	Wins           int            `json:"wins"` // Cycles with a profit
+	WinRate        float64        `json:"win_rate"`
+	TotalInvested  float64        `json:"total_invested"`
+	RealizedProfit float64        `json:"realized_profit"`
+	AvgReturn      float64        `json:"avg_return"` // Mean ReturnRate
+	AvgDays        float64        `json:"avg_days"`
+	MaxDays        int            `json:"max_days"`
+	EndReasons     map[string]int `json:"end_reasons"`
+}
+
+// CycleHistory returns the finished cycles, newest first, of symbol when it is
+// set, with the statistics of each symbol
+func (s *Strategy) CycleHistory(symbol string) ([]model.Cycle, []CycleStats, error) {
+	query := s.DB.Order("end_date DESC")
+	if symbol != "" {
+		query = query.Where("symbol = ?", strings.ToUpper(symbol))
+	}
+	var cycles []model.Cycle
+	if err := query.Find(&cycles).Error; err != nil {
+		return nil, nil, err
+	}
+
+	bySymbol := make(map[string]*CycleStats)
+	for _, c := range cycles {
+		st := bySymbol[c.Symbol]
+		if st == nil {
+			st = &CycleStats{Symbol: c.Symbol, EndReasons: make(map[string]int)}
+			bySymbol[c.Symbol] = st
+		}
+		st.Cycles++
+		if c.RealizedProfit > 0 {
+			st.Wins++
+		}
+		st.TotalInvested += c.TotalInvested
+		st.RealizedProfit += c.RealizedProfit
+		st.AvgReturn += c.ReturnRate
+		st.AvgDays += float64(c.Days)
+		if c.Days > st.MaxDays {
+			st.MaxDays = c.Days
+		}
+		st.EndReasons[c.EndReason]++
+	}
+
+	stats := make([]CycleStats, 0, len(bySymbol))
+	for _, st := range bySymbol {
+		n := float64(st.Cycles)
+		st.WinRate = float64(st.Wins) / n
+		st.AvgReturn /= n
+		st.AvgDays /= n
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Symbol < stats[j].Symbol })
+	return cycles, stats, nil
+}
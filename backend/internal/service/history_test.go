@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// A cycle synced from shares bought outside the app has only its sell fills
+// logged; the cost basis it was synced with is what it invested
+func TestCloseCycleSyncedFromHoldings(t *testing.T) {
+	s := newTestStrategy(t)
+	started := time.Now().Add(-48 * time.Hour)
+	s.DB.Create(&model.TradeLog{
+		Date: time.Now(), Symbol: "TQQQ", Side: "SELL", Type: "LIMIT",
+		Qty: 100, Price: 55, Amount: 5500, Profit: 500,
+	})
+
+	s.closeCycle(model.CycleStatus{
+		Symbol: "TQQQ", TotalBoughtQty: 100, AvgPrice: 50, TotalInvested: 5000,
+		UnitsUsed: 20, StartedAt: &started,
+	}, 40)
+
+	var rec model.Cycle
+	if err := s.DB.First(&rec).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rec.TotalInvested != 5000 || rec.RealizedProfit != 500 || rec.ReturnRate != 0.1 || rec.UnitsUsed != 20 {
+		t.Errorf("cycle = invested %.2f, profit %.2f, return %.4f, T %.2f; want 5000, 500, 0.1, 20",
+			rec.TotalInvested, rec.RealizedProfit, rec.ReturnRate, rec.UnitsUsed)
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// Phases of an infinite-buy cycle, by T (units used) against SplitCount
+const (
+	PhaseFirstHalf  = "FIRST_HALF"  // T ≤ SplitCount/2: half the unit at avg, half at avg × (1 + X)
+	PhaseSecondHalf = "SECOND_HALF" // T > SplitCount/2: the whole unit at avg
+	PhaseExhausted  = "EXHAUSTED"   // T ≥ SplitCount or the principal spent: the exhaust policy applies
+)
+
+// ModeReverse is the CycleStatus.Mode of a cycle in reverse mode
+const ModeReverse = "REVERSE"
+
+// What an exhausted cycle does (UserSettings.ExhaustPolicy)
+const (
+	// ExhaustQuarter sells a quarter MOC; the freed units restart the ladder in the second half
+	ExhaustQuarter = "quarter"
+	// ExhaustReverse enters reverse mode: a portion sold MOC every session and
+	// rebought LOC X below the last sale, until the price is back at the average
+	ExhaustReverse = "reverse"
+)
+
+// ParseExhaustPolicy accepts "quarter" (or empty) and "reverse"
+func ParseExhaustPolicy(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", ExhaustQuarter:
+		return ExhaustQuarter, nil
+	case ExhaustReverse:
+		return ExhaustReverse, nil
+	}
+	return "", fmt.Errorf("invalid exhaust policy: %q (expected quarter or reverse)", s)
+}
+
+// Cycle events (model.CycleEvent)
+const (
+	EventExhausted    = "EXHAUSTED"
+	EventQuarterStop  = "QUARTER_STOP"
+	EventReverseStart = "REVERSE_START"
+	EventReverseSell  = "REVERSE_SELL"
+	EventReverseEnd   = "REVERSE_END"
+)
+
+// defaultLadderRate is X when the settings have none
+const defaultLadderRate = 0.05
+
+// ladderOrder is one order of the daily ladder
+type ladderOrder struct {
+	Req    broker.OrderRequest
+	Reason string
+	Stop   bool // The quarter stop or reverse sell
+}
+
+// ladder is one session's orders of a cycle
+type ladder struct {
+	Symbol string        `json:"symbol"`
+	Phase  string        `json:"phase"`
+	Mode   string        `json:"mode"`   // REVERSE in reverse mode
+	Policy string        `json:"policy"` // Exhaust policy
+	T      float64       `json:"t"`      // Units used before this session
+	Splits int           `json:"split_count"`
+	Unit   float64       `json:"unit"` // USD per unit
+	Avg    float64       `json:"avg_price"`
+	Buys   []ladderOrder `json:"-"`
+	Sells  []ladderOrder `json:"-"`
+}
+
+func (l ladder) orders() []ladderOrder {
+	return append(append([]ladderOrder(nil), l.Buys...), l.Sells...)
+}
+
+// exhaustPolicy is the exhaust policy of the settings
+func exhaustPolicy(settings model.UserSettings) string {
+	p, err := ParseExhaustPolicy(settings.ExhaustPolicy)
+	if err != nil {
+		return ExhaustQuarter
+	}
+	return p
+}
+
+// ladderRate is X of the settings
+func ladderRate(settings model.UserSettings) float64 {
+	if settings.LadderRate > 0 {
+		return settings.LadderRate
+	}
+	return defaultLadderRate
+}
+
+// unitAmount is the USD of one split
+func unitAmount(settings model.UserSettings) float64 {
+	if settings.SplitCount <= 0 {
+		return 0
+	}
+	return settings.Principal / float64(settings.SplitCount)
+}
+
+// unitsUsed is T: how many units the position has cost, to two decimals
+func unitsUsed(cycle model.CycleStatus, unit float64) float64 {
+	if unit <= 0 || cycle.TotalBoughtQty <= 0 {
+		return 0
+	}
+	return math.Round(cycle.TotalInvested/unit*100) / 100
+}
+
+// cyclePhase is the phase at T units of split
+func cyclePhase(t float64, split int) string {
+	switch {
+	case t >= float64(split):
+		return PhaseExhausted
+	case t > float64(split)/2:
+		return PhaseSecondHalf
+	}
+	return PhaseFirstHalf
+}
+
+// planLadder builds the infinite-buy (무한매수법) orders of a session from the
+// cycle as synced with the broker:
+//
+//   - First half: half the unit LOC at the average price, half LOC at
+//     avg × (1 + X), one tick under the quarter sell so the two never cross.
+//     A new cycle starts with the whole unit LOC at price × (1 + X).
+//   - Second half: the whole unit LOC at the average price.
+//   - Sells: a quarter of the position LOC at avg × (1 + X), the rest as a
+//     limit at avg × (1 + TargetRate).
+//   - Exhausted (SplitCount units used, or not a share left in the
+//     principal at the average): no ladder buy. The quarter policy
+//     sells a quarter MOC, which frees a quarter of the units so the cycle
+//     carries on in the second half. The reverse policy switches to reverse mode.
+//   - Reverse mode: SplitCount/4 portions, one sold MOC per session, and up to
+//     a unit rebought LOC X below the last sale. It ends once the price is
+//     back at the average and the cycle resumes by T. An exhausted cycle at or
+//     above the average has nothing to reverse: it sells no stop and waits in
+//     the second half for its quarter and target sells, buying only what is
+//     left of the principal.
+//
+// Buys never exceed the day's budget: a unit below one share buys nothing.
+//
+// closeType is LOC, or a plain limit where the broker or the placement takes
+// no on-close orders; MOC sells then go out as limits at the quoted price.
+func planLadder(info symbols.Symbol, cycle model.CycleStatus, settings model.UserSettings, price float64, closeType broker.OrderType) ladder {
+	x := ladderRate(settings)
+	unit := unitAmount(settings)
+	l := ladder{Symbol: info.Ticker, Mode: cycle.Mode, Policy: exhaustPolicy(settings), Unit: unit, Avg: cycle.AvgPrice, T: unitsUsed(cycle, unit), Splits: settings.SplitCount}
+	l.Phase = cyclePhase(l.T, settings.SplitCount)
+
+	order := func(side broker.Side, typ broker.OrderType, qty int, px float64) broker.OrderRequest {
+		return broker.OrderRequest{Exchange: info.Exchange, Symbol: info.Ticker, Side: side, Type: typ, Qty: qty, Price: px}
+	}
+	buy := func(qty int, px float64, reason string) {
+		if qty > 0 {
+			l.Buys = append(l.Buys, ladderOrder{Req: order(broker.SideBuy, closeType, qty, px), Reason: reason})
+		}
+	}
+	// atClose sells qty MOC, or at the quote without on-close orders
+	atClose := func(qty int, reason string) {
+		req := order(broker.SideSell, broker.OrderTypeMOC, qty, 0)
+		if closeType != broker.OrderTypeLOC {
+			req = order(broker.SideSell, closeType, qty, info.RoundPrice(price, broker.SideBuy))
+		}
+		l.Sells = append(l.Sells, ladderOrder{Req: req, Reason: reason, Stop: true})
+	}
+
+	if cycle.TotalBoughtQty <= 0 || cycle.AvgPrice <= 0 {
+		l.Mode = ""
+		px := info.RoundPrice(price*(1+x), broker.SideBuy)
+		buy(int(math.Floor(unit/px)), px, fmt.Sprintf("cycle start: 1 unit at price +%.1f%%", x*100))
+		return l
+	}
+
+	// The last unit is whatever is left of the principal. Once that no longer
+	// buys a share at the average the principal is spent.
+	avgPx := info.RoundPrice(cycle.AvgPrice, broker.SideBuy)
+	budget := math.Min(unit, settings.Principal-cycle.TotalInvested)
+	if settings.Principal-cycle.TotalInvested < avgPx {
+		l.Phase = PhaseExhausted
+	}
+	recovered := false
+	switch {
+	case l.Mode == ModeReverse && price >= cycle.AvgPrice:
+		l.Mode = ""
+		recovered = true
+	case l.Mode == "" && l.Phase == PhaseExhausted && l.Policy == ExhaustReverse:
+		if price < cycle.AvgPrice {
+			l.Mode = ModeReverse
+		} else {
+			recovered = true
+		}
+	}
+	if recovered && l.Phase == PhaseExhausted {
+		l.Phase = PhaseSecondHalf
+	}
+
+	qty := cycle.TotalBoughtQty
+	target := func(rest int) {
+		if rest > 0 {
+			px := info.RoundPrice(cycle.AvgPrice*(1+settings.TargetRate), broker.SideSell)
+			l.Sells = append(l.Sells, ladderOrder{Req: order(broker.SideSell, broker.OrderTypeLimit, rest, px), Reason: fmt.Sprintf("target +%.1f%%", settings.TargetRate*100)})
+		}
+	}
+
+	if l.Mode == ModeReverse {
+		portion := qty * 4 / max(settings.SplitCount, 4)
+		if portion < 1 {
+			portion = 1
+		}
+		if cycle.ReversePrice > 0 && budget > 0 {
+			px := info.RoundPrice(cycle.ReversePrice*(1-x), broker.SideBuy)
+			buy(int(math.Floor(budget/px)), px, fmt.Sprintf("reverse rebuy: %.1f%% below the last sale at $%.2f", x*100, cycle.ReversePrice))
+		}
+		atClose(portion, fmt.Sprintf("reverse sell: 1/%d of the position (day %d)", max(settings.SplitCount/4, 1), cycle.ReverseDays+1))
+		target(qty - portion)
+		return l
+	}
+
+	switch l.Phase {
+	case PhaseExhausted:
+		// No buy: the principal is spent
+	case PhaseFirstHalf:
+		sellPx := info.RoundPrice(cycle.AvgPrice*(1+x), broker.SideSell)
+		highPx := info.RoundPrice(sellPx-info.Tick(sellPx), broker.SideBuy)
+		buy(int(math.Floor(budget/2/avgPx)), avgPx, fmt.Sprintf("T=%.2f first half: ½ unit at avg", l.T))
+		buy(int(math.Floor(budget/2/highPx)), highPx, fmt.Sprintf("T=%.2f first half: ½ unit at avg +%.1f%%", l.T, x*100))
+		if len(l.Buys) == 0 && budget >= avgPx {
+			// Neither half buys a share but the whole unit does
+			buy(1, avgPx, fmt.Sprintf("T=%.2f: 1 share at avg", l.T))
+		}
+	default:
+		buy(int(math.Floor(budget/avgPx)), avgPx, fmt.Sprintf("T=%.2f second half: 1 unit at avg", l.T))
+	}
+
+	quarter := qty / 4
+	if l.Phase == PhaseExhausted {
+		atClose(max(quarter, 1), fmt.Sprintf("quarter stop: T=%.2f of %d", l.T, settings.SplitCount))
+		target(qty - max(quarter, 1))
+		return l
+	}
+	if quarter > 0 {
+		px := info.RoundPrice(cycle.AvgPrice*(1+x), broker.SideSell)
+		l.Sells = append(l.Sells, ladderOrder{Req: order(broker.SideSell, closeType, quarter, px), Reason: fmt.Sprintf("¼ at avg +%.1f%%", x*100)})
+	}
+	target(qty - quarter)
+	return l
+}
+
+func modeSuffix(mode string) string {
+	if mode == "" {
+		return ""
+	}
+	return " (" + mode + ")"
+}
+
+// resetCycle clears the ladder state of a sold-out cycle
+func resetCycle(cycle *model.CycleStatus) {
+	cycle.StartedAt = nil
+	cycle.UnitsUsed = 0
+	cycle.Phase = ""
+	cycle.QuarterStops = 0
+	cycle.Mode = ""
+	cycle.ExhaustedAt = nil
+	cycle.ReverseDays = 0
+	cycle.ReversePrice = 0
+}
+
+// advanceCycle moves cycle to the phase and mode of plan, recording the transitions
+func (s *Strategy) advanceCycle(cycle *model.CycleStatus, plan ladder, price float64) {
+	if plan.Phase != cycle.Phase {
+		logWithTime("[%s] Phase changed: %q → %s", cycle.Symbol, cycle.Phase, plan.Phase)
+	}
+	cycle.UnitsUsed = plan.T
+	exhausted := plan.Phase == PhaseExhausted || plan.Mode == ModeReverse
+	if exhausted && cycle.ExhaustedAt == nil {
+		now := time.Now()
+		cycle.ExhaustedAt = &now
+		s.recordCycleEvent(*cycle, EventExhausted, plan.Policy, 0, price,
+			fmt.Sprintf("%.2f of %d units used", plan.T, plan.Splits))
+	}
+
+	switch {
+	case plan.Mode == ModeReverse && cycle.Mode != ModeReverse:
+		logWithTime("[%s] ⚠ Entering reverse mode", cycle.Symbol)
+		cycle.ReverseDays = 0
+		cycle.ReversePrice = 0
+		s.recordCycleEvent(*cycle, EventReverseStart, plan.Policy, 0, price, "")
+	case plan.Mode != ModeReverse && cycle.Mode == ModeReverse:
+		logWithTime("[%s] ✓ Leaving reverse mode after %d sessions: $%.2f is back at the average $%.2f",
+			cycle.Symbol, cycle.ReverseDays, price, cycle.AvgPrice)
+		s.recordCycleEvent(*cycle, EventReverseEnd, plan.Policy, 0, price,
+			fmt.Sprintf("after %d sessions", cycle.ReverseDays))
+		cycle.ReverseDays = 0
+		cycle.ReversePrice = 0
+	}
+	if !exhausted {
+		cycle.ExhaustedAt = nil
+	}
+	cycle.Phase = plan.Phase
+	cycle.Mode = plan.Mode
+}
+
+// recordStop books a placed quarter stop or reverse sell of req, quoted at price
+func (s *Strategy) recordStop(cycle *model.CycleStatus, plan ladder, req broker.OrderRequest, price float64) {
+	if plan.Mode == ModeReverse {
+		cycle.ReverseDays++
+		cycle.ReversePrice = price
+		logWithTime("[%s] ⚠ Reverse sell #%d placed", cycle.Symbol, cycle.ReverseDays)
+		s.recordCycleEvent(*cycle, EventReverseSell, plan.Policy, req.Qty, price, fmt.Sprintf("day %d", cycle.ReverseDays))
+		return
+	}
+	cycle.QuarterStops++
+	logWithTime("[%s] ⚠ Quarter stop #%d placed", cycle.Symbol, cycle.QuarterStops)
+	s.recordCycleEvent(*cycle, EventQuarterStop, plan.Policy, req.Qty, price, fmt.Sprintf("stop #%d", cycle.QuarterStops))
+}
+
+func (s *Strategy) recordCycleEvent(cycle model.CycleStatus, event, policy string, qty int, price float64, note string) {
+	ev := model.CycleEvent{
+		Date:     time.Now(),
+		Symbol:   cycle.Symbol,
+		Event:    event,
+		Policy:   policy,
+		T:        cycle.UnitsUsed,
+		Qty:      qty,
+		Price:    price,
+		AvgPrice: cycle.AvgPrice,
+		Note:     note,
+	}
+	if err := s.DB.Create(&ev).Error; err != nil {
+		logWithTime("[%s] ⚠ Failed to record cycle event %s: %v", cycle.Symbol, event, err)
+	}
+}
+
+// CycleEvents returns the latest cycle events, of symbol when it is set
+func (s *Strategy) CycleEvents(symbol string, limit int) ([]model.CycleEvent, error) {
+	query := s.DB.Order("date DESC").Limit(limit)
+	if symbol != "" {
+		query = query.Where("symbol = ?", strings.ToUpper(symbol))
+	}
+	var events []model.CycleEvent
+	err := query.Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// An exhausted cycle in reverse mode whose price is back at the average leaves
+// reverse mode without a stop sale and does not re-enter it the next session
+func TestReverseRecoverySellsNoStop(t *testing.T) {
+	info, err := symbols.Default().Lookup("TQQQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := model.UserSettings{
+		Principal: 10000, SplitCount: 40, TargetRate: 0.10, LadderRate: 0.05,
+		ExhaustPolicy: ExhaustReverse,
+	}
+	cycle := model.CycleStatus{
+		Symbol: "TQQQ", TotalBoughtQty: 200, AvgPrice: 50, TotalInvested: 10000,
+		Phase: PhaseExhausted, Mode: ModeReverse, ReverseDays: 3, ReversePrice: 48,
+	}
+
+	check := func(session string, l ladder) {
+		t.Helper()
+		if l.Mode != "" || l.Phase == PhaseExhausted {
+			t.Errorf("%s: mode %q phase %s, want out of reverse and not exhausted", session, l.Mode, l.Phase)
+		}
+		if len(l.Buys) != 0 {
+			t.Errorf("%s: %d buys with the principal spent", session, len(l.Buys))
+		}
+		sold := 0
+		for _, o := range l.Sells {
+			if o.Stop || o.Req.Type == broker.OrderTypeMOC {
+				t.Errorf("%s: stop sale %+v (%s)", session, o.Req, o.Reason)
+			}
+			sold += o.Req.Qty
+		}
+		if len(l.Sells) != 2 || sold != 200 {
+			t.Errorf("%s: sells %+v, want the quarter and the target for 200", session, l.Sells)
+		}
+	}
+
+	l := planLadder(info, cycle, settings, 51, broker.OrderTypeLOC)
+	check("recovery", l)
+	if l.Sells[0].Req.Qty != 50 || l.Sells[0].Req.Price != 52.5 || l.Sells[1].Req.Qty != 150 || l.Sells[1].Req.Price != 55 {
+		t.Errorf("recovery sells %+v, want 50 LOC @52.50 and 150 @55.00", l.Sells)
+	}
+
+	// The next session above the average still waits for the sells
+	cycle.Phase, cycle.Mode, cycle.ReverseDays, cycle.ReversePrice = l.Phase, l.Mode, 0, 0
+	check("next session", planLadder(info, cycle, settings, 50.5, broker.OrderTypeLOC))
+
+	// Below the average again the cycle goes back into reverse
+	if l := planLadder(info, cycle, settings, 49, broker.OrderTypeLOC); l.Mode != ModeReverse {
+		t.Errorf("below the average: mode %q, want %s", l.Mode, ModeReverse)
+	}
+}
+
+// A day's budget below one share buys nothing instead of one share over budget
+func TestLadderNeverBuysOverBudget(t *testing.T) {
+	info, err := symbols.Default().Lookup("TQQQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name      string
+		principal float64
+		invested  float64
+		qty       int
+		phase     string
+		buys      int // Shares bought
+	}{
+		// $1000 / 40 = $25 a unit, half a share at $50
+		{"first half, unit under a share", 1000, 100, 2, PhaseFirstHalf, 0},
+		{"second half, unit under a share", 1000, 800, 16, PhaseSecondHalf, 0},
+		{"cycle start, unit under a share", 1000, 0, 0, PhaseFirstHalf, 0},
+		// $3000 / 40 = $75: neither half buys a share, the whole unit buys one
+		{"first half, halves under a share", 3000, 300, 6, PhaseFirstHalf, 1},
+		// $30 of principal left does not buy a share at $50
+		{"principal left under a share", 10000, 9970, 199, PhaseExhausted, 0},
+	} {
+		settings := model.UserSettings{Principal: tc.principal, SplitCount: 40, TargetRate: 0.10, LadderRate: 0.05}
+		cycle := model.CycleStatus{Symbol: "TQQQ", TotalBoughtQty: tc.qty, TotalInvested: tc.invested}
+		if tc.qty > 0 {
+			cycle.AvgPrice = tc.invested / float64(tc.qty)
+		}
+		l := planLadder(info, cycle, settings, 50, broker.OrderTypeLOC)
+
+		bought, spent := 0, 0.0
+		for _, o := range l.Buys {
+			bought += o.Req.Qty
+			spent += float64(o.Req.Qty) * o.Req.Price
+		}
+		if l.Phase != tc.phase || bought != tc.buys {
+			t.Errorf("%s: phase %s, %d shares bought; want %s, %d", tc.name, l.Phase, bought, tc.phase, tc.buys)
+		}
+		if unit := tc.principal / 40; spent > unit {
+			t.Errorf("%s: $%.2f of buys over the $%.2f unit", tc.name, spent, unit)
+		}
+	}
+}
+
+type wantOrder struct {
+	side  broker.Side
+	typ   broker.OrderType
+	qty   int
+	price float64
+}
+
+// The half-phase ladder of a $10000 / 40 split cycle ($250 a unit, X 5%,
+// target 10%) at an average of $50
+func TestLadderPhases(t *testing.T) {
+	info, err := symbols.Default().Lookup("TQQQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := model.UserSettings{Principal: 10000, SplitCount: 40, TargetRate: 0.10, LadderRate: 0.05}
+	const (
+		buy  = broker.SideBuy
+		sell = broker.SideSell
+		loc  = broker.OrderTypeLOC
+		lim  = broker.OrderTypeLimit
+	)
+	for _, tc := range []struct {
+		name      string
+		qty       int
+		closeType broker.OrderType
+		phase     string
+		want      []wantOrder
+	}{
+		{"cycle start at price +X", 0, loc, PhaseFirstHalf, []wantOrder{
+			{buy, loc, 4, 52.5},
+		}},
+		{"first half: ½ unit at avg, ½ one tick under avg +X", 40, loc, PhaseFirstHalf, []wantOrder{
+			{buy, loc, 2, 50}, {buy, loc, 2, 52.49},
+			{sell, loc, 10, 52.5}, {sell, lim, 30, 55},
+		}},
+		{"first half up to half the splits", 100, loc, PhaseFirstHalf, []wantOrder{
+			{buy, loc, 2, 50}, {buy, loc, 2, 52.49},
+			{sell, loc, 25, 52.5}, {sell, lim, 75, 55},
+		}},
+		{"second half: 1 unit at avg", 120, loc, PhaseSecondHalf, []wantOrder{
+			{buy, loc, 5, 50},
+			{sell, loc, 30, 52.5}, {sell, lim, 90, 55},
+		}},
+		{"last unit is the principal left", 198, loc, PhaseSecondHalf, []wantOrder{
+			{buy, loc, 2, 50},
+			{sell, loc, 49, 52.5}, {sell, lim, 149, 55},
+		}},
+		{"¼ rounds down, the target sells the rest", 42, loc, PhaseFirstHalf, []wantOrder{
+			{buy, loc, 2, 50}, {buy, loc, 2, 52.49},
+			{sell, loc, 10, 52.5}, {sell, lim, 32, 55},
+		}},
+		{"limits where on-close orders are not taken", 40, lim, PhaseFirstHalf, []wantOrder{
+			{buy, lim, 2, 50}, {buy, lim, 2, 52.49},
+			{sell, lim, 10, 52.5}, {sell, lim, 30, 55},
+		}},
+	} {
+		cycle := model.CycleStatus{Symbol: "TQQQ", TotalBoughtQty: tc.qty, TotalInvested: float64(tc.qty) * 50}
+		if tc.qty > 0 {
+			cycle.AvgPrice = 50
+		}
+		l := planLadder(info, cycle, settings, 50, tc.closeType)
+		if l.Phase != tc.phase {
+			t.Errorf("%s: phase %s, want %s", tc.name, l.Phase, tc.phase)
+		}
+		var got []wantOrder
+		for _, o := range l.orders() {
+			got = append(got, wantOrder{o.Req.Side, o.Req.Type, o.Req.Qty, o.Req.Price})
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: orders %v, want %v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: order %d = %v, want %v", tc.name, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,662 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// Order sources
+const (
+	SourceDaily     = "DAILY"
+	SourceRebalance = "REBALANCE"
+	SourceManual    = "MANUAL"
+)
+
+// OrderTracker persists every order sent to the broker and follows it until it
+// is filled, cancelled or rejected. TradeLog rows are written from actual fills.
+type OrderTracker struct {
+	DB      *repository.DB
+	Broker  broker.Broker
+	Symbols *symbols.Master
+}
+
+func NewOrderTracker(db *repository.DB, b broker.Broker, syms *symbols.Master) *OrderTracker {
+	return &OrderTracker{DB: db, Broker: b, Symbols: syms}
+}
+
+// openStatuses are the states the poller still has to follow
+var openStatuses = []string{
+	string(broker.OrderStatusReserved),
+	string(broker.OrderStatusSubmitted),
+	string(broker.OrderStatusPartiallyFilled),
+}
+
+// resolve takes the listing exchange from the symbol master and snaps limit
+// prices to the symbol's tick grid
+func (t *OrderTracker) resolve(req broker.OrderRequest) (broker.OrderRequest, error) {
+	info, err := t.Symbols.Lookup(req.Symbol)
+	if err != nil {
+		return req, err
+	}
+	if req.Exchange != "" && req.Exchange != info.Exchange {
+		logWithTime("[ORDERS] ⚠ %s is listed on %s, not %s: using the symbol master", info.Ticker, info.Exchange, req.Exchange)
+	}
+	req.Symbol = info.Ticker
+	req.Exchange = info.Exchange
+	if req.Type.HasLimit() {
+		if p := info.RoundPrice(req.Price, req.Side); p != req.Price {
+			logWithTime("[ORDERS] %s %s limit $%.4f rounded to tick: $%.4f", req.Side, req.Symbol, req.Price, p)
+			req.Price = p
+		}
+	}
+	return req, nil
+}
+
+// Submit places req with the broker and records it. A rejected submission
+// (including an unknown symbol) is recorded too (Status REJECTED) and its
+// error returned.
+func (t *OrderTracker) Submit(ctx context.Context, req broker.OrderRequest, source string) (*model.Order, error) {
+	return t.place(ctx, req, source, false)
+}
+
+// Reserve queues req as a reservation order the broker sends at the next
+// session open. It is recorded as RESERVED and followed by the poller like a
+// live order; failures are recorded the same way as in Submit.
+func (t *OrderTracker) Reserve(ctx context.Context, req broker.OrderRequest, source string) (*model.Order, error) {
+	return t.place(ctx, req, source, true)
+}
+
+func (t *OrderTracker) place(ctx context.Context, req broker.OrderRequest, source string, reserve bool) (*model.Order, error) {
+	req, err := t.resolve(req)
+	now := time.Now()
+	rec := &model.Order{
+		Broker:      t.Broker.Name(),
+		Source:      source,
+		Exchange:    string(req.Exchange),
+		Symbol:      req.Symbol,
+		Side:        string(req.Side),
+		Type:        string(req.Type),
+		Qty:         req.Qty,
+		Price:       req.Price,
+		SubmittedAt: now,
+	}
+	if err == nil && req.Side == broker.SideSell {
+		rec.CostBasis = t.costBasis(ctx, req.Symbol)
+	}
+
+	var (
+		order *broker.Order
+		resv  *broker.Reservation
+	)
+	if err == nil {
+		if reserve {
+			resv, err = t.placeReservation(ctx, req)
+		} else {
+			order, err = t.Broker.PlaceOrder(ctx, req)
+		}
+	}
+	if errors.Is(err, broker.ErrOutcomeUnknown) {
+		// May be live at the broker: keep it out of the REJECTED bucket so the
+		// same order is not placed again until the poller has settled it
+		rec.Status = string(broker.OrderStatusUnknown)
+		rec.Message = err.Error()
+		t.DB.Create(rec)
+		logWithTime("[ORDERS] ⚠ Order #%d %s %d %s @ $%.2f: outcome unknown, matching it against the broker's orders",
+			rec.ID, rec.Side, rec.Qty, rec.Symbol, rec.Price)
+		return rec, err
+	}
+	if err != nil {
+		rec.Status = string(broker.OrderStatusRejected)
+		rec.Message = err.Error()
+		rec.ClosedAt = &now
+		t.DB.Create(rec)
+		return rec, err
+	}
+
+	if resv != nil {
+		rec.ReservationID = resv.ID
+		rec.Status = string(broker.OrderStatusReserved)
+		if !resv.ReservedAt.IsZero() {
+			rec.SubmittedAt = resv.ReservedAt
+		}
+		t.DB.Create(rec)
+		logWithTime("[ORDERS] Recorded reservation #%d (Reservation ID: %s) %s %d %s @ $%.2f",
+			rec.ID, rec.ReservationID, rec.Side, rec.Qty, rec.Symbol, rec.Price)
+		return rec, nil
+	}
+
+	rec.BrokerOrderID = order.ID
+	rec.Status = string(order.Status)
+	if !order.SubmittedAt.IsZero() {
+		rec.SubmittedAt = order.SubmittedAt
+	}
+	t.DB.Create(rec)
+	logWithTime("[ORDERS] Recorded order #%d (Broker ID: %s) %s %d %s @ $%.2f",
+		rec.ID, rec.BrokerOrderID, rec.Side, rec.Qty, rec.Symbol, rec.Price)
+	return rec, nil
+}
+
+// costBasis is the average cost per share of symbol before a sell: the
+// cycle's average when the symbol runs a cycle, else the broker position's.
+// 0 when neither is known.
+func (t *OrderTracker) costBasis(ctx context.Context, symbol string) float64 {
+	var cycle model.CycleStatus
+	if err := t.DB.Where("symbol = ?", symbol).First(&cycle).Error; err == nil && cycle.AvgPrice > 0 {
+		return cycle.AvgPrice
+	}
+	bal, err := t.Broker.GetBalance(ctx)
+	if err != nil {
+		logWithTime("[ORDERS] ⚠ No cost basis for %s: %v", symbol, err)
+		return 0
+	}
+	for _, p := range bal.Positions {
+		if p.Symbol == symbol {
+			return p.AvgPrice
+		}
+	}
+	return 0
+}
+
+// reserver returns the broker's reservation capability
+func (t *OrderTracker) reserver() (broker.Reserver, error) {
+	r, ok := t.Broker.(broker.Reserver)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "%s broker does not take reservation orders", t.Broker.Name())
+	}
+	return r, nil
+}
+
+// Reservations lists the broker's reservation orders made since since,
+// including those placed outside this tracker
+func (t *OrderTracker) Reservations(ctx context.Context, since time.Time) ([]broker.Reservation, error) {
+	r, err := t.reserver()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetReservations(ctx, since)
+}
+
+func (t *OrderTracker) placeReservation(ctx context.Context, req broker.OrderRequest) (*broker.Reservation, error) {
+	r, err := t.reserver()
+	if err != nil {
+		return nil, err
+	}
+	return r.PlaceReservation(ctx, req)
+}
+
+// OpenOrders returns the tracked orders that are not in a final state
+func (t *OrderTracker) OpenOrders() ([]model.Order, error) {
+	var orders []model.Order
+	err := t.DB.Where("status IN ?", openStatuses).Order("submitted_at ASC").Find(&orders).Error
+	return orders, err
+}
+
+// Poll settles the orders with an unknown outcome, then refreshes every open
+// order from the broker
+func (t *OrderTracker) Poll(ctx context.Context) error {
+	t.resolveUnknown(ctx)
+
+	orders, err := t.OpenOrders()
+	if err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	logWithTime("[ORDERS] Polling %d open orders...", len(orders))
+	var live, reserved []*model.Order
+	for i := range orders {
+		if orders[i].Status == string(broker.OrderStatusReserved) {
+			reserved = append(reserved, &orders[i])
+		} else {
+			live = append(live, &orders[i])
+		}
+	}
+	if len(reserved) > 0 {
+		t.refreshReservations(ctx, reserved)
+	}
+	for _, rec := range live {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t.refresh(ctx, rec)
+	}
+	return nil
+}
+
+// unknownGrace is how long an order with an unknown outcome may take to show
+// up at the broker before it is taken as never booked
+const unknownGrace = 10 * time.Minute
+
+// resolveUnknown looks the UNKNOWN orders up in the broker's orders and
+// reservations of their US trade date by symbol, side, quantity and price.
+// A match is followed from then on like any order; an order still missing
+// after unknownGrace is closed as REJECTED. Either way the broker's guard
+// against resending it is lifted.
+func (t *OrderTracker) resolveUnknown(ctx context.Context) {
+	resolver, ok := t.Broker.(broker.OrderResolver)
+	if !ok {
+		return
+	}
+	var recs []model.Order
+	if err := t.DB.Where("status = ?", string(broker.OrderStatusUnknown)).Order("submitted_at ASC").Find(&recs).Error; err != nil || len(recs) == 0 {
+		return
+	}
+
+	since := recs[0].SubmittedAt
+	orders, err := resolver.ListOrders(ctx, since)
+	if err != nil {
+		logWithTime("[ORDERS] ⚠ Order list for %d orders with an unknown outcome failed: %v", len(recs), err)
+		return
+	}
+	// Without the reservation list an order may still be a reservation: it
+	// is matched against the orders but not given up on. Accounts that take
+	// no reservations (VALIDATION) have none to match.
+	var reservations []broker.Reservation
+	listed := true
+	if r, ok := t.Broker.(broker.Reserver); ok {
+		reservations, err = r.GetReservations(ctx, since.Add(-reservationLookback))
+		if err != nil && broker.CategoryOf(err) != broker.CategoryValidation {
+			logWithTime("[ORDERS] ⚠ Reservation list for %d orders with an unknown outcome failed: %v", len(recs), err)
+			listed = false
+		}
+	}
+
+	// Broker orders already followed by another record cannot be a match
+	var tracked []model.Order
+	t.DB.Where("submitted_at >= ? AND (broker_order_id <> '' OR reservation_id <> '')", since.Add(-reservationLookback)).Find(&tracked)
+	taken := make(map[string]bool, len(tracked))
+	for _, o := range tracked {
+		taken[o.BrokerOrderID] = true
+		taken[o.ReservationID] = true
+	}
+
+	byResvID := make(map[string]broker.Reservation, len(reservations))
+	for _, res := range reservations {
+		byResvID[res.ID] = res
+	}
+	for i := range recs {
+		if ctx.Err() != nil {
+			return
+		}
+		rec := &recs[i]
+		req := toBrokerOrder(rec).OrderRequest
+		day := rec.SubmittedAt.In(broker.Eastern()).Format("20060102")
+		matches := func(o broker.OrderRequest, at time.Time) bool {
+			return o.Symbol == rec.Symbol && string(o.Side) == rec.Side && o.Qty == rec.Qty &&
+				math.Abs(o.Price-rec.Price) < 0.00005 && at.In(broker.Eastern()).Format("20060102") == day
+		}
+
+		found := false
+		for _, o := range orders {
+			if taken[o.ID] || !matches(o.OrderRequest, o.SubmittedAt) {
+				continue
+			}
+			taken[o.ID] = true
+			found = true
+			rec.BrokerOrderID = o.ID
+			rec.Status = string(broker.OrderStatusSubmitted)
+			logWithTime("[ORDERS] ✓ Order #%d %s %d %s with an unknown outcome found as order %s",
+				rec.ID, rec.Side, rec.Qty, rec.Symbol, o.ID)
+			t.refresh(ctx, rec)
+			break
+		}
+		if !found {
+			for _, res := range reservations {
+				if taken[res.ID] || !matches(res.OrderRequest, res.ReservedAt) {
+					continue
+				}
+				taken[res.ID] = true
+				found = true
+				rec.ReservationID = res.ID
+				rec.Status = string(broker.OrderStatusReserved)
+				logWithTime("[ORDERS] ✓ Order #%d %s %d %s with an unknown outcome found as reservation %s",
+					rec.ID, rec.Side, rec.Qty, rec.Symbol, res.ID)
+				t.applyReservation(ctx, rec, byResvID)
+				break
+			}
+		}
+
+		now := time.Now()
+		switch {
+		case found:
+		case listed && now.Sub(rec.SubmittedAt) >= unknownGrace:
+			rec.Status = string(broker.OrderStatusRejected)
+			rec.Message = "not booked by the broker: " + rec.Message
+			rec.ClosedAt = &now
+			rec.LastCheckedAt = &now
+			t.DB.Save(rec)
+			logWithTime("[ORDERS] Order #%d %s %d %s with an unknown outcome never reached the broker: %s → %s",
+				rec.ID, rec.Side, rec.Qty, rec.Symbol, broker.OrderStatusUnknown, rec.Status)
+		default:
+			rec.LastCheckedAt = &now
+			t.DB.Save(rec)
+			continue
+		}
+		resolver.ClearOutcomeUnknown(req)
+	}
+}
+
+// reservationLookback widens the reservation inquiry: receipt dates are KST
+const reservationLookback = 24 * time.Hour
+
+// refreshReservations applies the broker's reservation list to recs. Sent
+// reservations become SUBMITTED under their live order number and are
+// refreshed as orders from then on.
+func (t *OrderTracker) refreshReservations(ctx context.Context, recs []*model.Order) {
+	list, err := t.listReservations(ctx, recs)
+	if err != nil {
+		logWithTime("[ORDERS] ⚠ Reservation check failed for %d orders: %v", len(recs), err)
+		now := time.Now()
+		for _, rec := range recs {
+			rec.LastCheckedAt = &now
+			t.DB.Save(rec)
+		}
+		return
+	}
+
+	byID := make(map[string]broker.Reservation, len(list))
+	for _, res := range list {
+		byID[res.ID] = res
+	}
+	for _, rec := range recs {
+		t.applyReservation(ctx, rec, byID)
+	}
+}
+
+// listReservations fetches the broker's reservations back to the oldest of recs
+func (t *OrderTracker) listReservations(ctx context.Context, recs []*model.Order) ([]broker.Reservation, error) {
+	r, err := t.reserver()
+	if err != nil {
+		return nil, err
+	}
+	since := recs[0].SubmittedAt
+	for _, rec := range recs[1:] {
+		if rec.SubmittedAt.Before(since) {
+			since = rec.SubmittedAt
+		}
+	}
+	return r.GetReservations(ctx, since.Add(-reservationLookback))
+}
+
+func (t *OrderTracker) applyReservation(ctx context.Context, rec *model.Order, byID map[string]broker.Reservation) {
+	now := time.Now()
+	rec.LastCheckedAt = &now
+
+	res, ok := byID[rec.ReservationID]
+	if !ok {
+		logWithTime("[ORDERS] ⚠ Reservation %s of order #%d not found at the broker", rec.ReservationID, rec.ID)
+		t.DB.Save(rec)
+		return
+	}
+
+	switch res.Status {
+	case broker.ReservationSent:
+		rec.BrokerOrderID = res.OrderID
+		rec.Status = string(broker.OrderStatusSubmitted)
+		logWithTime("[ORDERS] Order #%d (%s) %s %s: reservation sent as order %s",
+			rec.ID, rec.ReservationID, rec.Side, rec.Symbol, rec.BrokerOrderID)
+		t.refresh(ctx, rec)
+		return
+	case broker.ReservationCancelled:
+		rec.Status = string(broker.OrderStatusCancelled)
+	case broker.ReservationRejected:
+		rec.Status = string(broker.OrderStatusRejected)
+		rec.Message = res.Message
+	default:
+		t.DB.Save(rec)
+		return
+	}
+
+	rec.ClosedAt = &now
+	t.DB.Save(rec)
+	logWithTime("[ORDERS] Order #%d (%s) %s %s: %s → %s %s",
+		rec.ID, rec.ReservationID, rec.Side, rec.Symbol, broker.OrderStatusReserved, rec.Status, rec.Message)
+}
+
+// refresh applies the broker's view of rec and logs new fills
+func (t *OrderTracker) refresh(ctx context.Context, rec *model.Order) {
+	now := time.Now()
+	rec.LastCheckedAt = &now
+
+	status, err := t.Broker.GetOrderStatus(ctx, rec.BrokerOrderID)
+	if err != nil {
+		logWithTime("[ORDERS] ⚠ Status check failed for order #%d (%s): %v", rec.ID, rec.BrokerOrderID, err)
+		t.DB.Save(rec)
+		return
+	}
+
+	// New fills since the last poll
+	if delta := status.FilledQty - rec.FilledQty; delta > 0 {
+		prevAmount := rec.AvgFillPrice * float64(rec.FilledQty)
+		newAmount := status.AvgFillPrice * float64(status.FilledQty)
+		fillPrice := (newAmount - prevAmount) / float64(delta)
+		t.recordFill(rec, delta, fillPrice)
+	}
+
+	prevStatus := rec.Status
+	rec.FilledQty = status.FilledQty
+	rec.AvgFillPrice = status.AvgFillPrice
+	rec.Status = string(status.Status)
+	if !isOpenStatus(rec.Status) && rec.ClosedAt == nil {
+		rec.ClosedAt = &now
+	}
+	t.DB.Save(rec)
+
+	if prevStatus != rec.Status {
+		logWithTime("[ORDERS] Order #%d (%s) %s %s: %s → %s (Filled %d/%d @ $%.2f)",
+			rec.ID, rec.BrokerOrderID, rec.Side, rec.Symbol, prevStatus, rec.Status,
+			rec.FilledQty, rec.Qty, rec.AvgFillPrice)
+	}
+}
+
+// OpenOrdersForSymbol returns the open tracked orders of symbol
+func (t *OrderTracker) OpenOrdersForSymbol(symbol string) ([]model.Order, error) {
+	var orders []model.Order
+	err := t.DB.Where("status IN ? AND symbol = ?", openStatuses, symbol).Order("submitted_at ASC").Find(&orders).Error
+	return orders, err
+}
+
+// Cancel asks the broker to cancel rec and refreshes its state. Brokers may
+// confirm asynchronously, in which case the poller picks up the final status.
+func (t *OrderTracker) Cancel(ctx context.Context, rec *model.Order) error {
+	if !isOpenStatus(rec.Status) {
+		return broker.NewError(broker.CategoryValidation, "order #%d is not open (%s)", rec.ID, rec.Status)
+	}
+
+	if rec.Status == string(broker.OrderStatusReserved) {
+		return t.cancelReservation(ctx, rec)
+	}
+
+	logWithTime("[ORDERS] Cancelling order #%d (%s) %s %d %s @ $%.2f",
+		rec.ID, rec.BrokerOrderID, rec.Side, rec.Qty, rec.Symbol, rec.Price)
+	if err := t.Broker.CancelOrder(ctx, toBrokerOrder(rec)); err != nil {
+		rec.Message = "cancel failed: " + err.Error()
+		t.DB.Save(rec)
+		return err
+	}
+
+	rec.Message = "cancel requested"
+	t.refresh(ctx, rec)
+	return nil
+}
+
+// cancelReservation withdraws a reservation before it is sent. The broker
+// confirms synchronously, so rec is closed right away.
+func (t *OrderTracker) cancelReservation(ctx context.Context, rec *model.Order) error {
+	r, err := t.reserver()
+	if err != nil {
+		return err
+	}
+
+	logWithTime("[ORDERS] Cancelling reservation #%d (%s) %s %d %s @ $%.2f",
+		rec.ID, rec.ReservationID, rec.Side, rec.Qty, rec.Symbol, rec.Price)
+	res := broker.Reservation{ID: rec.ReservationID, OrderRequest: toBrokerOrder(rec).OrderRequest}
+	if err := r.CancelReservation(ctx, res); err != nil {
+		rec.Message = "cancel failed: " + err.Error()
+		t.DB.Save(rec)
+		return err
+	}
+
+	now := time.Now()
+	rec.Status = string(broker.OrderStatusCancelled)
+	rec.Message = "reservation cancelled"
+	rec.ClosedAt = &now
+	t.DB.Save(rec)
+	return nil
+}
+
+// CancelAllForSymbol cancels the open orders of symbol placed by source (every
+// source when empty) and returns how many were cancelled. Strategies pass
+// their own source so they never withdraw the orders of another one.
+func (t *OrderTracker) CancelAllForSymbol(ctx context.Context, symbol, source string) (int, error) {
+	orders, err := t.OpenOrdersForSymbol(symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	var lastErr error
+	for i := range orders {
+		if source != "" && orders[i].Source != source {
+			continue
+		}
+		if err := t.Cancel(ctx, &orders[i]); err != nil {
+			logWithTime("[ORDERS] ✗ Failed to cancel order #%d: %v", orders[i].ID, err)
+			lastErr = err
+			continue
+		}
+		cancelled++
+	}
+	return cancelled, lastErr
+}
+
+// Amend changes quantity and price of rec. When the broker books the revision
+// as a new order, the replacement is tracked as its own record and returned.
+func (t *OrderTracker) Amend(ctx context.Context, rec *model.Order, qty int, price float64) (*model.Order, error) {
+	if !isOpenStatus(rec.Status) {
+		return nil, broker.NewError(broker.CategoryValidation, "order #%d is not open (%s)", rec.ID, rec.Status)
+	}
+	if rec.Status == string(broker.OrderStatusReserved) {
+		return nil, broker.NewError(broker.CategoryValidation, "reservation #%d cannot be amended: cancel it and reserve again", rec.ID)
+	}
+	info, err := t.Symbols.Lookup(rec.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	price = info.RoundPrice(price, broker.Side(rec.Side))
+
+	logWithTime("[ORDERS] Amending order #%d (%s): %d @ $%.2f → %d @ $%.2f",
+		rec.ID, rec.BrokerOrderID, rec.Qty, rec.Price, qty, price)
+	amended, err := t.Broker.AmendOrder(ctx, toBrokerOrder(rec), qty, price)
+	if err != nil {
+		rec.Message = "amend failed: " + err.Error()
+		t.DB.Save(rec)
+		return nil, err
+	}
+
+	if amended.ID == rec.BrokerOrderID {
+		rec.Qty = qty
+		rec.Price = price
+		rec.Message = "amended"
+		t.DB.Save(rec)
+		return rec, nil
+	}
+
+	replacement := &model.Order{
+		BrokerOrderID: amended.ID,
+		Broker:        rec.Broker,
+		Source:        rec.Source,
+		Exchange:      rec.Exchange,
+		Symbol:        rec.Symbol,
+		Side:          rec.Side,
+		Type:          rec.Type,
+		Qty:           qty,
+		Price:         price,
+		CostBasis:     rec.CostBasis,
+		Status:        string(amended.Status),
+		Message:       fmt.Sprintf("amends order #%d", rec.ID),
+		SubmittedAt:   amended.SubmittedAt,
+	}
+	t.DB.Create(replacement)
+
+	rec.Message = fmt.Sprintf("amended by order #%d", replacement.ID)
+	t.refresh(ctx, rec)
+	return replacement, nil
+}
+
+func toBrokerOrder(rec *model.Order) broker.Order {
+	return broker.Order{
+		ID: rec.BrokerOrderID,
+		OrderRequest: broker.OrderRequest{
+			Exchange: broker.Exchange(rec.Exchange),
+			Symbol:   rec.Symbol,
+			Side:     broker.Side(rec.Side),
+			Type:     broker.OrderType(rec.Type),
+			Qty:      rec.Qty,
+			Price:    rec.Price,
+		},
+		Status:       broker.OrderStatus(rec.Status),
+		FilledQty:    rec.FilledQty,
+		AvgFillPrice: rec.AvgFillPrice,
+		SubmittedAt:  rec.SubmittedAt,
+	}
+}
+
+// recordFill writes a TradeLog for qty shares of rec filled at price
+func (t *OrderTracker) recordFill(rec *model.Order, qty int, price float64) {
+	entry := model.TradeLog{
+		Date:    time.Now(),
+		Symbol:  rec.Symbol,
+		Side:    rec.Side,
+		Type:    rec.Type,
+		Qty:     qty,
+		Price:   price,
+		Amount:  float64(qty) * price,
+		OrderID: rec.ID,
+	}
+
+	if rec.Side == string(broker.SideSell) {
+		basis := rec.CostBasis
+		if basis == 0 {
+			// Recorded before orders kept their cost basis
+			var cycle model.CycleStatus
+			if err := t.DB.Where("symbol = ?", rec.Symbol).First(&cycle).Error; err == nil {
+				basis = cycle.AvgPrice
+			}
+		}
+		if basis > 0 {
+			entry.Profit = (price - basis) * float64(qty)
+		}
+	}
+
+	t.DB.Create(&entry)
+	logWithTime("[ORDERS] ✓ Fill: %s %d %s @ $%.2f (Order #%d)", rec.Side, qty, rec.Symbol, price, rec.ID)
+}
+
+// haltsOrdering reports failures after which no further order of the same run can succeed
+func haltsOrdering(cat broker.ErrorCategory) bool {
+	switch cat {
+	case broker.CategoryAuth, broker.CategoryMarketClosed, broker.CategoryRateLimit, broker.CategoryUnavailable:
+		return true
+	}
+	return false
+}
+
+func isOpenStatus(s string) bool {
+	for _, open := range openStatuses {
+		if s == open {
+			return true
+		}
+	}
+	return false
+}
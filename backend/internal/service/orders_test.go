@@ -0,0 +1,119 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// newKISTracker tracks orders against a fake KIS account
+func newKISTracker(t *testing.T, sc kisfake.Scenario) (*OrderTracker, *kisfake.Server) {
+	t.Helper()
+	srv := kisfake.NewServer(sc)
+	t.Cleanup(srv.Close)
+	client, err := kis.NewClient(&config.Config{
+		KisAppKey:     "appkey123456",
+		KisAppSecret:  "secret123456",
+		KisAccountNum: "12345678-01",
+		KisBaseURL:    srv.URL,
+		KisMode:       "real",
+		KisWSURL:      srv.WSURL(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := repository.NewDB(filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewOrderTracker(db, kis.NewBroker(client), symbols.Default()), srv
+}
+
+// An order whose response was lost after KIS booked it is found by the
+// poller, followed to its fill and no longer blocks the same order
+func TestUnknownOrderResolvedFromBrokerOrders(t *testing.T) {
+	tr, srv := newKISTracker(t, kisfake.Scenario{
+		Cash:   10000,
+		Prices: map[string]float64{"TQQQ": 50},
+		Faults: []kisfake.Fault{{Path: "/trading/order", Times: 1, Status: 500, AfterApply: true}},
+	})
+	ctx := t.Context()
+	req := broker.OrderRequest{Symbol: "TQQQ", Side: broker.SideBuy, Type: broker.OrderTypeLimit, Qty: 10, Price: 49}
+
+	rec, err := tr.Submit(ctx, req, SourceManual)
+	if rec.Status != string(broker.OrderStatusUnknown) {
+		t.Fatalf("lost response: status %s, err %v", rec.Status, err)
+	}
+	if _, err := tr.Submit(ctx, req, SourceManual); broker.CategoryOf(err) != broker.CategoryConflict {
+		t.Fatalf("resending the unknown order: err = %v, want CONFLICT", err)
+	}
+	booked := srv.Orders()
+	if len(booked) != 1 {
+		t.Fatalf("fake booked %d orders, want 1", len(booked))
+	}
+
+	if err := srv.Fill(booked[0].OrderNo, 10, 48.9); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tr.DB.First(rec, rec.ID)
+	if rec.Status != string(broker.OrderStatusFilled) || rec.BrokerOrderID != booked[0].OrderNo || rec.FilledQty != 10 {
+		t.Fatalf("after the poll: %s %s %d/%d", rec.Status, rec.BrokerOrderID, rec.FilledQty, rec.Qty)
+	}
+	var fills int64
+	tr.DB.Model(&model.TradeLog{}).Where("order_id = ?", rec.ID).Count(&fills)
+	if fills != 1 {
+		t.Errorf("%d trade logs for the resolved order, want 1", fills)
+	}
+
+	if again, err := tr.Submit(ctx, req, SourceManual); err != nil || again.Status != string(broker.OrderStatusSubmitted) {
+		t.Errorf("same order after the resolution: %v %v", again.Status, err)
+	}
+}
+
+// An order KIS never booked is closed once the grace period is over
+func TestUnknownOrderMissingAtBrokerRejected(t *testing.T) {
+	tr, srv := newKISTracker(t, kisfake.Scenario{
+		Cash:   10000,
+		Prices: map[string]float64{"TQQQ": 50},
+		Faults: []kisfake.Fault{{Path: "/trading/order", Times: 1, Status: 500}},
+	})
+	ctx := t.Context()
+	req := broker.OrderRequest{Symbol: "TQQQ", Side: broker.SideBuy, Type: broker.OrderTypeLimit, Qty: 10, Price: 49}
+
+	rec, _ := tr.Submit(ctx, req, SourceManual)
+	if rec.Status != string(broker.OrderStatusUnknown) || len(srv.Orders()) != 0 {
+		t.Fatalf("failed submission: status %s, %d booked", rec.Status, len(srv.Orders()))
+	}
+
+	// Within the grace period it stays unknown
+	if err := tr.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tr.DB.First(rec, rec.ID)
+	if rec.Status != string(broker.OrderStatusUnknown) {
+		t.Fatalf("within the grace period: %s", rec.Status)
+	}
+
+	tr.DB.Model(rec).Update("submitted_at", time.Now().Add(-unknownGrace))
+	if err := tr.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tr.DB.First(rec, rec.ID)
+	if rec.Status != string(broker.OrderStatusRejected) || rec.ClosedAt == nil {
+		t.Fatalf("after the grace period: %s", rec.Status)
+	}
+	if _, err := tr.Submit(ctx, req, SourceManual); err != nil {
+		t.Errorf("same order after the rejection: %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// StrategyPlugin is a trading strategy the scheduler and the API run by name.
+// Plug-ins share the Strategy's broker, order tracker and DB.
+type StrategyPlugin interface {
+	Name() string
+	Describe() string
+	// DefaultSchedule is the cron spec (ET) used when the config has none.
+	// at is SCHEDULE_TIME, or RESERVE_TIME for reserve placement (HH:MM).
+	DefaultSchedule(at string, placement Placement) string
+	// Evaluate proposes the orders a run would place, without placing them
+	Evaluate(ctx context.Context, cfg model.StrategyConfig) (*Proposal, error)
+	// Execute runs the strategy; a dry run only logs the orders
+	Execute(ctx context.Context, cfg model.StrategyConfig, placement Placement, dryRun bool) error
+	// State describes what the strategy is currently holding or tracking
+	State(ctx context.Context, cfg model.StrategyConfig) (interface{}, error)
+}
+
+// ParamsValidator is implemented by plug-ins that take params
+type ParamsValidator interface {
+	ValidateParams(params string) error
+}
+
+// SymbolClaimer is implemented by plug-ins that trade their own set of
+// symbols. A plug-in sizes against the whole broker position of its symbols
+// and cancels its open orders before ordering, so no two enabled plug-ins
+// may claim the same symbol.
+type SymbolClaimer interface {
+	ClaimedSymbols(cfg model.StrategyConfig) ([]string, error)
+}
+
+// ProposedOrder is one order a strategy would place
+type ProposedOrder struct {
+	Symbol string           `json:"symbol"`
+	Side   broker.Side      `json:"side"`
+	Type   broker.OrderType `json:"type"`
+	Qty    int              `json:"qty"`
+	Price  float64          `json:"price"`
+	Reason string           `json:"reason"`
+}
+
+// Proposal is the outcome of Evaluate
+type Proposal struct {
+	Strategy string          `json:"strategy"`
+	Orders   []ProposedOrder `json:"orders"`
+	Summary  string          `json:"summary"`
+	Detail   interface{}     `json:"detail,omitempty"` // Strategy specific, e.g. the rebalance plan
+}
+
+// Registry holds the strategy plug-ins in registration order
+type Registry struct {
+	plugins []StrategyPlugin
+	enabled map[string]bool // Default of a new config
+}
+
+func NewRegistry() *Registry {
+	return &Registry{enabled: make(map[string]bool)}
+}
+
+// Register adds p; enabled is its state until a config is saved
+func (r *Registry) Register(p StrategyPlugin, enabled bool) {
+	if _, ok := r.Get(p.Name()); ok {
+		panic("strategy plug-in registered twice: " + p.Name())
+	}
+	r.plugins = append(r.plugins, p)
+	r.enabled[p.Name()] = enabled
+}
+
+func (r *Registry) Get(name string) (StrategyPlugin, bool) {
+	for _, p := range r.plugins {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) List() []StrategyPlugin {
+	return append([]StrategyPlugin(nil), r.plugins...)
+}
+
+// ErrUnknownStrategy is returned for a name no plug-in is registered under
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+func (s *Strategy) plugin(name string) (StrategyPlugin, error) {
+	p, ok := s.Plugins.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+	return p, nil
+}
+
+// StrategyConfig returns the stored config of name, creating the default one
+func (s *Strategy) StrategyConfig(name string) (model.StrategyConfig, error) {
+	if _, err := s.plugin(name); err != nil {
+		return model.StrategyConfig{}, err
+	}
+	var cfg model.StrategyConfig
+	err := s.DB.Where("name = ?", name).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		cfg = model.StrategyConfig{Name: name, Enabled: s.Plugins.enabled[name]}
+		err = s.DB.Create(&cfg).Error
+	}
+	return cfg, err
+}
+
+// StrategyConfigs returns the config of every registered plug-in
+func (s *Strategy) StrategyConfigs() ([]model.StrategyConfig, error) {
+	var out []model.StrategyConfig
+	for _, p := range s.Plugins.List() {
+		cfg, err := s.StrategyConfig(p.Name())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cfg)
+	}
+	return out, nil
+}
+
+// SaveStrategyConfig validates and stores cfg
+func (s *Strategy) SaveStrategyConfig(cfg model.StrategyConfig) (model.StrategyConfig, error) {
+	p, err := s.plugin(cfg.Name)
+	if err != nil {
+		return model.StrategyConfig{}, err
+	}
+	stored, err := s.StrategyConfig(cfg.Name)
+	if err != nil {
+		return stored, err
+	}
+	cfg.Schedule = strings.TrimSpace(cfg.Schedule)
+	if cfg.Schedule != "" {
+		if _, err := cron.ParseStandard(cfg.Schedule); err != nil {
+			return stored, broker.NewError(broker.CategoryValidation, "invalid schedule %q: %v", cfg.Schedule, err)
+		}
+	}
+	if cfg.Placement != "" {
+		placement, err := ParsePlacement(cfg.Placement)
+		if err != nil {
+			return stored, broker.NewError(broker.CategoryValidation, "%v", err)
+		}
+		if _, ok := s.Broker.(broker.Reserver); !ok && placement == PlacementReserve {
+			return stored, broker.NewError(broker.CategoryValidation, "%s broker does not take reservation orders", s.Broker.Name())
+		}
+		cfg.Placement = string(placement)
+	}
+	if cfg.Params != "" && !json.Valid([]byte(cfg.Params)) {
+		return stored, broker.NewError(broker.CategoryValidation, "params of %s are not valid JSON", cfg.Name)
+	}
+
+	if v, ok := p.(ParamsValidator); ok {
+		if err := v.ValidateParams(cfg.Params); err != nil {
+			return stored, broker.NewError(broker.CategoryValidation, "params of %s: %v", cfg.Name, err)
+		}
+	}
+	if c, ok := p.(SymbolClaimer); ok && cfg.Enabled {
+		syms, err := c.ClaimedSymbols(cfg)
+		if err != nil {
+			return stored, err
+		}
+		if err := s.CheckSymbolClaims(cfg.Name, syms); err != nil {
+			return stored, err
+		}
+	}
+
+	stored.Enabled = cfg.Enabled
+	stored.Schedule = cfg.Schedule
+	stored.Placement = cfg.Placement
+	stored.Params = cfg.Params
+	if err := s.DB.Save(&stored).Error; err != nil {
+		return stored, err
+	}
+	logWithTime("[STRATEGY] %s config saved (enabled: %v, schedule: %q, placement: %q)",
+		stored.Name, stored.Enabled, stored.Schedule, stored.Placement)
+	return stored, nil
+}
+
+// claims maps each symbol of the enabled plug-ins other than except to the
+// plug-in trading it
+func (s *Strategy) claims(except string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, p := range s.Plugins.List() {
+		c, ok := p.(SymbolClaimer)
+		if !ok || p.Name() == except {
+			continue
+		}
+		cfg, err := s.StrategyConfig(p.Name())
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Enabled {
+			continue
+		}
+		syms, err := c.ClaimedSymbols(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, sym := range syms {
+			out[sym] = p.Name()
+		}
+	}
+	return out, nil
+}
+
+// CheckSymbolClaims rejects symbols of strategy name that another enabled
+// strategy already trades
+func (s *Strategy) CheckSymbolClaims(name string, symbols []string) error {
+	claimed, err := s.claims(name)
+	if err != nil {
+		return err
+	}
+	var clash []string
+	for _, sym := range symbols {
+		if other, ok := claimed[sym]; ok {
+			clash = append(clash, fmt.Sprintf("%s (%s)", sym, other))
+		}
+	}
+	if len(clash) > 0 {
+		return broker.NewError(broker.CategoryValidation,
+			"%s: %s already traded by another enabled strategy; remove the symbol from one of them",
+			name, strings.Join(clash, ", "))
+	}
+	return nil
+}
+
+// PlacementFor is the placement of a strategy: its config, else ORDER_PLACEMENT.
+// Reserve falls back to live on a broker without reservation orders.
+func (s *Strategy) PlacementFor(cfg model.StrategyConfig) Placement {
+	if cfg.Placement == "" {
+		return s.Placement
+	}
+	p, err := ParsePlacement(cfg.Placement)
+	if err != nil {
+		return s.Placement
+	}
+	if _, ok := s.Broker.(broker.Reserver); !ok && p == PlacementReserve {
+		return PlacementLive
+	}
+	return p
+}
+
+// Schedule is the cron spec of a strategy; at is SCHEDULE_TIME or RESERVE_TIME
+// by the strategy's placement
+func (s *Strategy) Schedule(cfg model.StrategyConfig, scheduleTime, reserveTime string) (string, error) {
+	if cfg.Schedule != "" {
+		return cfg.Schedule, nil
+	}
+	p, err := s.plugin(cfg.Name)
+	if err != nil {
+		return "", err
+	}
+	placement := s.PlacementFor(cfg)
+	at := scheduleTime
+	if placement == PlacementReserve {
+		at = reserveTime
+	}
+	return p.DefaultSchedule(at, placement), nil
+}
+
+// EvaluateStrategy proposes the orders of name
+func (s *Strategy) EvaluateStrategy(ctx context.Context, name string) (*Proposal, error) {
+	p, err := s.plugin(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.StrategyConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := p.Evaluate(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	prop.Strategy = name
+	return prop, nil
+}
+
+// RunStrategy executes name with its configured placement
+func (s *Strategy) RunStrategy(ctx context.Context, name string, dryRun bool) error {
+	p, err := s.plugin(name)
+	if err != nil {
+		return err
+	}
+	cfg, err := s.StrategyConfig(name)
+	if err != nil {
+		return err
+	}
+	if c, ok := p.(SymbolClaimer); ok {
+		// Configs saved before symbols were claimed may still overlap
+		syms, err := c.ClaimedSymbols(cfg)
+		if err != nil {
+			return err
+		}
+		if err := s.CheckSymbolClaims(name, syms); err != nil {
+			return err
+		}
+	}
+	placement := s.PlacementFor(cfg)
+	logWithTime("[STRATEGY] ▶ Running %s (placement: %s, dry run: %v)", name, placement, dryRun)
+	return p.Execute(ctx, cfg, placement, dryRun)
+}
+
+// StrategyState describes the current state of name
+func (s *Strategy) StrategyState(ctx context.Context, name string) (interface{}, error) {
+	p, err := s.plugin(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.StrategyConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.State(ctx, cfg)
+}
+
+// cronTime splits HH:MM, defaulting to the SCHEDULE_TIME default 15:40
+func cronTime(at string) (string, string) {
+	parts := strings.Split(at, ":")
+	if len(parts) != 2 {
+		logWithTime("[SCHEDULER] ⚠ Invalid schedule time format (%s), defaulting to 15:40", at)
+		return "40", "15"
+	}
+	return parts[1], parts[0]
+}
+
+// scheduleLeadMin is how long a daily run syncs, cancels and quotes before
+// its on-close orders go out
+const scheduleLeadMin = 5
+
+// CheckScheduleTime rejects a SCHEDULE_TIME (HH:MM ET) at which the daily
+// run would reach the broker outside the on-close order window: before the
+// pre-market opens, or after the earliest cutoff (NYSE 15:50), when every
+// LOC/MOC order of that venue is refused
+func CheckScheduleTime(at string) error {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("invalid SCHEDULE_TIME %q: want HH:MM (ET)", at)
+	}
+	latest := broker.EarliestClosingCutoff() - scheduleLeadMin
+	if m := t.Hour()*60 + t.Minute(); m < broker.PreMarketOpenMin || m > latest {
+		return fmt.Errorf("SCHEDULE_TIME %s is outside the on-close order window: schedule the daily run between %02d:%02d and %02d:%02d ET",
+			at, broker.PreMarketOpenMin/60, broker.PreMarketOpenMin%60, latest/60, latest%60)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/paper"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
+)
+
+// noCandles is a market data source without any candles
+type noCandles struct{}
+
+func (noCandles) QueryCandles(string, time.Time, time.Time) ([]market.Candle, error) {
+	return nil, nil
+}
+
+func newTestStrategy(t *testing.T) *Strategy {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := repository.NewDB(filepath.Join(dir, "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := paper.NewBroker(noCandles{}, filepath.Join(dir, "ledger.json"), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStrategy(db, b, symbols.Default())
+}
+
+func TestCheckScheduleTime(t *testing.T) {
+	for at, ok := range map[string]bool{
+		"15:40": true,  // Default
+		"15:45": true,  // Last minute with the lead before the NYSE cutoff
+		"09:00": true,  // Pre-market
+		"15:50": false, // NYSE cutoff: every LOC of an NYSE listing is refused
+		"15:59": false,
+		"03:30": false, // Before the pre-market
+		"3pm":   false,
+	} {
+		if err := CheckScheduleTime(at); (err == nil) != ok {
+			t.Errorf("CheckScheduleTime(%q) = %v, want ok %v", at, err, ok)
+		}
+	}
+}
+
+// The default rebalance portfolio holds TQQQ, the default cycle symbol
+func TestEnablingOverlappingStrategiesRejected(t *testing.T) {
+	s := newTestStrategy(t)
+
+	// The default cycle symbol is not in the default weights
+	if _, err := s.SaveStrategyConfig(model.StrategyConfig{Name: PluginInfinite, Enabled: true}); err != nil {
+		t.Fatalf("enabling infinite next to the default rebalance: %v", err)
+	}
+	if err := s.CheckCycleSymbols([]string{"TQQQ"}); broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("cycle symbol TQQQ of the default rebalance: err = %v", err)
+	}
+
+	// Rebalance weights may not take a cycle symbol either
+	_, err := s.SaveStrategyConfig(model.StrategyConfig{
+		Name: PluginRebalance, Enabled: true,
+		Params: `{"weights":{"SOXL":0.5,"SCHD":0.5}}`,
+	})
+	if broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("rebalance weights on the cycle symbol SOXL: err = %v", err)
+	}
+
+	// With infinite disabled the cycle symbols are free
+	if _, err := s.SaveStrategyConfig(model.StrategyConfig{Name: PluginInfinite}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckCycleSymbols([]string{"TQQQ"}); err != nil {
+		t.Errorf("cycle symbols while infinite is disabled: %v", err)
+	}
+
+	// Without TQQQ in the weights it may cycle
+	if _, err := s.SaveStrategyConfig(model.StrategyConfig{
+		Name: PluginRebalance, Enabled: true,
+		Params: `{"weights":{"SCHD":0.5,"PFIX":0.25,"TMF":0.25}}`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveStrategyConfig(model.StrategyConfig{Name: PluginInfinite, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckCycleSymbols([]string{"TQQQ"}); err != nil {
+		t.Errorf("cycle symbol TQQQ off the weights: %v", err)
+	}
+	if err := s.CheckCycleSymbols([]string{"TQQQ", "SCHD"}); broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("cycle symbol SCHD of the rebalance: err = %v", err)
+	}
+	s.DB.Create(&model.UserSettings{Symbols: "TQQQ"})
+
+	// A custom plan may not trade a cycle symbol
+	err = s.executeRebalanceItems(t.Context(), []RebalanceItem{{Symbol: "TQQQ", Action: "BUY", ActionQty: 1}}, PlacementLive, true)
+	if broker.CategoryOf(err) != broker.CategoryValidation {
+		t.Errorf("rebalancing TQQQ while it cycles: err = %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+// ProfitBook imports the broker's realized profit and trade history into
+// RealizedProfit/BrokerTrade and reconciles them with the TradeLog written
+// from our own fills.
+type ProfitBook struct {
+	DB     *repository.DB
+	Broker broker.Broker
+}
+
+func NewProfitBook(db *repository.DB, b broker.Broker) *ProfitBook {
+	return &ProfitBook{DB: db, Broker: b}
+}
+
+// reconcileTolerance is the USD difference between the broker's profit and
+// ours (less the broker's fees) still counted as reconciled: the TradeLog
+// books sells against the cycle average, which may drift by rounding.
+const reconcileTolerance = 1.0
+
+// ImportResult reports what an import stored
+type ImportResult struct {
+	From    string `json:"from"` // YYYY-MM-DD
+	To      string `json:"to"`
+	Profits int    `json:"profits"` // RealizedProfit rows
+	Trades  int    `json:"trades"`  // BrokerTrade rows
+}
+
+func (p *ProfitBook) reporter() (broker.ProfitReporter, error) {
+	r, ok := p.Broker.(broker.ProfitReporter)
+	if !ok {
+		return nil, broker.NewError(broker.CategoryValidation, "%s broker does not report realized profit", p.Broker.Name())
+	}
+	return r, nil
+}
+
+// Import replaces the imported rows of the trade dates from..to with the
+// broker's current report, so re-running it over the same range is safe.
+func (p *ProfitBook) Import(ctx context.Context, from, to time.Time) (*ImportResult, error) {
+	rep, err := p.reporter()
+	if err != nil {
+		return nil, err
+	}
+	start, end := from.Format("20060102"), to.Format("20060102")
+	res := &ImportResult{From: from.Format("2006-01-02"), To: to.Format("2006-01-02")}
+	logWithTime("[PNL] Importing realized profit and trades for %s ~ %s...", res.From, res.To)
+
+	pls, err := rep.GetRealizedPL(ctx, start, end)
+	if err != nil {
+		logWithTime("[PNL] ✗ Realized profit inquiry failed: %v", err)
+		return nil, err
+	}
+	execs, err := rep.GetExecutions(ctx, start, end)
+	if err != nil {
+		logWithTime("[PNL] ✗ Trade history inquiry failed: %v", err)
+		return nil, err
+	}
+
+	name := p.Broker.Name()
+	profits := make([]model.RealizedProfit, 0, len(pls))
+	for _, pl := range pls {
+		profits = append(profits, model.RealizedProfit{
+			Broker:       name,
+			TradeDate:    isoDate(pl.Date),
+			Symbol:       pl.Symbol,
+			Exchange:     string(pl.Exchange),
+			Qty:          pl.Qty,
+			AvgBuyPrice:  pl.AvgBuyPrice,
+			AvgSellPrice: pl.AvgSellPrice,
+			BuyAmount:    pl.BuyAmount,
+			SellAmount:   pl.SellAmount,
+			Fee:          pl.Fee,
+			Profit:       pl.Profit,
+			ExchangeRate: pl.ExchangeRate,
+		})
+	}
+	trades := make([]model.BrokerTrade, 0, len(execs))
+	for _, e := range execs {
+		trades = append(trades, model.BrokerTrade{
+			Broker:     name,
+			TradeDate:  isoDate(e.Date),
+			SettleDate: isoDate(e.SettleDate),
+			Symbol:     e.Symbol,
+			Side:       string(e.Side),
+			Qty:        e.Qty,
+			Price:      e.Price,
+			Amount:     e.Amount,
+			Fee:        e.Fee,
+		})
+	}
+
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		inRange := tx.Unscoped().Where("broker = ? AND trade_date BETWEEN ? AND ?", name, res.From, res.To)
+		if err := inRange.Delete(&model.RealizedProfit{}).Error; err != nil {
+			return err
+		}
+		inRange = tx.Unscoped().Where("broker = ? AND trade_date BETWEEN ? AND ?", name, res.From, res.To)
+		if err := inRange.Delete(&model.BrokerTrade{}).Error; err != nil {
+			return err
+		}
+		if len(profits) > 0 {
+			if err := tx.CreateInBatches(profits, 100).Error; err != nil {
+				return err
+			}
+		}
+		if len(trades) > 0 {
+			if err := tx.CreateInBatches(trades, 100).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logWithTime("[PNL] ✗ Failed to store the import: %v", err)
+		return nil, err
+	}
+
+	res.Profits, res.Trades = len(profits), len(trades)
+	logWithTime("[PNL] ✓ Imported %d realized profit rows and %d trades", res.Profits, res.Trades)
+	return res, nil
+}
+
+// isoDate turns a broker YYYYMMDD date into YYYY-MM-DD
+func isoDate(s string) string {
+	if len(s) != 8 {
+		return s
+	}
+	return s[:4] + "-" + s[4:6] + "-" + s[6:]
+}
+
+// PnLRow compares the broker's realized P&L of one month or symbol with the
+// TradeLog. Broker figures come from the last import.
+type PnLRow struct {
+	Key             string  `json:"key"` // YYYY-MM or symbol
+	BrokerBoughtQty int     `json:"broker_bought_qty"`
+	BrokerSoldQty   int     `json:"broker_sold_qty"`
+	BrokerProfit    float64 `json:"broker_profit"` // After fees
+	BrokerFee       float64 `json:"broker_fee"`
+	LocalBoughtQty  int     `json:"local_bought_qty"`
+	LocalSoldQty    int     `json:"local_sold_qty"`
+	LocalProfit     float64 `json:"local_profit"` // Before fees
+	Diff            float64 `json:"diff"`         // BrokerProfit - (LocalProfit - BrokerFee)
+	Reconciled      bool    `json:"reconciled"`
+}
+
+// PnLSummary is realized P&L over a date range, by month and by symbol
+type PnLSummary struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Broker     string     `json:"broker"`
+	Total      PnLRow     `json:"total"`
+	ByMonth    []PnLRow   `json:"by_month"`
+	BySymbol   []PnLRow   `json:"by_symbol"`
+	ImportedAt *time.Time `json:"imported_at"` // Latest import in the range, nil if none
+}
+
+// Summary aggregates imported broker figures and the TradeLog over the
+// trade dates from..to (TradeLog rows are dated in ET)
+func (p *ProfitBook) Summary(from, to time.Time) (*PnLSummary, error) {
+	sum := &PnLSummary{
+		From:   from.Format("2006-01-02"),
+		To:     to.Format("2006-01-02"),
+		Broker: p.Broker.Name(),
+	}
+
+	var profits []model.RealizedProfit
+	if err := p.DB.Where("broker = ? AND trade_date BETWEEN ? AND ?", sum.Broker, sum.From, sum.To).
+		Find(&profits).Error; err != nil {
+		return nil, err
+	}
+	var trades []model.BrokerTrade
+	if err := p.DB.Where("broker = ? AND trade_date BETWEEN ? AND ?", sum.Broker, sum.From, sum.To).
+		Find(&trades).Error; err != nil {
+		return nil, err
+	}
+	// Server-local timestamps: widen the window, then filter by ET date
+	var logs []model.TradeLog
+	if err := p.DB.Where("date BETWEEN ? AND ?", from.AddDate(0, 0, -1), to.AddDate(0, 0, 2)).
+		Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	months := map[string]*PnLRow{}
+	syms := map[string]*PnLRow{}
+	rows := func(date, symbol string) []*PnLRow {
+		return []*PnLRow{&sum.Total, pnlRow(months, monthOf(date)), pnlRow(syms, symbol)}
+	}
+
+	imported := func(at time.Time) {
+		if sum.ImportedAt == nil || at.After(*sum.ImportedAt) {
+			sum.ImportedAt = &at
+		}
+	}
+
+	for _, rp := range profits {
+		imported(rp.CreatedAt)
+		for _, r := range rows(rp.TradeDate, rp.Symbol) {
+			r.BrokerSoldQty += rp.Qty
+			r.BrokerProfit += rp.Profit
+			r.BrokerFee += rp.Fee
+		}
+	}
+	for _, bt := range trades {
+		imported(bt.CreatedAt)
+		if bt.Side != string(broker.SideBuy) {
+			continue
+		}
+		for _, r := range rows(bt.TradeDate, bt.Symbol) {
+			r.BrokerBoughtQty += bt.Qty
+		}
+	}
+	for _, tl := range logs {
+		date := tl.Date.In(broker.Eastern()).Format("2006-01-02")
+		if date < sum.From || date > sum.To {
+			continue
+		}
+		for _, r := range rows(date, tl.Symbol) {
+			if tl.Side == string(broker.SideSell) {
+				r.LocalSoldQty += tl.Qty
+				r.LocalProfit += tl.Profit
+			} else {
+				r.LocalBoughtQty += tl.Qty
+			}
+		}
+	}
+
+	sum.Total.Key = "TOTAL"
+	reconcile(&sum.Total)
+	sum.ByMonth = sortedRows(months)
+	sum.BySymbol = sortedRows(syms)
+	return sum, nil
+}
+
+// monthOf returns the YYYY-MM of a YYYY-MM-DD date
+func monthOf(date string) string {
+	if len(date) < 7 {
+		return date
+	}
+	return date[:7]
+}
+
+func pnlRow(m map[string]*PnLRow, key string) *PnLRow {
+	r, ok := m[key]
+	if !ok {
+		r = &PnLRow{Key: key}
+		m[key] = r
+	}
+	return r
+}
+
+func sortedRows(m map[string]*PnLRow) []PnLRow {
+	out := make([]PnLRow, 0, len(m))
+	for _, r := range m {
+		reconcile(r)
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func reconcile(r *PnLRow) {
+	r.Diff = math.Round((r.BrokerProfit-(r.LocalProfit-r.BrokerFee))*100) / 100
+	r.Reconciled = r.BrokerBoughtQty == r.LocalBoughtQty &&
+		r.BrokerSoldQty == r.LocalSoldQty &&
+		math.Abs(r.Diff) <= reconcileTolerance
+}
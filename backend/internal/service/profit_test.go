@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kisfake"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// Importing the same range again replaces its rows with the broker's current
+// report instead of adding to them; other dates are left alone
+func TestProfitImportRerunReplacesRows(t *testing.T) {
+	tr, srv := newKISTracker(t, kisfake.Scenario{
+		Prices:   map[string]float64{"TQQQ": 55},
+		Holdings: []kisfake.Holding{{Symbol: "TQQQ", Exchange: "NASD", Qty: 20, AvgPrice: 50}},
+	})
+	ctx := t.Context()
+	book := NewProfitBook(tr.DB, tr.Broker)
+	sell := func(qty int, price float64) {
+		t.Helper()
+		o, err := tr.Broker.PlaceOrder(ctx, broker.OrderRequest{
+			Symbol: "TQQQ", Exchange: broker.ExchangeNASDAQ, Side: broker.SideSell,
+			Type: broker.OrderTypeLimit, Qty: qty, Price: price,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Fill(o.ID, qty, price); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() (profits, trades int64, qty int) {
+		t.Helper()
+		var rows []model.RealizedProfit
+		tr.DB.Where("trade_date <> ?", "2000-01-03").Find(&rows)
+		for _, r := range rows {
+			qty += r.Qty
+		}
+		tr.DB.Model(&model.BrokerTrade{}).Count(&trades)
+		return int64(len(rows)), trades, qty
+	}
+
+	tr.DB.Create(&model.RealizedProfit{Broker: "kis", TradeDate: "2000-01-03", Symbol: "TQQQ", Qty: 1})
+	sell(5, 55)
+	day, err := time.Parse("20060102", srv.Executions()[0].Date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		if _, err := book.Import(ctx, day, day); err != nil {
+			t.Fatal(err)
+		}
+		if p, tc, qty := count(); p != 1 || tc != 1 || qty != 5 {
+			t.Errorf("import #%d: %d profit rows (%d shares), %d trades; want 1 (5), 1", run, p, qty, tc)
+		}
+	}
+
+	// A later fill on the same day updates the day's row
+	sell(3, 56)
+	if _, err := book.Import(ctx, day, day); err != nil {
+		t.Fatal(err)
+	}
+	if p, tc, qty := count(); p != 1 || tc != 2 || qty != 8 {
+		t.Errorf("after another sell: %d profit rows (%d shares), %d trades; want 1 (8), 2", p, qty, tc)
+	}
+
+	var kept int64
+	tr.DB.Model(&model.RealizedProfit{}).Where("trade_date = ?", "2000-01-03").Count(&kept)
+	if kept != 1 {
+		t.Errorf("row outside the range: %d left, want 1", kept)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
+)
+
+// defaultQuoteMaxAge is how old a streamed price may be and still be traded on
+const defaultQuoteMaxAge = 15 * time.Second
+
+// QuoteCache keeps the latest realtime quote per symbol and fans updates out
+// to listeners (the streaming API)
+type QuoteCache struct {
+	// MaxAge is how old a cached last price may be and still replace a broker quote
+	MaxAge time.Duration
+
+	mu        sync.RWMutex
+	quotes    map[string]broker.Quote
+	listeners map[chan broker.Quote]struct{}
+	streaming atomic.Bool
+}
+
+func NewQuoteCache() *QuoteCache {
+	return &QuoteCache{
+		MaxAge:    defaultQuoteMaxAge,
+		quotes:    make(map[string]broker.Quote),
+		listeners: make(map[chan broker.Quote]struct{}),
+	}
+}
+
+// Update merges u into the cached quote of its symbol: trade updates carry
+// the last price, quote updates only bid and ask
+func (c *QuoteCache) Update(u broker.Quote) {
+	c.mu.Lock()
+	q := c.quotes[u.Symbol]
+	q.Symbol = u.Symbol
+	if u.Exchange != "" {
+		q.Exchange = u.Exchange
+	}
+	if u.Last > 0 {
+		q.Last = u.Last
+		q.Volume = u.Volume
+		q.Time = u.Time
+		q.Received = u.Received
+	}
+	if u.Bid > 0 || u.Ask > 0 {
+		q.Bid, q.Ask = u.Bid, u.Ask
+		q.BidSize, q.AskSize = u.BidSize, u.AskSize
+	}
+	if q.Received.IsZero() {
+		q.Received = u.Received
+	}
+	c.quotes[u.Symbol] = q
+	for ch := range c.listeners {
+		select {
+		case ch <- q:
+		default: // Slow listener, it gets the next update
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Streaming reports whether the realtime feed is running
+func (c *QuoteCache) Streaming() bool {
+	return c.streaming.Load()
+}
+
+// stopped marks the feed down and closes every listener channel
+func (c *QuoteCache) stopped() {
+	c.streaming.Store(false)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.listeners {
+		close(ch)
+		delete(c.listeners, ch)
+	}
+}
+
+// Get returns the cached quote of symbol
+func (c *QuoteCache) Get(symbol string) (broker.Quote, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	q, ok := c.quotes[symbol]
+	return q, ok
+}
+
+// Fresh returns the cached quote of symbol if its last price is within MaxAge
+func (c *QuoteCache) Fresh(symbol string) (broker.Quote, bool) {
+	q, ok := c.Get(symbol)
+	if !ok || q.Last <= 0 || time.Since(q.Received) > c.MaxAge {
+		return broker.Quote{}, false
+	}
+	return q, true
+}
+
+// Snapshot returns every cached quote, by symbol
+func (c *QuoteCache) Snapshot() []broker.Quote {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]broker.Quote, 0, len(c.quotes))
+	for _, q := range c.quotes {
+		out = append(out, q)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+// Listen returns a channel of merged updates, closed when the feed stops;
+// call the func to stop listening
+func (c *QuoteCache) Listen() (<-chan broker.Quote, func()) {
+	ch := make(chan broker.Quote, 64)
+	c.mu.Lock()
+	c.listeners[ch] = struct{}{}
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.listeners, ch)
+		c.mu.Unlock()
+	}
+}
+
+// quote returns the price of sym to trade on: the streamed last price when
+// fresh, else the broker's quote
+func (s *Strategy) quote(ctx context.Context, exch broker.Exchange, sym string) (float64, error) {
+	if q, ok := s.Quotes.Fresh(sym); ok {
+		logWithTime("[%s] Using realtime price $%.2f (%s old)", sym, q.Last, time.Since(q.Received).Round(time.Millisecond))
+		return q.Last, nil
+	}
+	return s.Broker.GetQuote(ctx, exch, sym)
+}
+
+// WatchList is the symbols the strategy trades: the configured ones, the
+// daily ladder and the rebalance portfolio
+func (s *Strategy) WatchList() []string {
+	seen := map[string]bool{}
+	var settings model.UserSettings
+	s.DB.First(&settings) // No row: the default cycle symbol
+	for _, sym := range CycleSymbols(settings) {
+		seen[sym] = true
+	}
+	for sym := range s.RebalanceWeights() {
+		seen[sym] = true
+	}
+	out := make([]string, 0, len(seen))
+	for sym := range seen {
+		out = append(out, sym)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Watch adds sym to the realtime feed for one listener; call release when it
+// leaves. A symbol off the watch list is dropped from the feed with its last
+// listener, as a KIS session only carries about 20 symbols.
+func (s *Strategy) Watch(sym string) (release func(), err error) {
+	st, ok := s.Broker.(broker.QuoteStreamer)
+	if !ok {
+		return nil, broker.ErrNotSupported
+	}
+	info, err := s.Symbols.Lookup(sym)
+	if err != nil {
+		return nil, err
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.watchers[info.Ticker] == 0 {
+		if err := st.SubscribeQuotes(info.Exchange, info.Ticker); err != nil {
+			return nil, err
+		}
+	}
+	if s.watchers == nil {
+		s.watchers = make(map[string]int)
+	}
+	s.watchers[info.Ticker]++
+
+	var once sync.Once
+	return func() { once.Do(func() { s.unwatch(st, info.Exchange, info.Ticker) }) }, nil
+}
+
+// unwatch drops one listener of sym, releasing it from the feed with the last
+func (s *Strategy) unwatch(st broker.QuoteStreamer, exch broker.Exchange, sym string) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.watchers[sym]--; s.watchers[sym] > 0 {
+		return
+	}
+	delete(s.watchers, sym)
+	if slices.Contains(s.WatchList(), sym) {
+		return
+	}
+	if err := st.UnsubscribeQuotes(exch, sym); err != nil {
+		logWithTime("[QUOTES] ⚠ Cannot release %s: %v", sym, err)
+	}
+}
+
+// RunQuoteFeed subscribes the watch list and feeds the cache until ctx ends
+func (s *Strategy) RunQuoteFeed(ctx context.Context) error {
+	st, ok := s.Broker.(broker.QuoteStreamer)
+	if !ok {
+		return broker.NewError(broker.CategoryValidation, "%s broker has no realtime quote feed", s.Broker.Name())
+	}
+	for _, sym := range s.WatchList() {
+		// Never released: the feed holds the watch list for its lifetime
+		if _, err := s.Watch(sym); err != nil {
+			logWithTime("[QUOTES] ⚠ Cannot subscribe %s: %v", sym, err)
+		}
+	}
+	logWithTime("[QUOTES] Starting realtime feed (%s)...", s.Broker.Name())
+	s.Quotes.streaming.Store(true)
+	defer s.Quotes.stopped()
+	return st.StreamQuotes(ctx, s.Quotes.Update)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// streamingBroker counts the feed registrations of the wrapped broker
+type streamingBroker struct {
+	broker.Broker
+	subs map[string]int
+}
+
+func (b *streamingBroker) StreamQuotes(ctx context.Context, sink func(broker.Quote)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *streamingBroker) SubscribeQuotes(exch broker.Exchange, symbol string) error {
+	b.subs[symbol]++
+	return nil
+}
+
+func (b *streamingBroker) UnsubscribeQuotes(exch broker.Exchange, symbol string) error {
+	b.subs[symbol]--
+	return nil
+}
+
+// A symbol streamed on request stays on the feed while anyone listens and is
+// released with the last listener; watch-list symbols are never released
+func TestWatchReleasesWithLastListener(t *testing.T) {
+	s := newTestStrategy(t)
+	b := &streamingBroker{Broker: s.Broker, subs: map[string]int{}}
+	s.Broker = b
+
+	first, err := s.Watch("QQQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Watch("qqq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.subs["QQQ"] != 1 {
+		t.Fatalf("two listeners: %d registrations, want 1", b.subs["QQQ"])
+	}
+	first()
+	first() // Releasing twice counts once
+	if b.subs["QQQ"] != 1 {
+		t.Fatalf("one listener left: %d registrations, want 1", b.subs["QQQ"])
+	}
+	second()
+	if b.subs["QQQ"] != 0 {
+		t.Fatalf("no listener left: %d registrations, want 0", b.subs["QQQ"])
+	}
+
+	release, err := s.Watch("TQQQ") // Rebalance symbol
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if b.subs["TQQQ"] != 1 {
+		t.Errorf("watch-list symbol released: %d registrations, want 1", b.subs["TQQQ"])
+	}
+}
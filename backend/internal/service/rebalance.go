@@ -1,10 +1,15 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 
-	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
 )
 
 // RebalancePlan holds the result of a rebalance calculation
@@ -14,6 +19,63 @@ type RebalancePlan struct {
 	Items         []RebalanceItem `json:"items"`
 	EstimatedTax  float64         `json:"estimated_tax"`
 	ActionSummary string          `json:"action_summary"`
+
+	// KRW view at ExchangeRate (KRW per USD); all 0 when no rate is known
+	ExchangeRate    float64 `json:"exchange_rate"`
+	TotalValueKRW   float64 `json:"total_value_krw"`
+	CashKRW         float64 `json:"cash_krw"`
+	EstimatedTaxKRW float64 `json:"estimated_tax_krw"`
+}
+
+// rebalanceWeights are the base target weights of the portfolio
+var rebalanceWeights = map[string]float64{
+	"TQQQ": 0.50,
+	"PFIX": 0.15,
+	"SCHD": 0.20,
+	"TMF":  0.15,
+}
+
+// PluginRebalance is the registry name of the V2 rebalance
+const PluginRebalance = "rebalance"
+
+// RebalanceParams are the params of the rebalance plug-in
+type RebalanceParams struct {
+	Weights map[string]float64 `json:"weights,omitempty"` // Base target weights, empty = rebalanceWeights
+}
+
+func parseRebalanceParams(params string) (RebalanceParams, error) {
+	var p RebalanceParams
+	if params == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(params), &p); err != nil {
+		return p, err
+	}
+	total := 0.0
+	for sym, wt := range p.Weights {
+		if wt < 0 {
+			return p, fmt.Errorf("negative weight for %s", sym)
+		}
+		total += wt
+	}
+	if total > 1.0001 {
+		return p, fmt.Errorf("weights add up to %.4f, more than 1", total)
+	}
+	return p, nil
+}
+
+// RebalanceWeights are the base target weights of the rebalance config,
+// else the built-in ones
+func (s *Strategy) RebalanceWeights() map[string]float64 {
+	cfg, err := s.StrategyConfig(PluginRebalance)
+	if err != nil {
+		return rebalanceWeights
+	}
+	p, err := parseRebalanceParams(cfg.Params)
+	if err != nil || len(p.Weights) == 0 {
+		return rebalanceWeights
+	}
+	return p.Weights
 }
 
 type RebalanceItem struct {
@@ -37,37 +99,20 @@ type RebalanceItem struct {
 }
 
 // CalculateRebalancePlan generates a plan without executing trades
-func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
+func (s *Strategy) CalculateRebalancePlan(ctx context.Context) (*RebalancePlan, error) {
 	logWithTime("[REBALANCE] Starting calculation...")
 
-	// 1. Define Strategy Constants
-	baseWeights := map[string]float64{
-		"TQQQ": 0.50,
-		"PFIX": 0.15,
-		"SCHD": 0.20,
-		"TMF":  0.15,
-	}
-	exchCodes := map[string]string{
-		"TQQQ": "NAS",
-		"PFIX": "AMS",
-		"SCHD": "AMS",
-		"TMF":  "AMS",
-	}
-
-	// 2. Fetch Portfolio State
-	// Get Balance/BuyingPower
-	bp, err := s.Client.GetBuyingPower()
+	// 1. Fetch Portfolio State
+	// Get Cash (buying power)
+	cash, err := s.Broker.GetCash(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get buying power: %v", err)
+		return nil, fmt.Errorf("failed to get buying power: %w", err)
 	}
-	// Parse Cash
-	var cash float64
-	fmt.Sscanf(bp.Output.OvrsOrdPsblAmt, "%f", &cash)
 
-	// Get Holdings (GetBalance logic)
-	bal, err := s.Client.GetBalance()
+	// Get Holdings
+	bal, err := s.Broker.GetBalance(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get balance: %v", err)
+		return nil, fmt.Errorf("failed to get balance: %w", err)
 	}
 
 	type HoldingInfo struct {
@@ -76,16 +121,11 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 		Price    float64
 	}
 	holdingsMap := make(map[string]HoldingInfo)
-	for _, h := range bal.Output1 {
-		var q int
-		var avg, now float64
-		fmt.Sscanf(h.Qty, "%d", &q)
-		fmt.Sscanf(h.AvgPrice, "%f", &avg)
-		fmt.Sscanf(h.NowPrice, "%f", &now)
-		holdingsMap[h.Symbol] = HoldingInfo{Qty: q, AvgPrice: avg, Price: now}
+	for _, h := range bal.Positions {
+		holdingsMap[h.Symbol] = HoldingInfo{Qty: h.Qty, AvgPrice: h.AvgPrice, Price: h.CurrentPrice}
 	}
 
-	// 3. Process Each Asset (Fetch Data & Calc Logic)
+	// 2. Process Each Asset (Fetch Data & Calc Logic)
 	// We also need to map "Kill Switch" status to apply cross-asset logic (PFIX <-> TMF)
 	killStatus := make(map[string]bool)
 
@@ -103,14 +143,23 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 	var tempItems []TempItem
 	totalEquity := cash
 
-	for sym, baseWt := range baseWeights {
-		exch := exchCodes[sym]
+	for sym, baseWt := range s.RebalanceWeights() {
+		info, err := s.Symbols.Lookup(sym)
+		if err != nil {
+			return nil, err
+		}
 
 		// A. Get Price History (131 days)
-		prices, err := s.Client.GetDailyPrice(exch, sym, 131)
+		prices, err := s.Broker.GetHistory(ctx, broker.HistoryRequest{
+			Exchange: info.Exchange,
+			Symbol:   sym,
+			Period:   broker.PeriodDay,
+			Adjusted: true,
+			Limit:    131,
+		})
 		if err != nil {
 			logWithTime("⚠ Failed to get history for %s: %v", sym, err)
-			return nil, err
+			return nil, fmt.Errorf("failed to get history for %s: %w", sym, err)
 		}
 
 		if len(prices) < 130 {
@@ -163,10 +212,14 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 
 		// Add to Equity
 		h, exists := holdingsMap[sym]
+		if exists && h.Price > 0 {
+			currentPrice = h.Price
+		}
+		// A fresh realtime price beats the balance snapshot
+		if q, ok := s.Quotes.Fresh(sym); ok {
+			currentPrice = q.Last
+		}
 		if exists {
-			if h.Price > 0 {
-				currentPrice = h.Price
-			}
 			totalEquity += float64(h.Qty) * currentPrice
 		}
 
@@ -182,7 +235,7 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 		})
 	}
 
-	// 4. Cross-Asset Logic (PFIX <-> TMF)
+	// 3. Cross-Asset Logic (PFIX <-> TMF)
 	for i := range tempItems {
 		item := &tempItems[i]
 		if item.Symbol == "PFIX" && killStatus["TMF"] {
@@ -199,7 +252,7 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 		}
 	}
 
-	// 5. Finalize Items
+	// 4. Finalize Items
 	var rebalItems []RebalanceItem
 	var totalTax float64
 
@@ -266,44 +319,42 @@ func (s *Strategy) CalculateRebalancePlan() (*RebalancePlan, error) {
 		EstimatedTax:  totalTax,
 		ActionSummary: fmt.Sprintf("Equity: $%.2f, Est. Tax: $%.2f", totalEquity, totalTax),
 	}
+	if fx, err := s.FX.Current(ctx); err == nil {
+		plan.ExchangeRate = fx.Rate
+		plan.TotalValueKRW = fx.KRW(totalEquity)
+		plan.CashKRW = fx.KRW(cash)
+		plan.EstimatedTaxKRW = fx.KRW(totalTax)
+		plan.ActionSummary += fmt.Sprintf(" (₩%.0f, Est. Tax ₩%.0f at %.2f)", plan.TotalValueKRW, plan.EstimatedTaxKRW, fx.Rate)
+	} else {
+		logWithTime("[REBALANCE] ⚠ No USD/KRW rate, plan in USD only: %v", err)
+	}
 
 	logWithTime("[REBALANCE] Plan calculated. Total Equity: $%.2f", totalEquity)
 	return plan, nil
 }
 
-// ExecuteRebalance executes the plan
-func (s *Strategy) ExecuteRebalance(dryRun bool) error {
-	plan, err := s.CalculateRebalancePlan()
+// ExecuteRebalance executes the plan with the rebalance strategy's placement
+func (s *Strategy) ExecuteRebalance(ctx context.Context, dryRun bool) error {
+	return s.RunStrategy(ctx, PluginRebalance, dryRun)
+}
+
+// QueueRebalance places the plan as reservation orders sent at the next
+// session open. Quotes are the last prices, so run it the evening before.
+func (s *Strategy) QueueRebalance(ctx context.Context, dryRun bool) error {
+	return s.rebalance(ctx, PlacementReserve, dryRun)
+}
+
+func (s *Strategy) rebalance(ctx context.Context, placement Placement, dryRun bool) error {
+	plan, err := s.CalculateRebalancePlan(ctx)
 	if err != nil {
 		return err
 	}
 
-	logWithTime("[REBALANCE] Executing Plan (DryRun=%v)...", dryRun)
+	logWithTime("[REBALANCE] Executing Plan (DryRun=%v, Placement=%s)...", dryRun, placement)
 	logWithTime("[REBALANCE] %s", plan.ActionSummary)
 
-	var sells, buys []RebalanceItem
-	for _, item := range plan.Items {
-		if item.Action == "SELL" {
-			sells = append(sells, item)
-		} else if item.Action == "BUY" {
-			buys = append(buys, item)
-		}
-	}
-
-	// 1. Sells
-	for _, item := range sells {
-		if item.ActionQty == 0 {
-			continue
-		}
-		s.placeRebalanceOrder(item, dryRun)
-	}
-
-	// 2. Buys
-	for _, item := range buys {
-		if item.ActionQty == 0 {
-			continue
-		}
-		s.placeRebalanceOrder(item, dryRun)
+	if err := s.executeRebalanceItems(ctx, plan.Items, placement, dryRun); err != nil {
+		return err
 	}
 
 	logWithTime("[REBALANCE] Execution Completed.")
@@ -311,68 +362,216 @@ func (s *Strategy) ExecuteRebalance(dryRun bool) error {
 }
 
 // ExecuteCustomRebalance executes a user-modified plan
-func (s *Strategy) ExecuteCustomRebalance(customPlan *RebalancePlan, dryRun bool) error {
+func (s *Strategy) ExecuteCustomRebalance(ctx context.Context, customPlan *RebalancePlan, dryRun bool) error {
 	logWithTime("[REBALANCE] Executing CUSTOM Plan (DryRun=%v)...", dryRun)
 	logWithTime("[REBALANCE] Total Equity: $%.2f, Items: %d", customPlan.TotalValue, len(customPlan.Items))
 
+	cfg, err := s.StrategyConfig(PluginRebalance)
+	if err != nil {
+		return err
+	}
+	if err := s.executeRebalanceItems(ctx, customPlan.Items, s.PlacementFor(cfg), dryRun); err != nil {
+		return err
+	}
+
+	logWithTime("[REBALANCE] Custom Plan Execution Completed.")
+	return nil
+}
+
+// executeRebalanceItems places sells first, then buys. It stops early when the
+// broker cannot take orders at all (auth, market closed, outage) and skips the
+// remaining buys once cash runs out. The first failure is returned.
+func (s *Strategy) executeRebalanceItems(ctx context.Context, items []RebalanceItem, placement Placement, dryRun bool) error {
 	var sells, buys []RebalanceItem
-	for _, item := range customPlan.Items {
+	var syms []string
+	for _, item := range items {
 		if item.Action == "SELL" {
 			sells = append(sells, item)
 		} else if item.Action == "BUY" {
 			buys = append(buys, item)
+		} else {
+			continue
 		}
+		syms = append(syms, item.Symbol)
+	}
+	// Custom plans and queued rebalances must not touch another strategy's symbols
+	if err := s.CheckSymbolClaims(PluginRebalance, syms); err != nil {
+		logWithTime("[REBALANCE] ✗ %v", err)
+		return err
 	}
 
-	// 1. Sells first
-	for _, item := range sells {
+	var firstErr error
+	for _, item := range append(sells, buys...) {
 		if item.ActionQty == 0 {
 			continue
 		}
-		s.placeRebalanceOrder(item, dryRun)
-	}
+		if err := ctx.Err(); err != nil {
+			logWithTime("[REBALANCE] ✗ Aborted before %s %s: %v", item.Action, item.Symbol, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return firstErr
+		}
 
-	// 2. Buys
-	for _, item := range buys {
-		if item.ActionQty == 0 {
+		err := s.placeRebalanceOrder(ctx, item, placement, dryRun)
+		if err == nil {
 			continue
 		}
-		s.placeRebalanceOrder(item, dryRun)
-	}
+		err = fmt.Errorf("%s %s: %w", item.Action, item.Symbol, err)
+		if firstErr == nil {
+			firstErr = err
+		}
 
-	logWithTime("[REBALANCE] Custom Plan Execution Completed.")
-	return nil
+		cat := broker.CategoryOf(err)
+		if haltsOrdering(cat) {
+			logWithTime("[REBALANCE] ✗ Stopping execution (%s): %v", cat, err)
+			return err
+		}
+		if cat == broker.CategoryFunds && item.Action == "BUY" {
+			logWithTime("[REBALANCE] ✗ Out of buying power, skipping remaining buys")
+			break
+		}
+	}
+	return firstErr
 }
 
-func (s *Strategy) placeRebalanceOrder(item RebalanceItem, dryRun bool) {
+func (s *Strategy) placeRebalanceOrder(ctx context.Context, item RebalanceItem, placement Placement, dryRun bool) error {
 	logWithTime("[REBALANCE] %s %d shares of %s (Target: %d, Current: %d)",
 		item.Action, item.ActionQty, item.Symbol, item.TargetQty, item.CurrentQty)
 
-	exch := "NYS"
-	if item.Symbol == "TQQQ" {
-		exch = "NASD"
-	} else if item.Symbol == "PFIX" || item.Symbol == "SCHD" || item.Symbol == "TMF" {
-		exch = "AMEX" // Order API uses 4-char codes (NASD, AMEX)
+	info, err := s.Symbols.Lookup(item.Symbol)
+	if err != nil {
+		logWithTime("[REBALANCE] ✗ %v", err)
+		return err
 	}
+	exch := info.Exchange
 
 	logWithTime("[REBALANCE] Preparing %s order for %s:%s (DryRun=%v)", item.Action, exch, item.Symbol, dryRun)
 
 	if dryRun {
-		return
+		return nil
 	}
 
-	orderReq := kis.OrderReq{
-		ExchCode: exch,
+	if n, err := s.Orders.CancelAllForSymbol(ctx, item.Symbol, SourceRebalance); err != nil {
+		logWithTime("[REBALANCE] ⚠ Failed to cancel outstanding %s orders (%d cancelled): %v", item.Symbol, n, err)
+	} else if n > 0 {
+		logWithTime("[REBALANCE] Cancelled %d outstanding %s orders", n, item.Symbol)
+	}
+
+	orderReq := broker.OrderRequest{
+		Exchange: exch,
 		Symbol:   item.Symbol,
+		Side:     broker.Side(item.Action),
+		Type:     broker.OrderTypeLimit,
 		Qty:      item.ActionQty,
 		Price:    item.CurrentPrice,
-		OrdType:  "00", // Limit
-		Side:     item.Action,
 	}
 
-	if err := s.Client.PlaceOrder(orderReq); err != nil {
-		logWithTime("[REBALANCE] ✗ Failed to %s %s: %v", item.Action, item.Symbol, err)
-	} else {
-		logWithTime("[REBALANCE] ✓ %s Order PLACED for %s", item.Action, item.Symbol)
+	orderReq, err = s.checkBuyingPower(ctx, orderReq)
+	if err != nil {
+		logWithTime("[REBALANCE] ✗ Skipping %s %s (%s): %v", item.Action, item.Symbol, broker.CategoryOf(err), err)
+		return err
+	}
+
+	order, err := s.submit(ctx, orderReq, SourceRebalance, placement)
+	if err != nil {
+		logWithTime("[REBALANCE] ✗ Failed to %s %s (%s): %v", item.Action, item.Symbol, broker.CategoryOf(err), err)
+		return err
+	}
+	if placement == PlacementReserve {
+		logWithTime("[REBALANCE] ✓ %s Order RESERVED for %s (Reservation ID: %s)", item.Action, item.Symbol, order.ReservationID)
+		return nil
+	}
+	logWithTime("[REBALANCE] ✓ %s Order PLACED for %s (Order ID: %s)", item.Action, item.Symbol, order.BrokerOrderID)
+	return nil
+}
+
+// rebalancePlugin runs the V2 monthly rebalance from the registry
+type rebalancePlugin struct {
+	s *Strategy
+}
+
+func (p rebalancePlugin) Name() string { return PluginRebalance }
+
+func (p rebalancePlugin) Describe() string {
+	return "V2 monthly rebalance to target weights, halved under a falling MA130 (2-strike kill switch for PFIX/TMF)"
+}
+
+// DefaultSchedule is the 26th, or the evening of the 25th for reservations
+// sent at the open of the 26th
+func (p rebalancePlugin) DefaultSchedule(at string, placement Placement) string {
+	min, hour := cronTime(at)
+	day := 26
+	if placement == PlacementReserve {
+		day = 25
+	}
+	return fmt.Sprintf("%s %s %d * *", min, hour, day)
+}
+
+func (p rebalancePlugin) ValidateParams(params string) error {
+	rp, err := parseRebalanceParams(params)
+	if err != nil {
+		return err
+	}
+	for sym := range rp.Weights {
+		if sym != strings.ToUpper(sym) {
+			return fmt.Errorf("symbol %s must be upper case", sym)
+		}
+		if _, err := p.s.Symbols.Lookup(sym); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimedSymbols are the symbols with a target weight in cfg
+func (p rebalancePlugin) ClaimedSymbols(cfg model.StrategyConfig) ([]string, error) {
+	rp, err := parseRebalanceParams(cfg.Params)
+	if err != nil {
+		return nil, err
 	}
+	weights := rp.Weights
+	if len(weights) == 0 {
+		weights = rebalanceWeights
+	}
+	var out []string
+	for sym, wt := range weights {
+		if wt > 0 {
+			out = append(out, sym)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (p rebalancePlugin) Evaluate(ctx context.Context, cfg model.StrategyConfig) (*Proposal, error) {
+	plan, err := p.s.CalculateRebalancePlan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prop := &Proposal{Summary: plan.ActionSummary, Detail: plan}
+	for _, item := range plan.Items {
+		if item.Action == "HOLD" || item.ActionQty == 0 {
+			continue
+		}
+		prop.Orders = append(prop.Orders, ProposedOrder{
+			Symbol: item.Symbol,
+			Side:   broker.Side(item.Action),
+			Type:   broker.OrderTypeLimit,
+			Qty:    item.ActionQty,
+			Price:  item.CurrentPrice,
+			Reason: fmt.Sprintf("weight %.1f%% → %.1f%%", item.CurrentWt*100, item.TargetWt*100),
+		})
+	}
+	return prop, nil
+}
+
+func (p rebalancePlugin) Execute(ctx context.Context, cfg model.StrategyConfig, placement Placement, dryRun bool) error {
+	return p.s.rebalance(ctx, placement, dryRun)
+}
+
+func (p rebalancePlugin) State(ctx context.Context, cfg model.StrategyConfig) (interface{}, error) {
+	return map[string]interface{}{
+		"weights": p.s.RebalanceWeights(),
+	}, nil
 }
@@ -1,24 +1,71 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"math"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/kis"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/repository"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/symbols"
 	"gorm.io/gorm"
 )
 
+// Placement selects how strategy orders reach the broker
+type Placement string
+
+const (
+	PlacementLive    Placement = "live"    // Regular orders during the session
+	PlacementReserve Placement = "reserve" // Reservation orders sent at the next session open
+)
+
+// ParsePlacement accepts "live" (or empty) and "reserve"
+func ParsePlacement(s string) (Placement, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "live":
+		return PlacementLive, nil
+	case "reserve", "reservation":
+		return PlacementReserve, nil
+	}
+	return "", fmt.Errorf("invalid order placement: %q (expected live or reserve)", s)
+}
+
 type Strategy struct {
-	DB     *repository.DB
-	Client *kis.Client
+	DB      *repository.DB
+	Broker  broker.Broker
+	Orders  *OrderTracker
+	Profits *ProfitBook
+	FX      *FXBook
+	Quotes  *QuoteCache
+	Symbols *symbols.Master
+	// Plugins are the strategies the scheduler and the API run by name
+	Plugins *Registry
+	// Placement is used by ExecuteDaily and by strategies without their own
+	Placement Placement
+
+	watchMu  sync.Mutex
+	watchers map[string]int // Listeners per symbol on the realtime feed
 }
 
-func NewStrategy(db *repository.DB, client *kis.Client) *Strategy {
-	return &Strategy{DB: db, Client: client}
+func NewStrategy(db *repository.DB, b broker.Broker, syms *symbols.Master) *Strategy {
+	s := &Strategy{
+		DB:      db,
+		Broker:  b,
+		Orders:  NewOrderTracker(db, b, syms),
+		Profits: NewProfitBook(db, b),
+		FX:      NewFXBook(db, b),
+		Quotes:  NewQuoteCache(),
+		Symbols: syms,
+		Plugins: NewRegistry(),
+	}
+	// The monthly rebalance has always been scheduled; the daily ladder is opt-in
+	s.Plugins.Register(rebalancePlugin{s}, true)
+	s.Plugins.Register(infinitePlugin{s}, false)
+	return s
 }
 
 // logWithTime logs a message with timestamp
@@ -27,26 +74,40 @@ func logWithTime(format string, v ...interface{}) {
 	log.Printf("[%s] "+format, append([]interface{}{timestamp}, v...)...)
 }
 
-// SyncState updates local DB with real portfolio status
-func (s *Strategy) SyncState() error {
-	logWithTime("[SYNC] Starting portfolio sync with KIS API...")
+// SyncState updates local DB with real portfolio status. Holdings are
+// attributed to the cycle of their symbol; those outside UserSettings.Symbols
+// (the rebalance portfolio, manual trades) belong to no cycle and are skipped.
+func (s *Strategy) SyncState(ctx context.Context) error {
+	logWithTime("[SYNC] Starting portfolio sync with broker (%s)...", s.Broker.Name())
 
-	bal, err := s.Client.GetBalance()
+	bal, err := s.Broker.GetBalance(ctx)
 	if err != nil {
 		logWithTime("[SYNC] ✗ Failed to get balance: %v", err)
 		return err
 	}
 
-	logWithTime("[SYNC] Received %d holdings from KIS", len(bal.Output1))
+	logWithTime("[SYNC] Received %d holdings from broker", len(bal.Positions))
+
+	var settings model.UserSettings
+	s.DB.First(&settings)
+	cycleSymbols := CycleSymbols(settings)
+	isCycle := make(map[string]bool)
+	for _, sym := range cycleSymbols {
+		isCycle[sym] = true
+	}
 
 	// Track active symbols to find sold ones later
 	activeSymbols := make(map[string]bool)
 
-	// 1. Update Existing / Create New Holdings from KIS
-	for _, holding := range bal.Output1 {
+	// 1. Update Existing / Create New Cycles from the broker's holdings
+	for _, holding := range bal.Positions {
+		if !isCycle[holding.Symbol] {
+			logWithTime("[SYNC] %s: %d shares outside the cycle symbols, not attributed", holding.Symbol, holding.Qty)
+			continue
+		}
 		activeSymbols[holding.Symbol] = true
-		qty, _ := strconv.Atoi(holding.Qty)
-		avgPrice, _ := strconv.ParseFloat(holding.AvgPrice, 64)
+		qty := holding.Qty
+		avgPrice := holding.AvgPrice
 
 		var cycle model.CycleStatus
 		res := s.DB.Where("symbol = ?", holding.Symbol).First(&cycle)
@@ -57,6 +118,10 @@ func (s *Strategy) SyncState() error {
 		}
 
 		// Update
+		if qty > 0 {
+			startCycle(&cycle)
+		}
+		cycle.Exchange = string(holding.Exchange)
 		cycle.TotalBoughtQty = qty
 		cycle.AvgPrice = avgPrice
 		cycle.TotalInvested = float64(qty) * avgPrice
@@ -71,43 +136,53 @@ func (s *Strategy) SyncState() error {
 			holding.Symbol, qty, avgPrice, cycle.TotalInvested)
 	}
 
-	// 2. Check for Sold Holdings (In DB but not in KIS)
+	// 2. Check for Sold Holdings (In DB but not at the broker)
 	var allCycles []model.CycleStatus
-	s.DB.Find(&allCycles)
+	s.DB.Where("symbol IN ?", cycleSymbols).Find(&allCycles)
 
+	splits := make(map[string]int)
+	if cycles, err := s.SymbolCycles(settings); err == nil {
+		for _, c := range cycles {
+			splits[c.Symbol] = c.SplitCount
+		}
+	}
+
+	reset := false
 	for _, cycle := range allCycles {
-		if !activeSymbols[cycle.Symbol] {
-			// Cycle exists in DB but not in KIS -> Sold completely OR data missing
-			if cycle.TotalBoughtQty > 0 {
-				logWithTime("[SYNC] ⚠ Detected SOLD position: %s (Qty: %d -> 0)", cycle.Symbol, cycle.TotalBoughtQty)
-
-				// Reset Cycle
-				cycle.TotalBoughtQty = 0
-				cycle.CurrentCycleDay = 0
-				cycle.AvgPrice = 0
-				cycle.TotalInvested = 0
-				s.DB.Save(&cycle)
-				logWithTime("[SYNC] Cycle for %s reset.", cycle.Symbol)
-
-				// 3. Auto-Update Principal
-				logWithTime("[SYNC] Cycle reset detected! Updating Principal from Buying Power...")
-				bp, bpErr := s.Client.GetBuyingPower()
-				if bpErr == nil {
-					newPrincipal, _ := strconv.ParseFloat(bp.Output.OvrsOrdPsblAmt, 64)
-					if newPrincipal > 0 {
-						s.DB.Exec("UPDATE user_settings SET principal = ? WHERE id = 1", newPrincipal)
-						logWithTime("[SYNC] ✓ Principal Auto-Updated to: $%.2f", newPrincipal)
-					}
-				} else {
-					logWithTime("[SYNC] ✗ Failed to fetch Buying Power for update: %v", bpErr)
-				}
-			} else {
-				// Already 0, double check consistency
-				if cycle.TotalBoughtQty != 0 {
-					cycle.TotalBoughtQty = 0
-					s.DB.Save(&cycle)
-				}
+		if activeSymbols[cycle.Symbol] || cycle.TotalBoughtQty == 0 {
+			continue
+		}
+		// Cycle exists in DB but not at the broker -> Sold completely OR data missing
+		logWithTime("[SYNC] ⚠ Detected SOLD position: %s (Qty: %d -> 0)", cycle.Symbol, cycle.TotalBoughtQty)
+
+		// Record the finished cycle, then reset it
+		s.closeCycle(cycle, splits[cycle.Symbol])
+		cycle.TotalBoughtQty = 0
+		cycle.CurrentCycleDay = 0
+		cycle.AvgPrice = 0
+		cycle.TotalInvested = 0
+		if cycle.Mode == ModeReverse {
+			s.recordCycleEvent(cycle, EventReverseEnd, ExhaustReverse, 0, 0, "position sold")
+		}
+		resetCycle(&cycle)
+		s.DB.Save(&cycle)
+		logWithTime("[SYNC] Cycle for %s reset.", cycle.Symbol)
+		reset = true
+	}
+
+	// 3. Auto-Update Principal, once no cycle holds shares: the cash is then
+	// what all of them share
+	if reset && len(activeSymbols) > 0 {
+		logWithTime("[SYNC] Principal kept: %d other cycles still hold shares", len(activeSymbols))
+	} else if reset {
+		logWithTime("[SYNC] Cycle reset detected! Updating Principal from Buying Power...")
+		newPrincipal, bpErr := s.Broker.GetCash(ctx)
+		if bpErr == nil {
+			if newPrincipal > 0 {
+				s.updatePrincipal(ctx, newPrincipal)
 			}
+		} else {
+			logWithTime("[SYNC] ✗ Failed to fetch Buying Power for update: %v", bpErr)
 		}
 	}
 
@@ -115,14 +190,84 @@ func (s *Strategy) SyncState() error {
 	return nil
 }
 
+// updatePrincipal stores cash (USD) as the new principal, converted when the
+// principal is kept in KRW. Without a rate the principal is left unchanged.
+func (s *Strategy) updatePrincipal(ctx context.Context, cash float64) {
+	var settings model.UserSettings
+	s.DB.First(&settings)
+	if settings.PrincipalCurrency != CurrencyKRW {
+		s.DB.Exec("UPDATE user_settings SET principal = ? WHERE id = 1", cash)
+		logWithTime("[SYNC] ✓ Principal Auto-Updated to: $%.2f", cash)
+		return
+	}
+	fx, err := s.FX.Current(ctx)
+	if err != nil {
+		logWithTime("[SYNC] ⚠ Principal not updated, no USD/KRW rate: %v", err)
+		return
+	}
+	krw := fx.KRW(cash)
+	s.DB.Exec("UPDATE user_settings SET principal = ? WHERE id = 1", krw)
+	logWithTime("[SYNC] ✓ Principal Auto-Updated to: ₩%.0f ($%.2f at %.2f)", krw, cash, fx.Rate)
+}
+
+// submit sends req as a live order or queues it as a reservation
+func (s *Strategy) submit(ctx context.Context, req broker.OrderRequest, source string, placement Placement) (*model.Order, error) {
+	if placement == PlacementReserve {
+		return s.Orders.Reserve(ctx, req, source)
+	}
+	return s.Orders.Submit(ctx, req, source)
+}
+
+// checkBuyingPower clamps a buy to the quantity the broker reports as
+// orderable at its limit price, so an oversized buy does not fail at the
+// broker halfway through a run. Not even one share is a FUNDS error. When the
+// inquiry itself fails the order goes out unchanged and the broker decides.
+func (s *Strategy) checkBuyingPower(ctx context.Context, req broker.OrderRequest) (broker.OrderRequest, error) {
+	if req.Side != broker.SideBuy {
+		return req, nil
+	}
+	price := 0.0
+	if req.Type.HasLimit() {
+		price = req.Price
+	}
+
+	bp, err := s.Broker.GetBuyingPower(ctx, req.Exchange, req.Symbol, price)
+	if err != nil {
+		logWithTime("[%s] ⚠ Buying power check failed, leaving it to the broker: %v", req.Symbol, err)
+		return req, nil
+	}
+	if bp.MaxQty <= 0 {
+		return req, broker.NewError(broker.CategoryFunds, "no buying power for %s at $%.2f (available $%.2f)",
+			req.Symbol, bp.Price, bp.Cash)
+	}
+	if req.Qty > bp.MaxQty {
+		logWithTime("[%s] ⚠ Buy clamped to buying power: %d → %d shares at $%.2f (available $%.2f)",
+			req.Symbol, req.Qty, bp.MaxQty, bp.Price, bp.Cash)
+		req.Qty = bp.MaxQty
+	}
+	return req, nil
+}
+
 // ExecuteDaily runs the strategy for a single day
-func (s *Strategy) ExecuteDaily() {
+func (s *Strategy) ExecuteDaily(ctx context.Context) {
+	s.runDaily(ctx, s.Placement)
+}
+
+// QueueDaily builds the daily ladder as reservation orders, to be run the
+// evening before the session they are meant for
+func (s *Strategy) QueueDaily(ctx context.Context) {
+	s.runDaily(ctx, PlacementReserve)
+}
+
+func (s *Strategy) runDaily(ctx context.Context, placement Placement) {
 	logWithTime("[EXECUTE] ========================================")
-	logWithTime("[EXECUTE] Starting ExecuteDaily...")
+	logWithTime("[EXECUTE] Starting ExecuteDaily (placement: %s)...", placement)
 
 	// 0. Force Refresh Token to avoid expiration issues during execution
-	if err := s.Client.ForceRefresh(); err != nil {
-		logWithTime("[EXECUTE] ⚠ Failed to refresh token: %v (trying to proceed anyway)", err)
+	if r, ok := s.Broker.(broker.TokenRefresher); ok {
+		if err := r.ForceRefresh(ctx); err != nil {
+			logWithTime("[EXECUTE] ⚠ Failed to refresh token: %v (trying to proceed anyway)", err)
+		}
 	}
 
 	// 1. Load Settings
@@ -132,8 +277,8 @@ func (s *Strategy) ExecuteDaily() {
 		return
 	}
 
-	logWithTime("[EXECUTE] Settings loaded - IsActive: %v, Principal: $%.2f, SplitCount: %d, TargetRate: %.2f%%",
-		settings.IsActive, settings.Principal, settings.SplitCount, settings.TargetRate*100)
+	logWithTime("[EXECUTE] Settings loaded - IsActive: %v, Principal: %.2f %s, SplitCount: %d, TargetRate: %.2f%%",
+		settings.IsActive, settings.Principal, settings.PrincipalCurrency, settings.SplitCount, settings.TargetRate*100)
 
 	if !settings.IsActive {
 		logWithTime("[EXECUTE] ⚠ Strategy is INACTIVE, skipping execution")
@@ -142,142 +287,286 @@ func (s *Strategy) ExecuteDaily() {
 
 	// Sync State First
 	logWithTime("[EXECUTE] Step 1: Syncing portfolio state...")
-	if err := s.SyncState(); err != nil {
+	if err := s.SyncState(ctx); err != nil {
 		logWithTime("[EXECUTE] ✗ Sync State Failed: %v", err)
 		return
 	}
 
-	symbols := []string{"TQQQ"} // Or parse settings.Symbols
-	logWithTime("[EXECUTE] Step 2: Processing symbols: %v", symbols)
+	// The ladder is sized in USD
+	principal, err := s.principalUSD(ctx, settings)
+	if err != nil {
+		logWithTime("[EXECUTE] ✗ Cannot convert the KRW principal: %v", err)
+		return
+	}
+	settings.Principal = principal
 
-	for _, sym := range symbols {
-		s.processSymbol(sym, settings)
+	cycles, err := s.SymbolCycles(settings)
+	if err != nil {
+		logWithTime("[EXECUTE] ✗ Cannot load the cycle configs: %v", err)
+		return
+	}
+	logWithTime("[EXECUTE] Step 2: Processing cycles: %v", cycles)
+
+	for _, c := range cycles {
+		if err := ctx.Err(); err != nil {
+			logWithTime("[EXECUTE] ✗ Aborted before %s: %v", c.Symbol, err)
+			break
+		}
+		if c.Principal <= 0 || c.SplitCount <= 0 {
+			logWithTime("[%s] ⚠ No principal allocated or no splits, skipping", c.Symbol)
+			continue
+		}
+		s.processSymbol(ctx, c, c.settings(settings), placement)
 	}
 
 	logWithTime("[EXECUTE] ========================================")
 }
 
-func (s *Strategy) processSymbol(sym string, settings model.UserSettings) {
+// ladderOrderType is LOC for the daily ladder. The KIS virtual (VTS) account
+// only accepts plain limits, and so do reservation orders, which fall back to those.
+func (s *Strategy) ladderOrderType(placement Placement) broker.OrderType {
+	if s.Broker.Mode() == "virtual" || placement == PlacementReserve {
+		return broker.OrderTypeLimit
+	}
+	return broker.OrderTypeLOC
+}
+
+// processSymbol places today's ladder of one cycle; settings are those of its symbol
+func (s *Strategy) processSymbol(ctx context.Context, c SymbolCycle, settings model.UserSettings, placement Placement) {
+	sym := c.Symbol
 	logWithTime("[%s] ----------------------------------------", sym)
-	logWithTime("[%s] Processing symbol...", sym)
+	logWithTime("[%s] Processing symbol: principal $%.2f (%.0f%%), %d splits, target %.1f%%...",
+		sym, settings.Principal, c.Allocation*100, settings.SplitCount, settings.TargetRate*100)
+
+	info, err := c.lookup(s.Symbols)
+	if err != nil {
+		logWithTime("[%s] ✗ %v", sym, err)
+		return
+	}
 
 	var cycle model.CycleStatus
 	if err := s.DB.Where("symbol = ?", sym).First(&cycle).Error; err != nil {
 		// Init if missing
-		cycle = model.CycleStatus{Symbol: sym, CurrentCycleDay: 0}
+		cycle = model.CycleStatus{Symbol: sym, Exchange: string(info.Exchange), CurrentCycleDay: 0}
 		logWithTime("[%s] No existing cycle found, initializing new cycle (Day 0)", sym)
 	} else {
 		logWithTime("[%s] Existing cycle: Day %d, TotalQty=%d, AvgPrice=$%.2f, TotalInvested=$%.2f",
 			sym, cycle.CurrentCycleDay, cycle.TotalBoughtQty, cycle.AvgPrice, cycle.TotalInvested)
 	}
 
-	// 2. Buy Logic
-	// Check if already bought today
+	// 2. Today's ladder
+	// Skip when it was already placed today (any accepted DAILY order counts, filled or not)
 	today := time.Now().Truncate(24 * time.Hour)
-	var existingLog model.TradeLog
-	if err := s.DB.Where("symbol = ? AND side = 'BUY' AND created_at >= ?", sym, today).First(&existingLog).Error; err == nil {
-		logWithTime("[%s] ⚠ Already bought today (Qty: %d at $%.2f), skipping buy.", sym, existingLog.Qty, existingLog.Price)
+	var existingOrder model.Order
+	if err := s.DB.Where("symbol = ? AND source = ? AND status <> ? AND submitted_at >= ?",
+		sym, SourceDaily, broker.OrderStatusRejected, today).First(&existingOrder).Error; err == nil {
+		logWithTime("[%s] ⚠ Ladder already placed today (%s %d at $%.2f, Status: %s), skipping.",
+			sym, existingOrder.Side, existingOrder.Qty, existingOrder.Price, existingOrder.Status)
 		return
 	}
 
-	// Amount per buy = Principal / 40
-	unitAmount := settings.Principal / float64(settings.SplitCount)
-	logWithTime("[%s] Unit amount per buy: $%.2f (Principal $%.2f / %d splits)",
-		sym, unitAmount, settings.Principal, settings.SplitCount)
+	// Replace yesterday's ladder: stale orders would otherwise pile up
+	if n, err := s.Orders.CancelAllForSymbol(ctx, sym, SourceDaily); err != nil {
+		logWithTime("[%s] ⚠ Failed to cancel some outstanding orders (%d cancelled): %v", sym, n, err)
+	} else if n > 0 {
+		logWithTime("[%s] Cancelled %d outstanding orders", sym, n)
+	}
 
 	// Check Price
-	logWithTime("[%s] Fetching current price from KIS API...", sym)
-	price, err := s.Client.GetCurrentPrice("NAS", sym) // Assuming NAS
+	logWithTime("[%s] Fetching current price from broker...", sym)
+	price, err := s.quote(ctx, info.Exchange, sym)
 	if err != nil {
 		logWithTime("[%s] ✗ Price fetch failed: %v", sym, err)
 		return
 	}
 	logWithTime("[%s] ✓ Current price: $%.2f", sym, price)
 
-	// Calculate Qty to buy
-	// If unitAmount $250, Price $50 -> 5 shares
-	buyQty := int(math.Floor(unitAmount / price))
-	if buyQty < 1 {
-		buyQty = 1 // Min 1
-	}
-	logWithTime("[%s] Calculated buy quantity: %d shares ($%.2f / $%.2f)", sym, buyQty, unitAmount, price)
-
-	// Place Buy Order (LOC - Limit On Close, simulated as Limit Order)
-	// We use price * 1.05 to ensure fill for now, or just Limit at Price
-	logWithTime("[%s] Placing BUY order: %d shares at $%.2f (Limit)...", sym, buyQty, price)
-	buyErr := s.Client.PlaceOrder(kis.OrderReq{
-		ExchCode: "NASD",
-		Symbol:   sym,
-		Qty:      buyQty,
-		Price:    price, // Use current price
-		OrdType:  "00",  // Limit
-		Side:     "BUY",
-	})
-
-	if buyErr != nil {
-		logWithTime("[%s] ✗ Buy order FAILED: %v", sym, buyErr)
-	} else {
-		logWithTime("[%s] ✓ Buy order PLACED: %d shares at $%.2f (Total: $%.2f)", sym, buyQty, price, float64(buyQty)*price)
+	plan := planLadder(info, cycle, settings, price, s.ladderOrderType(placement))
+	logWithTime("[%s] Phase %s%s: T=%.2f of %d, unit $%.2f, X=%.1f%%, target %.1f%%, exhaust policy %s",
+		sym, plan.Phase, modeSuffix(plan.Mode), plan.T, settings.SplitCount, plan.Unit, ladderRate(settings)*100, settings.TargetRate*100, plan.Policy)
+	s.advanceCycle(&cycle, plan, price)
+
+	// 3. Buys (LOC - Limit On Close): fill at the close if it is at or below the limit
+	bought := false
+	for _, o := range plan.Buys {
+		req, err := s.checkBuyingPower(ctx, o.Req)
+		if err == nil {
+			logWithTime("[%s] Placing BUY order: %d shares at $%.2f (%s) - %s", sym, req.Qty, req.Price, req.Type, o.Reason)
+			_, err = s.submit(ctx, req, SourceDaily, placement)
+		}
+		if err != nil {
+			cat := broker.CategoryOf(err)
+			logWithTime("[%s] ✗ Buy order FAILED (%s): %v", sym, cat, err)
+			if haltsOrdering(cat) {
+				// The sells would fail the same way, or double up on an order of unknown outcome
+				logWithTime("[%s] ✗ Skipping the rest of the ladder (%s)", sym, cat)
+				s.DB.Save(&cycle)
+				return
+			}
+			if cat == broker.CategoryFunds {
+				logWithTime("[%s] ⚠ Out of buying power: only the existing position will be offered for sale", sym)
+				break
+			}
+			continue
+		}
+		bought = true
+		logWithTime("[%s] ✓ Buy order PLACED: %d shares at $%.2f (Total: $%.2f)", sym, req.Qty, req.Price, float64(req.Qty)*req.Price)
+		// Trade log is written by the order poller once the order actually fills
+	}
+	if bought {
+		startCycle(&cycle)
 		// Update Cycle Day (Optimistic, sync will fix later)
 		cycle.CurrentCycleDay++
-		s.DB.Save(&cycle)
 		logWithTime("[%s] Cycle day updated to: %d", sym, cycle.CurrentCycleDay)
+	}
 
-		// Record Log
-		s.DB.Create(&model.TradeLog{
-			Date:   time.Now(),
-			Symbol: sym,
-			Side:   "BUY",
-			Qty:    buyQty,
-			Price:  price,
-			Amount: float64(buyQty) * price,
-		})
-		logWithTime("[%s] Trade log recorded in database", sym)
-	}
-
-	// 3. Sell Logic
-	// If we have shares, place Sell Limit at AvgPrice * 1.10
-	if cycle.TotalBoughtQty > 0 || buyErr == nil {
-		logWithTime("[%s] ----------------------------------------", sym)
-		logWithTime("[%s] Processing SELL order...", sym)
-
-		// Total shares = Current Locked + New Buy (approx)
-		// Note: We can't sell "New Buy" immediately if not settled?
-		// Actually KIS allows Day Trading. But safe to sell "Existing" + "New" if accepted.
-		// For simplicity, we assume we sell what we think we have.
-
-		totalQty := cycle.TotalBoughtQty + buyQty // Valid assumption for ordering
-
-		// Target Price
-		// AvgPrice might change after Buy.
-		// Estimated New Avg = (OldTotalVal + NewVal) / TotalQty
-		oldVal := cycle.TotalInvested
-		newVal := float64(buyQty) * price
-		estAvg := (oldVal + newVal) / float64(totalQty)
-
-		targetPrice := estAvg * (1 + settings.TargetRate)
-
-		logWithTime("[%s] Sell calculation: TotalQty=%d, OldInvested=$%.2f, NewInvested=$%.2f",
-			sym, totalQty, oldVal, newVal)
-		logWithTime("[%s] Estimated Avg: $%.2f, Target Rate: %.2f%%, Target Price: $%.2f",
-			sym, estAvg, settings.TargetRate*100, targetPrice)
-
-		logWithTime("[%s] Placing SELL order: %d shares at $%.2f (Limit)...", sym, totalQty, targetPrice)
-		sellErr := s.Client.PlaceOrder(kis.OrderReq{
-			ExchCode: "NASD",
-			Symbol:   sym,
-			Qty:      totalQty,
-			Price:    targetPrice,
-			OrdType:  "00", // Limit
-			Side:     "SELL",
-		})
-
-		if sellErr != nil {
-			logWithTime("[%s] ✗ Sell order FAILED: %v", sym, sellErr)
-		} else {
-			logWithTime("[%s] ✓ Sell order PLACED: %d shares at Target $%.2f", sym, totalQty, targetPrice)
+	// 4. Sells of the synced position
+	for _, o := range plan.Sells {
+		logWithTime("[%s] Placing SELL order: %d shares at $%.2f (%s) - %s", sym, o.Req.Qty, o.Req.Price, o.Req.Type, o.Reason)
+		if _, err := s.submit(ctx, o.Req, SourceDaily, placement); err != nil {
+			cat := broker.CategoryOf(err)
+			logWithTime("[%s] ✗ Sell order FAILED (%s): %v", sym, cat, err)
+			if haltsOrdering(cat) {
+				break
+			}
+			continue
+		}
+		logWithTime("[%s] ✓ Sell order PLACED: %d shares at $%.2f", sym, o.Req.Qty, o.Req.Price)
+		if o.Stop {
+			s.recordStop(&cycle, plan, o.Req, price)
 		}
 	}
+	s.DB.Save(&cycle)
 
 	logWithTime("[%s] ----------------------------------------", sym)
 }
+
+// PluginInfinite is the registry name of the infinite-buy daily ladder
+const PluginInfinite = "infinite"
+
+// infinitePlugin runs the infinite-buy daily ladder from the registry. The
+// principal, splits and target rate stay in UserSettings, whose IsActive
+// still gates the orders.
+type infinitePlugin struct {
+	s *Strategy
+}
+
+func (p infinitePlugin) Name() string { return PluginInfinite }
+
+func (p infinitePlugin) Describe() string {
+	return "Infinite buying (무한매수법): daily LOC buys of Principal/SplitCount at avg and avg × (1 + X), " +
+		"¼ sold LOC at avg × (1 + X) and the rest at avg × (1 + TargetRate); quarter stop or reverse mode once the splits are spent"
+}
+
+// DefaultSchedule is every session, or the evening before for reservations
+func (p infinitePlugin) DefaultSchedule(at string, placement Placement) string {
+	min, hour := cronTime(at)
+	if placement == PlacementReserve {
+		return fmt.Sprintf("%s %s * * 0-4", min, hour)
+	}
+	return fmt.Sprintf("%s %s * * 1-5", min, hour)
+}
+
+// ClaimedSymbols are the cycle symbols of UserSettings
+func (p infinitePlugin) ClaimedSymbols(cfg model.StrategyConfig) ([]string, error) {
+	var settings model.UserSettings
+	p.s.DB.First(&settings)
+	return CycleSymbols(settings), nil
+}
+
+// Evaluate prices today's ladder from the stored cycles without syncing or
+// cancelling anything
+func (p infinitePlugin) Evaluate(ctx context.Context, cfg model.StrategyConfig) (*Proposal, error) {
+	s := p.s
+	var settings model.UserSettings
+	if err := s.DB.First(&settings).Error; err != nil {
+		return nil, broker.NewError(broker.CategoryValidation, "no settings saved")
+	}
+	if settings.SplitCount <= 0 {
+		return nil, broker.NewError(broker.CategoryValidation, "split count must be positive")
+	}
+	principal, err := s.principalUSD(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	settings.Principal = principal
+
+	prop := &Proposal{}
+	var ladders []ladder
+	orderType := s.ladderOrderType(s.PlacementFor(cfg))
+	cycles, err := s.SymbolCycles(settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cycles {
+		if c.Principal <= 0 || c.SplitCount <= 0 {
+			continue
+		}
+		info, err := c.lookup(s.Symbols)
+		if err != nil {
+			return nil, err
+		}
+		var cycle model.CycleStatus
+		s.DB.Where("symbol = ?", c.Symbol).First(&cycle)
+
+		price, err := s.quote(ctx, info.Exchange, c.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Symbol, err)
+		}
+		plan := planLadder(info, cycle, c.settings(settings), price, orderType)
+		for _, o := range plan.orders() {
+			prop.Orders = append(prop.Orders, proposed(o.Req, o.Reason))
+		}
+		ladders = append(ladders, plan)
+	}
+	prop.Detail = ladders
+	prop.Summary = fmt.Sprintf("Principal $%.2f over %d cycles, default %d splits, X %.1f%%, target %.1f%%",
+		settings.Principal, len(cycles), settings.SplitCount, ladderRate(settings)*100, settings.TargetRate*100)
+	if !settings.IsActive {
+		prop.Summary += " (inactive: no orders are placed)"
+	}
+	return prop, nil
+}
+
+func proposed(req broker.OrderRequest, reason string) ProposedOrder {
+	return ProposedOrder{Symbol: req.Symbol, Side: req.Side, Type: req.Type, Qty: req.Qty, Price: req.Price, Reason: reason}
+}
+
+func (p infinitePlugin) Execute(ctx context.Context, cfg model.StrategyConfig, placement Placement, dryRun bool) error {
+	if dryRun {
+		prop, err := p.Evaluate(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		for _, o := range prop.Orders {
+			logWithTime("[EXECUTE] (dry run) %s %d %s at $%.2f (%s): %s", o.Side, o.Qty, o.Symbol, o.Price, o.Type, o.Reason)
+		}
+		return nil
+	}
+	p.s.runDaily(ctx, placement)
+	return nil
+}
+
+func (p infinitePlugin) State(ctx context.Context, cfg model.StrategyConfig) (interface{}, error) {
+	var settings model.UserSettings
+	p.s.DB.First(&settings)
+	var cycles []model.CycleStatus
+	if err := p.s.DB.Find(&cycles).Error; err != nil {
+		return nil, err
+	}
+	symbols, err := p.s.SymbolCycles(settings)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"active":         settings.IsActive,
+		"principal":      settings.Principal,
+		"currency":       settings.PrincipalCurrency,
+		"split_count":    settings.SplitCount,
+		"target_rate":    settings.TargetRate,
+		"ladder_rate":    ladderRate(settings),
+		"exhaust_policy": exhaustPolicy(settings),
+		"symbols":        symbols,
+		"cycles":         cycles,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// Buys are clamped to the orderable quantity, rejected when not even one
+// share fits and sent unchanged when the inquiry fails
+func TestBuyClampedToBuyingPower(t *testing.T) {
+	s := newTestStrategy(t) // $10,000 paper account
+	ctx := t.Context()
+	buy := broker.OrderRequest{
+		Symbol: "TQQQ", Exchange: broker.ExchangeNASDAQ, Side: broker.SideBuy,
+		Type: broker.OrderTypeLOC, Qty: 300, Price: 50,
+	}
+
+	req, err := s.checkBuyingPower(ctx, buy)
+	if err != nil || req.Qty != 200 {
+		t.Errorf("300 at $50: qty %d, err %v; want clamped to 200", req.Qty, err)
+	}
+
+	small := buy
+	small.Qty = 10
+	if req, err := s.checkBuyingPower(ctx, small); err != nil || req.Qty != 10 {
+		t.Errorf("10 at $50: qty %d, err %v; want unchanged", req.Qty, err)
+	}
+
+	dear := buy
+	dear.Price = 20000
+	if _, err := s.checkBuyingPower(ctx, dear); broker.CategoryOf(err) != broker.CategoryFunds {
+		t.Errorf("1 share over the cash: err = %v, want FUNDS", err)
+	}
+
+	sell := buy
+	sell.Side = broker.SideSell
+	if req, err := s.checkBuyingPower(ctx, sell); err != nil || req.Qty != 300 {
+		t.Errorf("sell: qty %d, err %v; want unchanged", req.Qty, err)
+	}
+
+	// A market buy prices at the last trade; the paper account has no candles
+	market := buy
+	market.Type, market.Price = broker.OrderTypeMarket, 0
+	if req, err := s.checkBuyingPower(ctx, market); err != nil || req.Qty != 300 {
+		t.Errorf("failed inquiry: qty %d, err %v; want the order unchanged", req.Qty, err)
+	}
+}
@@ -0,0 +1,194 @@
+package symbols
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// Load returns the built-in master overlaid with each file in paths, in order
+func Load(paths []string) (*Master, error) {
+	m := Default()
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		n, err := m.LoadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("symbol master %s: %w", p, err)
+		}
+		log.Printf("[SYMBOLS] Loaded %d symbols from %s", n, p)
+	}
+	return m, nil
+}
+
+// LoadFile adds the symbols of one file, chosen by extension:
+//   - .json: array of Symbol
+//   - .csv: header row with Symbol JSON field names (ticker, exchange, ...)
+//   - .cod / .zip: KIS overseas master file (nasmst.cod, nysmst.cod, amsmst.cod), zipped or not
+func (m *Master) LoadFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var syms []Symbol
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &syms)
+	case ".csv":
+		syms, err = parseCSV(data)
+	case ".cod":
+		syms, err = parseKISMaster(data)
+	case ".zip":
+		syms, err = parseKISMasterZip(data)
+	default:
+		return 0, fmt.Errorf("unsupported symbol master format: %s", path)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.Add(syms...); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.sources = append(m.sources, path)
+	m.mu.Unlock()
+	return len(syms), nil
+}
+
+// parseCSV reads rows keyed by the header; unknown columns are ignored
+func parseCSV(data []byte) ([]Symbol, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := col["ticker"]; !ok {
+		return nil, fmt.Errorf("csv header needs a ticker column")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var syms []Symbol
+	for line, row := range rows[1:] {
+		s := Symbol{
+			Ticker:     get(row, "ticker"),
+			Name:       get(row, "name"),
+			Exchange:   broker.Exchange(get(row, "exchange")),
+			Currency:   get(row, "currency"),
+			Underlying: get(row, "underlying"),
+		}
+		var err error
+		if v := get(row, "tick_size"); v != "" {
+			if s.TickSize, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("line %d: tick_size: %w", line+2, err)
+			}
+		}
+		if v := get(row, "leverage"); v != "" {
+			if s.Leverage, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("line %d: leverage: %w", line+2, err)
+			}
+		}
+		if v := get(row, "is_etf"); v != "" {
+			if s.IsETF, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("line %d: is_etf: %w", line+2, err)
+			}
+		}
+		syms = append(syms, s)
+	}
+	return syms, nil
+}
+
+// KIS overseas master columns (tab separated, CP949; only ASCII columns are read)
+const (
+	kisColExchCode = 2  // NAS, NYS, AMS
+	kisColSymbol   = 4  // Ticker
+	kisColEngName  = 7  // English name
+	kisColSecType  = 8  // 1: index, 2: stock, 3: ETP, 4: warrant
+	kisColCurrency = 9  // USD
+	kisMinCols     = 10 // Rows shorter than this are skipped
+)
+
+func parseKISMaster(data []byte) ([]Symbol, error) {
+	var syms []Symbol
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Split(strings.TrimRight(sc.Text(), "\r"), "\t")
+		if len(fields) < kisMinCols {
+			continue
+		}
+		secType := strings.TrimSpace(fields[kisColSecType])
+		if secType != "2" && secType != "3" {
+			continue // Indices and warrants are not tradable here
+		}
+		exch := ExchangeFromCode(fields[kisColExchCode])
+		if _, ok := QuoteCode(exch); !ok {
+			continue
+		}
+		syms = append(syms, Symbol{
+			Ticker:   strings.TrimSpace(fields[kisColSymbol]),
+			Name:     strings.TrimSpace(fields[kisColEngName]),
+			Exchange: exch,
+			Currency: strings.TrimSpace(fields[kisColCurrency]),
+			IsETF:    secType == "3",
+		})
+	}
+	return syms, sc.Err()
+}
+
+// parseKISMasterZip reads every .cod file in the archive KIS publishes
+// (e.g. nasmst.cod.zip)
+func parseKISMasterZip(data []byte) ([]Symbol, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var syms []Symbol
+	for _, f := range zr.File {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".cod") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseKISMaster(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		syms = append(syms, parsed...)
+	}
+	return syms, nil
+}
@@ -0,0 +1,235 @@
+// Package symbols is the symbol master: it resolves a ticker to its listing
+// exchange, the KIS quote/order exchange codes, tick size, currency and
+// ETF/leverage metadata. Every quote and order path looks symbols up here
+// instead of assuming an exchange.
+package symbols
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+)
+
+// KIS uses 3-letter codes for quotations and 4-letter codes for trading.
+// NYSE Arca listings are served under the AMEX codes.
+var (
+	quoteCodes = map[broker.Exchange]string{
+		broker.ExchangeNASDAQ: "NAS",
+		broker.ExchangeNYSE:   "NYS",
+		broker.ExchangeAMEX:   "AMS",
+	}
+	orderCodes = map[broker.Exchange]string{
+		broker.ExchangeNASDAQ: "NASD",
+		broker.ExchangeNYSE:   "NYSE",
+		broker.ExchangeAMEX:   "AMEX",
+	}
+)
+
+// QuoteCode returns the KIS quotation exchange code (EXCD) for exch
+func QuoteCode(exch broker.Exchange) (string, bool) {
+	code, ok := quoteCodes[exch]
+	return code, ok
+}
+
+// OrderCode returns the KIS trading exchange code (OVRS_EXCG_CD) for exch
+func OrderCode(exch broker.Exchange) (string, bool) {
+	code, ok := orderCodes[exch]
+	return code, ok
+}
+
+// ExchangeFromCode maps either KIS vocabulary (or a plain name) to a broker.Exchange
+func ExchangeFromCode(code string) broker.Exchange {
+	switch strings.ToUpper(strings.TrimSpace(code)) {
+	case "NAS", "NASD", "NASDAQ":
+		return broker.ExchangeNASDAQ
+	case "NYS", "NYSE":
+		return broker.ExchangeNYSE
+	case "AMS", "AMEX", "ARCA", "NYSEARCA":
+		return broker.ExchangeAMEX
+	}
+	return broker.Exchange(code)
+}
+
+const (
+	defaultTick   = 0.01   // US equities at or above $1
+	subDollarTick = 0.0001 // US equities below $1
+)
+
+// Symbol is one entry of the master
+type Symbol struct {
+	Ticker     string          `json:"ticker"`
+	Name       string          `json:"name"`
+	Exchange   broker.Exchange `json:"exchange"`
+	QuoteCode  string          `json:"quote_code"` // Derived from Exchange
+	OrderCode  string          `json:"order_code"` // Derived from Exchange
+	TickSize   float64         `json:"tick_size"`  // Tick at or above $1 (default 0.01)
+	Currency   string          `json:"currency"`   // Default USD
+	IsETF      bool            `json:"is_etf"`
+	Leverage   float64         `json:"leverage"`   // 3 = 3x long, -3 = 3x inverse, 0 = unleveraged
+	Underlying string          `json:"underlying"` // Tracked index or fund, e.g. QQQ for TQQQ
+}
+
+// normalize fills derived fields and defaults
+func (s *Symbol) normalize() error {
+	s.Ticker = strings.ToUpper(strings.TrimSpace(s.Ticker))
+	if s.Ticker == "" {
+		return broker.NewError(broker.CategoryValidation, "symbol master entry without ticker")
+	}
+	s.Exchange = ExchangeFromCode(string(s.Exchange))
+	quote, ok := QuoteCode(s.Exchange)
+	if !ok {
+		return broker.NewError(broker.CategoryValidation, "%s: unsupported exchange %q", s.Ticker, s.Exchange)
+	}
+	s.QuoteCode = quote
+	s.OrderCode, _ = OrderCode(s.Exchange)
+	if s.TickSize <= 0 {
+		s.TickSize = defaultTick
+	}
+	if s.Currency == "" {
+		s.Currency = "USD"
+	}
+	return nil
+}
+
+// Tick returns the minimum price increment at price
+func (s Symbol) Tick(price float64) float64 {
+	if price < 1 {
+		return subDollarTick
+	}
+	if s.TickSize > 0 {
+		return s.TickSize
+	}
+	return defaultTick
+}
+
+// RoundPrice snaps a limit price to the tick grid: buys round down and sells
+// round up, so neither side ends up paying more or accepting less than intended.
+func (s Symbol) RoundPrice(price float64, side broker.Side) float64 {
+	if price <= 0 {
+		return price
+	}
+	tick := s.Tick(price)
+	steps := price / tick
+	const eps = 1e-9 // 49.99 / 0.01 is 4998.999...
+	if side == broker.SideSell {
+		steps = math.Ceil(steps - eps)
+	} else {
+		steps = math.Floor(steps + eps)
+	}
+	return math.Round(steps*tick*10000) / 10000
+}
+
+// Master is a concurrency-safe ticker index
+type Master struct {
+	mu      sync.RWMutex
+	symbols map[string]Symbol
+	sources []string
+}
+
+func NewMaster() *Master {
+	return &Master{symbols: make(map[string]Symbol)}
+}
+
+// builtins covers the strategy assets and common leveraged ETFs so the
+// server works without master files
+var builtins = []Symbol{
+	{Ticker: "TQQQ", Name: "ProShares UltraPro QQQ", Exchange: broker.ExchangeNASDAQ, IsETF: true, Leverage: 3, Underlying: "QQQ"},
+	{Ticker: "SQQQ", Name: "ProShares UltraPro Short QQQ", Exchange: broker.ExchangeNASDAQ, IsETF: true, Leverage: -3, Underlying: "QQQ"},
+	{Ticker: "QQQ", Name: "Invesco QQQ Trust", Exchange: broker.ExchangeNASDAQ, IsETF: true},
+	{Ticker: "TLT", Name: "iShares 20+ Year Treasury Bond ETF", Exchange: broker.ExchangeNASDAQ, IsETF: true},
+	{Ticker: "TMF", Name: "Direxion Daily 20+ Year Treasury Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "TLT"},
+	{Ticker: "PFIX", Name: "Simplify Interest Rate Hedge ETF", Exchange: broker.ExchangeAMEX, IsETF: true},
+	{Ticker: "SCHD", Name: "Schwab US Dividend Equity ETF", Exchange: broker.ExchangeAMEX, IsETF: true},
+	{Ticker: "SPY", Name: "SPDR S&P 500 ETF Trust", Exchange: broker.ExchangeAMEX, IsETF: true},
+	{Ticker: "UPRO", Name: "ProShares UltraPro S&P500", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "SPY"},
+	{Ticker: "SPXL", Name: "Direxion Daily S&P 500 Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "SPY"},
+	{Ticker: "SOXL", Name: "Direxion Daily Semiconductor Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "SOXX"},
+	{Ticker: "SOXS", Name: "Direxion Daily Semiconductor Bear 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: -3, Underlying: "SOXX"},
+	{Ticker: "TECL", Name: "Direxion Daily Technology Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "XLK"},
+	{Ticker: "FNGU", Name: "MicroSectors FANG+ 3X Leveraged ETN", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "NYFANG"},
+	{Ticker: "TNA", Name: "Direxion Daily Small Cap Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "IWM"},
+	{Ticker: "LABU", Name: "Direxion Daily S&P Biotech Bull 3X", Exchange: broker.ExchangeAMEX, IsETF: true, Leverage: 3, Underlying: "XBI"},
+}
+
+// Default returns a master holding only the built-in symbols
+func Default() *Master {
+	m := NewMaster()
+	if err := m.Add(builtins...); err != nil {
+		panic(err) // builtins are static
+	}
+	m.sources = append(m.sources, "builtin")
+	return m
+}
+
+// Add inserts or updates symbols. Metadata missing from an update (e.g.
+// leverage, which the KIS master files lack) is kept from the existing entry.
+func (m *Master) Add(syms ...Symbol) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range syms {
+		if err := s.normalize(); err != nil {
+			return err
+		}
+		if old, ok := m.symbols[s.Ticker]; ok {
+			s = merge(old, s)
+		}
+		m.symbols[s.Ticker] = s
+	}
+	return nil
+}
+
+func merge(old, s Symbol) Symbol {
+	if s.Name == "" {
+		s.Name = old.Name
+	}
+	if !s.IsETF {
+		s.IsETF = old.IsETF
+	}
+	if s.Leverage == 0 {
+		s.Leverage = old.Leverage
+	}
+	if s.Underlying == "" {
+		s.Underlying = old.Underlying
+	}
+	return s
+}
+
+// Lookup resolves ticker. Unknown tickers are validation errors: an order on
+// a guessed exchange would be rejected by KIS anyway.
+func (m *Master) Lookup(ticker string) (Symbol, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.symbols[strings.ToUpper(strings.TrimSpace(ticker))]
+	if !ok {
+		return Symbol{}, broker.NewError(broker.CategoryValidation, "unknown symbol %s (add it to the symbol master)", ticker)
+	}
+	return s, nil
+}
+
+// All returns every symbol sorted by ticker
+func (m *Master) All() []Symbol {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Symbol, 0, len(m.symbols))
+	for _, s := range m.symbols {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ticker < out[j].Ticker })
+	return out
+}
+
+func (m *Master) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.symbols)
+}
+
+// Sources lists where the entries were loaded from, in load order
+func (m *Master) Sources() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.sources...)
+}
@@ -1,30 +1,78 @@
 package worker
 
 import (
-	"fmt"
+	"context"
 	"log"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/broker"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/config"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/market"
+	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/model"
 	"github.com/mgcha85/TQQQ-InfiniteTrader/backend/internal/service"
 	"github.com/robfig/cron/v3"
 )
 
 type Scheduler struct {
 	Cron      *cron.Cron
+	Config    *config.Config
 	Strat     *service.Strategy
 	MarketSvc *market.MarketDataService
 	Location  *time.Location
+
+	// ctx is cancelled by Stop so running jobs abort their broker calls
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	jobs map[string]cron.EntryID // Strategy name → its job
 }
 
-func NewScheduler(strat *service.Strategy, marketSvc *market.MarketDataService) *Scheduler {
+// Job deadlines
+const (
+	marketCloseHour   = 16 // Regular session close (ET)
+	afterCloseTimeout = 10 * time.Minute
+	pollTimeout       = 50 * time.Second // Finish before the next 1m tick
+	stopTimeout       = 15 * time.Second
+)
+
+// pnlImportDays is how far back the nightly P&L import re-reads the broker
+const pnlImportDays = 7
+
+func NewScheduler(cfg *config.Config, strat *service.Strategy, marketSvc *market.MarketDataService) *Scheduler {
 	loc, err := time.LoadLocation("America/New_York")
 	if err != nil {
 		log.Fatal("Failed to load America/New_York location:", err)
 	}
 	c := cron.New(cron.WithLocation(loc))
-	return &Scheduler{Cron: c, Strat: strat, MarketSvc: marketSvc, Location: loc}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{Cron: c, Config: cfg, Strat: strat, MarketSvc: marketSvc, Location: loc, ctx: ctx, cancel: cancel,
+		jobs: make(map[string]cron.EntryID)}
+}
+
+// Stop cancels running jobs and waits (bounded) for them to return
+func (s *Scheduler) Stop() {
+	log.Println("[SCHEDULER] Stopping scheduler...")
+	s.cancel()
+	select {
+	case <-s.Cron.Stop().Done():
+		log.Println("[SCHEDULER] ✓ All jobs finished")
+	case <-time.After(stopTimeout):
+		log.Printf("[SCHEDULER] ⚠ Jobs still running after %v, exiting anyway", stopTimeout)
+	}
+}
+
+// beforeCloseContext returns a context that expires at today's market close.
+// Jobs started after the close get afterCloseTimeout instead.
+func (s *Scheduler) beforeCloseContext(now time.Time) (context.Context, context.CancelFunc) {
+	now = now.In(s.Location)
+	closeAt := time.Date(now.Year(), now.Month(), now.Day(), marketCloseHour, 0, 0, 0, s.Location)
+	if !now.Before(closeAt) {
+		log.Printf("[SCHEDULER] ⚠ Job started after the %02d:00 ET close, allowing %v", marketCloseHour, afterCloseTimeout)
+		return context.WithTimeout(s.ctx, afterCloseTimeout)
+	}
+	return context.WithDeadline(s.ctx, closeAt)
 }
 
 func (s *Scheduler) Start() {
@@ -61,67 +109,184 @@ func (s *Scheduler) Start() {
 		log.Printf("[SCHEDULER] Registered Daily Market Data Sync at 20:00 ET (Mon-Fri)")
 	}
 
-	// 2. Monthly Rebalancing Schedule: 26th of every month
-	// Time: Configured via SCHEDULE_TIME (default 15:50 ET)
-	scheduleTime := s.Strat.Client.Config.ScheduleTime
-	parts := strings.Split(scheduleTime, ":")
-	if len(parts) != 2 {
-		log.Printf("[SCHEDULER] ⚠ Invalid SCHEDULE_TIME format (%s), defaulting to 15:50", scheduleTime)
-		parts = []string{"15", "50"}
+	// 2. Strategies: one job per enabled strategy plug-in (the V2 rebalance on
+	// the 26th by default, the infinite-buy ladder every session when enabled)
+	s.ScheduleStrategies()
+
+	// 3. Order Status Poller: follow open orders until filled/cancelled/rejected
+	_, err = s.Cron.AddFunc("@every 1m", func() {
+		ctx, cancel := context.WithTimeout(s.ctx, pollTimeout)
+		defer cancel()
+		if err := s.Strat.Orders.Poll(ctx); err != nil {
+			log.Printf("[ORDERS] ✗ Order poll failed: %v", err)
+		}
+	})
+	if err != nil {
+		log.Printf("[SCHEDULER] ⚠ Failed to register Order Poller job: %v", err)
+	} else {
+		log.Printf("[SCHEDULER] Registered Order Status Poller (every 1m)")
 	}
-	hour := parts[0]
-	min := parts[1]
-	// Cron: Min Hour Dom Month Dow
-	cronSpec := fmt.Sprintf("%s %s 26 * *", min, hour)
 
-	log.Printf("[SCHEDULER] Schedule registered: %s ET on 26th (Cron: %s)", scheduleTime, cronSpec)
+	// 4. Realized P&L Import: re-import the last week so late settlements and
+	// corrections replace what was imported before
+	// Schedule: 20:30 ET (after the market data sync) - Mon-Fri
+	if _, ok := s.Strat.Broker.(broker.ProfitReporter); ok {
+		_, err = s.Cron.AddFunc("30 20 * * 1-5", func() {
+			ctx, cancel := context.WithTimeout(s.ctx, afterCloseTimeout)
+			defer cancel()
+			to := time.Now().In(s.Location)
+			if _, err := s.Strat.Profits.Import(ctx, to.AddDate(0, 0, -pnlImportDays), to); err != nil {
+				log.Printf("[PNL] ✗ Realized P&L import failed: %v", err)
+			}
+		})
+		if err != nil {
+			log.Printf("[SCHEDULER] ⚠ Failed to register P&L Import job: %v", err)
+		} else {
+			log.Printf("[SCHEDULER] Registered Realized P&L Import at 20:30 ET (Mon-Fri, last %d days)", pnlImportDays)
+		}
+	}
 
-	entryID, err := s.Cron.AddFunc(cronSpec, func() {
-		execTime := time.Now().In(s.Location)
-		log.Println("========================================")
-		log.Printf("[STRATEGY] ▶ Starting Monthly Rebalance Execution at %s", execTime.Format("2006-01-02 15:04:05 MST"))
-		log.Println("========================================")
+	// 5. USD/KRW Rate: store the day's rate once KIS has posted it (~09:00 KST)
+	// Schedule: 21:00 ET Sun-Thu = 10:00/11:00 KST Mon-Fri
+	if _, ok := s.Strat.Broker.(broker.FXQuoter); ok {
+		_, err = s.Cron.AddFunc("0 21 * * 0-4", func() {
+			ctx, cancel := context.WithTimeout(s.ctx, afterCloseTimeout)
+			defer cancel()
+			s.Strat.FX.Refresh(ctx)
+		})
+		if err != nil {
+			log.Printf("[SCHEDULER] ⚠ Failed to register FX Rate job: %v", err)
+		} else {
+			log.Printf("[SCHEDULER] Registered USD/KRW Rate Refresh at 21:00 ET (Sun-Thu)")
+		}
+	}
+
+	s.Cron.Start()
+	log.Printf("[SCHEDULER] ✓ Scheduler started successfully")
+	s.logNextRuns()
+	log.Println("========================================")
+
+	// Start heartbeat goroutine to log status every 30 minutes
+	go s.heartbeat()
+}
+
+// ScheduleStrategies registers a job for every enabled strategy, replacing
+// the previous ones; call it again after a strategy config changes
+func (s *Scheduler) ScheduleStrategies() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if err := s.Strat.ExecuteRebalance(false); err != nil {
-			log.Printf("[STRATEGY] ✗ Rebalance Execution Failed: %v", err)
+	for name, id := range s.jobs {
+		s.Cron.Remove(id)
+		delete(s.jobs, name)
+	}
+
+	configs, err := s.Strat.StrategyConfigs()
+	if err != nil {
+		log.Printf("[SCHEDULER] ✗ Cannot load strategy configs, no strategy scheduled: %v", err)
+		return
+	}
+	for _, cfg := range configs {
+		if !cfg.Enabled {
+			log.Printf("[SCHEDULER] Strategy %s is disabled", cfg.Name)
+			continue
+		}
+		spec, err := s.Spec(cfg)
+		if err != nil {
+			log.Printf("[SCHEDULER] ⚠ Strategy %s not scheduled: %v", cfg.Name, err)
+			continue
 		}
+		name := cfg.Name
+		id, err := s.Cron.AddFunc(spec, func() { s.runStrategy(name) })
+		if err != nil {
+			log.Printf("[SCHEDULER] ⚠ Strategy %s not scheduled, invalid cron %q: %v", name, spec, err)
+			continue
+		}
+		s.jobs[name] = id
+		log.Printf("[SCHEDULER] Strategy %s registered: placement %s (Cron: %s)", name, s.Strat.PlacementFor(cfg), spec)
+	}
+}
 
-		endTime := time.Now().In(s.Location)
-		log.Println("========================================")
-		log.Printf("[STRATEGY] ✓ Monthly Rebalance Execution Completed at %s (Duration: %v)", endTime.Format("2006-01-02 15:04:05 MST"), endTime.Sub(execTime))
-		log.Println("========================================")
-	})
+// Spec is the cron spec of a strategy: its own, else the plug-in default at
+// SCHEDULE_TIME, or RESERVE_TIME for reservations queued the evening before
+func (s *Scheduler) Spec(cfg model.StrategyConfig) (string, error) {
+	return s.Strat.Schedule(cfg, s.Config.ScheduleTime, s.Config.ReserveTime)
+}
+
+// NextRun is the next scheduled run of a strategy; false when it is not scheduled
+func (s *Scheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	id, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}, false
+	}
+	next := s.Cron.Entry(id).Next
+	return next, !next.IsZero()
+}
+
+// runStrategy is the job of one strategy
+func (s *Scheduler) runStrategy(name string) {
+	execTime := time.Now().In(s.Location)
+	log.Println("========================================")
+	log.Printf("[STRATEGY] ▶ Starting %s at %s", name, execTime.Format("2006-01-02 15:04:05 MST"))
+	log.Println("========================================")
+
+	cfg, err := s.Strat.StrategyConfig(name)
 	if err != nil {
-		log.Fatal("Error adding cron job:", err)
+		log.Printf("[STRATEGY] ✗ %s: %v", name, err)
+		return
 	}
 
-	s.Cron.Start()
+	// Orders placed after the close would miss the session: stop at 16:00 ET.
+	// Reservations are for the next session and only get a timeout.
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if s.Strat.PlacementFor(cfg) == service.PlacementReserve {
+		ctx, cancel = context.WithTimeout(s.ctx, afterCloseTimeout)
+	} else {
+		ctx, cancel = s.beforeCloseContext(execTime)
+	}
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok {
+		log.Printf("[STRATEGY] Deadline: %s", deadline.In(s.Location).Format("15:04:05 MST"))
+	}
 
-	// Calculate and log next scheduled execution
-	entry := s.Cron.Entry(entryID)
-	nextRun := entry.Next
-	log.Printf("[SCHEDULER] ✓ Scheduler started successfully")
-	log.Printf("[SCHEDULER] Next Rebalance: %s", nextRun.In(s.Location).Format("2006-01-02 15:04:05 MST"))
-	log.Printf("[SCHEDULER] Time until rebalance: %v", time.Until(nextRun).Round(time.Second))
+	if err := s.Strat.RunStrategy(ctx, name, false); err != nil {
+		log.Printf("[STRATEGY] ✗ %s Execution Failed: %v", name, err)
+	}
+
+	endTime := time.Now().In(s.Location)
+	log.Println("========================================")
+	log.Printf("[STRATEGY] ✓ %s Execution Completed at %s (Duration: %v)", name, endTime.Format("2006-01-02 15:04:05 MST"), endTime.Sub(execTime))
 	log.Println("========================================")
+}
 
-	// Start heartbeat goroutine to log status every 30 minutes
-	go s.heartbeat(entryID)
+// logNextRuns logs the next run of every scheduled strategy
+func (s *Scheduler) logNextRuns() {
+	for _, p := range s.Strat.Plugins.List() {
+		if next, ok := s.NextRun(p.Name()); ok {
+			log.Printf("[SCHEDULER] Next %s: %s (in %v)", p.Name(),
+				next.In(s.Location).Format("2006-01-02 15:04:05 MST"), time.Until(next).Round(time.Second))
+		}
+	}
 }
 
 // heartbeat logs the scheduler status periodically
-func (s *Scheduler) heartbeat(entryID cron.EntryID) {
+func (s *Scheduler) heartbeat() {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		entry := s.Cron.Entry(entryID)
-		nextRun := entry.Next
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
 		nowET := time.Now().In(s.Location)
-
-		log.Printf("[SCHEDULER] 💓 Heartbeat - Current Time (ET): %s | Next Execution: %s | Time Until: %v",
-			nowET.Format("15:04:05"),
-			nextRun.In(s.Location).Format("2006-01-02 15:04:05"),
-			time.Until(nextRun).Round(time.Second))
+		log.Printf("[SCHEDULER] 💓 Heartbeat - Current Time (ET): %s", nowET.Format("15:04:05"))
+		s.logNextRuns()
 	}
 }
@@ -1,26 +1,166 @@
 export interface CycleStatus {
     ID: number;
     Symbol: string;
+    Exchange: string;
     CurrentCycleDay: number;
     TotalBoughtQty: number;
     AvgPrice: number;
     TotalInvested: number;
+    UnitsUsed: number;
+    Phase: "" | "FIRST_HALF" | "SECOND_HALF" | "EXHAUSTED";
+    QuarterStops: number;
+    Mode: "" | "REVERSE";
+    ExhaustedAt: string | null;
+    ReverseDays: number;
+    ReversePrice: number;
+}
+
+export interface CycleEvent {
+    ID: number;
+    Date: string;
+    Symbol: string;
+    Event: "EXHAUSTED" | "QUARTER_STOP" | "REVERSE_START" | "REVERSE_SELL" | "REVERSE_END";
+    Policy: "quarter" | "reverse";
+    T: number;
+    Qty: number;
+    Price: number;
+    AvgPrice: number;
+    Note: string;
 }
 
 export interface UserSettings {
     Principal: number;
+    PrincipalCurrency: "USD" | "KRW";
     SplitCount: number;
     TargetRate: number;
+    LadderRate: number;
+    ExhaustPolicy: "quarter" | "reverse";
     Symbols: string;
     IsActive: boolean;
 }
 
+// Structured error returned by the backend: {"error", "category", "code", "tr_id"}
+export class ApiError extends Error {
+    category?: string;
+    code?: string;
+
+    constructor(message: string, category?: string, code?: string) {
+        super(message);
+        this.category = category;
+        this.code = code;
+    }
+}
+
+const categoryHints: Record<string, string> = {
+    AUTH: 'Broker authentication failed',
+    RATE_LIMIT: 'Broker rate limit hit, try again shortly',
+    MARKET_CLOSED: 'Market is closed',
+    FUNDS: 'Insufficient buying power or holdings',
+    VALIDATION: 'Invalid order',
+    UNAVAILABLE: 'Broker unavailable',
+    CONFLICT: 'Same order still awaiting verification',
+};
+
+async function toApiError(res: Response, fallback: string): Promise<ApiError> {
+    const body = await res.json().catch(() => ({}));
+    const message = body.error || fallback;
+    const hint = categoryHints[body.category];
+    return new ApiError(hint ? `${hint}: ${message}` : message, body.category, body.code);
+}
+
 export async function fetchDashboard() {
     const res = await fetch('/api/dashboard');
     if (!res.ok) throw new Error('Failed to fetch dashboard');
     return await res.json();
 }
 
+export async function fetchCycleEvents(symbol = "", limit = 20): Promise<CycleEvent[]> {
+    const params = new URLSearchParams({ limit: String(limit) });
+    if (symbol) params.set('symbol', symbol);
+    const res = await fetch(`/api/cycles/events?${params}`);
+    if (!res.ok) throw new Error('Failed to fetch cycle events');
+    return (await res.json()).events;
+}
+
+// A finished infinite-buy cycle
+export interface Cycle {
+    ID: number;
+    Symbol: string;
+    StartDate: string;
+    EndDate: string;
+    Days: number;
+    SplitCount: number;
+    UnitsUsed: number;
+    TotalInvested: number;
+    TotalSold: number;
+    RealizedProfit: number;
+    ReturnRate: number;
+    QuarterStops: number;
+    EndReason: "TARGET" | "QUARTER_STOP" | "REVERSE" | "MANUAL";
+}
+
+export interface CycleStats {
+    symbol: string;
+    cycles: number;
+    wins: number;
+    win_rate: number;
+    total_invested: number;
+    realized_profit: number;
+    avg_return: number;
+    avg_days: number;
+    max_days: number;
+    end_reasons: Record<string, number>;
+}
+
+export async function fetchCycleHistory(symbol = ""): Promise<{ cycles: Cycle[]; stats: CycleStats[] }> {
+    const params = symbol ? `?symbol=${encodeURIComponent(symbol)}` : '';
+    const res = await fetch(`/api/cycles/history${params}`);
+    if (!res.ok) throw new Error('Failed to fetch cycle history');
+    return await res.json();
+}
+
+// Per-symbol override of the settings; zero values fall back to them
+export interface CycleConfig {
+    Exchange: string;
+    Allocation: number;
+    SplitCount: number;
+    TargetRate: number;
+    LadderRate: number;
+}
+
+// Effective infinite-buy setup of one symbol
+export interface SymbolCycle {
+    symbol: string;
+    exchange?: string;
+    allocation: number;
+    principal: number;
+    split_count: number;
+    target_rate: number;
+    ladder_rate: number;
+    config: CycleConfig | null;
+}
+
+export async function fetchCycleConfigs(): Promise<SymbolCycle[]> {
+    const res = await fetch('/api/cycles/config');
+    if (!res.ok) throw new Error('Failed to fetch cycle configs');
+    return (await res.json()).cycles;
+}
+
+export async function updateCycleConfig(symbol: string, cfg: CycleConfig) {
+    const res = await fetch(`/api/cycles/config/${encodeURIComponent(symbol)}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(cfg)
+    });
+    if (!res.ok) throw await toApiError(res, 'Failed to update cycle config');
+    return await res.json();
+}
+
+export async function deleteCycleConfig(symbol: string) {
+    const res = await fetch(`/api/cycles/config/${encodeURIComponent(symbol)}`, { method: 'DELETE' });
+    if (!res.ok) throw await toApiError(res, 'Failed to delete cycle config');
+}
+
 export async function fetchSettings() {
     const res = await fetch('/api/settings');
     if (!res.ok) throw new Error('Failed to fetch settings');
@@ -35,13 +175,13 @@ export async function updateSettings(settings: UserSettings) {
         },
         body: JSON.stringify(settings)
     });
-    if (!res.ok) throw new Error('Failed to update settings');
+    if (!res.ok) throw await toApiError(res, 'Failed to update settings');
     return await res.json();
 }
 
 export async function triggerSync() {
     const res = await fetch('/api/sync', { method: 'POST' });
-    if (!res.ok) throw new Error('Sync failed');
+    if (!res.ok) throw await toApiError(res, 'Sync failed');
     return await res.json();
 }
 
@@ -69,23 +209,22 @@ export interface RebalancePlan {
     items: RebalanceItem[];
     estimated_tax: number;
     action_summary: string;
+    // KRW view, 0 when no USD/KRW rate is known
+    exchange_rate: number;
+    total_value_krw: number;
+    cash_krw: number;
+    estimated_tax_krw: number;
 }
 
 export async function fetchRebalancePreview() {
     const res = await fetch('/api/rebalance/preview');
-    if (!res.ok) {
-        const err = await res.json().catch(() => ({ error: 'Failed to fetch rebalance plan' }));
-        throw new Error(err.error || 'Failed to fetch rebalance plan');
-    }
+    if (!res.ok) throw await toApiError(res, 'Failed to fetch rebalance plan');
     return await res.json();
 }
 
 export async function executeRebalance(dryRun: boolean = true) {
     const res = await fetch(`/api/rebalance/execute?dry_run=${dryRun}`, { method: 'POST' });
-    if (!res.ok) {
-        const err = await res.json().catch(() => ({ error: 'Failed to execute rebalance' }));
-        throw new Error(err.error || 'Failed to execute rebalance');
-    }
+    if (!res.ok) throw await toApiError(res, 'Failed to execute rebalance');
     return await res.json();
 }
 
@@ -95,9 +234,136 @@ export async function executeCustomRebalance(plan: RebalancePlan, dryRun: boolea
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(plan)
     });
-    if (!res.ok) {
-        const err = await res.json().catch(() => ({ error: 'Failed to execute custom plan' }));
-        throw new Error(err.error || 'Failed to execute custom plan');
-    }
+    if (!res.ok) throw await toApiError(res, 'Failed to execute custom plan');
+    return await res.json();
+}
+
+// Strategy plug-ins: config as stored, effective_* as the scheduler runs it
+export interface StrategyInfo {
+    name: string;
+    description: string;
+    enabled: boolean;
+    schedule: string; // Cron (ET), empty = default
+    effective_schedule: string;
+    placement: '' | 'live' | 'reserve'; // Empty = ORDER_PLACEMENT
+    effective_placement: 'live' | 'reserve';
+    params?: Record<string, unknown>;
+    next_run: string | null;
+}
+
+export interface StrategyUpdate {
+    enabled?: boolean;
+    schedule?: string;
+    placement?: string;
+    params?: Record<string, unknown> | null;
+}
+
+export interface ProposedOrder {
+    symbol: string;
+    side: 'BUY' | 'SELL';
+    type: string;
+    qty: number;
+    price: number;
+    reason: string;
+}
+
+export interface Proposal {
+    strategy: string;
+    orders: ProposedOrder[];
+    summary: string;
+    detail?: unknown;
+}
+
+export async function fetchStrategies(): Promise<StrategyInfo[]> {
+    const res = await fetch('/api/strategies');
+    if (!res.ok) throw await toApiError(res, 'Failed to fetch strategies');
     return await res.json();
 }
+
+export async function updateStrategy(name: string, update: StrategyUpdate): Promise<StrategyInfo> {
+    const res = await fetch(`/api/strategies/${encodeURIComponent(name)}`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(update)
+    });
+    if (!res.ok) throw await toApiError(res, 'Failed to update strategy');
+    return await res.json();
+}
+
+export async function evaluateStrategy(name: string): Promise<Proposal> {
+    const res = await fetch(`/api/strategies/${encodeURIComponent(name)}/evaluate`);
+    if (!res.ok) throw await toApiError(res, 'Failed to evaluate strategy');
+    return await res.json();
+}
+
+// Realized P&L: broker figures (last import) next to our own TradeLog
+export interface PnLRow {
+    key: string; // YYYY-MM, symbol or TOTAL
+    broker_bought_qty: number;
+    broker_sold_qty: number;
+    broker_profit: number; // After fees
+    broker_fee: number;
+    local_bought_qty: number;
+    local_sold_qty: number;
+    local_profit: number; // Before fees
+    diff: number;
+    reconciled: boolean;
+}
+
+export interface PnLSummary {
+    from: string;
+    to: string;
+    broker: string;
+    total: PnLRow;
+    by_month: PnLRow[];
+    by_symbol: PnLRow[];
+    imported_at: string | null;
+}
+
+export interface PnLImportResult {
+    from: string;
+    to: string;
+    profits: number;
+    trades: number;
+}
+
+function rangeQuery(from?: string, to?: string): string {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const q = params.toString();
+    return q ? `?${q}` : '';
+}
+
+export async function fetchRealizedPnL(from?: string, to?: string): Promise<PnLSummary> {
+    const res = await fetch(`/api/pnl/realized${rangeQuery(from, to)}`);
+    if (!res.ok) throw await toApiError(res, 'Failed to fetch realized P&L');
+    return await res.json();
+}
+
+export async function importPnL(from?: string, to?: string): Promise<PnLImportResult> {
+    const res = await fetch(`/api/pnl/import${rangeQuery(from, to)}`, { method: 'POST' });
+    if (!res.ok) throw await toApiError(res, 'P&L import failed');
+    return await res.json();
+}
+
+export interface Quote {
+    exchange: string;
+    symbol: string;
+    last: number;
+    bid: number;
+    ask: number;
+    bid_size: number;
+    ask_size: number;
+    volume: number;
+    time: string;
+    received: string;
+}
+
+// Realtime quotes over server-sent events; returns a function that closes the stream.
+// The server answers 503 when REALTIME_QUOTES is off; the browser then gives up without retrying.
+export function streamQuotes(symbols: string[], onQuote: (q: Quote) => void): () => void {
+    const source = new EventSource(`/api/quotes/stream?symbols=${encodeURIComponent(symbols.join(','))}`);
+    source.addEventListener('quote', (e) => onQuote(JSON.parse((e as MessageEvent).data)));
+    return () => source.close();
+}